@@ -25,6 +25,11 @@ func (e *Error) Code() int {
 	return e.StatusCode
 }
 
+// Unwrap returns the wrapped internal error, so errors.Is and errors.As can inspect it.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func (e *Error) Cause(err error) error {
 	if err != nil {
 		e.Err = fmt.Errorf("%w", err)
@@ -1,8 +1,15 @@
 package common
 
 const (
-	ErrUnexpectedDatabase = "unexpected database error"
-	ErrTXBegin            = "error creating transaction"
-	ErrTXRollback         = "error rolling back the transaction"
-	ErrTxCommit           = "error committing the transaction"
+	ErrUnexpectedDatabase   = "unexpected database error"
+	ErrTXBegin              = "error creating transaction"
+	ErrTXRollback           = "error rolling back the transaction"
+	ErrTxCommit             = "error committing the transaction"
+	ErrParkingLotFull       = "parking lot is full"
+	ErrReservationOverlaps  = "vehicle already has a reservation overlapping this window"
+	ErrNoSlotForReservation = "no slot available for this reservation window"
+	ErrPermitPlateOverlaps  = "a plate already has a permit overlapping this period"
+	ErrPermitSlotTaken      = "slot is assigned to another permit or reserved during this period"
+	ErrVehicleParked        = "vehicle with this registration number is already parked"
+	ErrVehicleParkedInOther = "vehicle is parked in another parking lot"
 )
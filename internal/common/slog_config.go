@@ -6,13 +6,13 @@ import (
 )
 
 // GetSlogConf constructs and returns a pointer to a slog.HandlerOptions struct.
-// This function customizes the log configuration by setting the logging level to debug
+// This function customizes the log configuration by setting the given logging level
 // and stripping the full directory path from the source's filename. The customized options
 // are encapsulated in a slog.HandlerOptions and returned as a pointer.
 //
 // Returns:
 //   - *slog.HandlerOptions: Pointer to a slog.HandlerOptions struct containing the logging configurations.
-func GetSlogConf() *slog.HandlerOptions {
+func GetSlogConf(level slog.Leveler) *slog.HandlerOptions {
 	replace := func(groups []string, a slog.Attr) slog.Attr {
 		// Remove the directory from the source's filename.
 		if a.Key == slog.SourceKey {
@@ -29,7 +29,7 @@ func GetSlogConf() *slog.HandlerOptions {
 
 	handlerOpts := slog.HandlerOptions{
 		AddSource:   true,
-		Level:       slog.LevelDebug,
+		Level:       level,
 		ReplaceAttr: replace,
 	}
 
@@ -0,0 +1,348 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/plate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StorageBackendPostgres = "postgres"
+	StorageBackendMemory   = "memory"
+
+	PlatePolicySingleLot = "single-lot"
+	PlatePolicyMultiLot  = "multi-lot"
+
+	redacted = "REDACTED"
+)
+
+// Config is the typed application configuration, loaded by Load.
+type Config struct {
+	StorageBackend string        `yaml:"storageBackend"`
+	Server         ServerConfig  `yaml:"server"`
+	DB             DBConfig      `yaml:"db"`
+	Log            LogConfig     `yaml:"log"`
+	Parking        ParkingConfig `yaml:"parking"`
+}
+
+type ServerConfig struct {
+	Host              string        `yaml:"host"`
+	Port              string        `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"`
+	// ShutdownReadinessDelay is how long readiness fails before draining starts, so load balancers stop routing first.
+	ShutdownReadinessDelay time.Duration `yaml:"shutdownReadinessDelay"`
+}
+
+type DBConfig struct {
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslMode"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// ParkingConfig holds parking rules shared by every parking lot.
+// PlatePolicy is single-lot, a plate is parked in one parking lot at a time, or multi-lot, once per parking lot.
+// PlatePatterns are regular expressions of valid canonical plates by country code, see plate.Rules, none accepts any plate.
+type ParkingConfig struct {
+	PlatePolicy   string            `yaml:"platePolicy"`
+	PlatePatterns map[string]string `yaml:"platePatterns"`
+}
+
+// Default returns the configuration used for anything not set by a file, env variable or flag.
+func Default() Config {
+	return Config{
+		StorageBackend: StorageBackendPostgres,
+		Server: ServerConfig{
+			Host:                   "127.0.0.1",
+			Port:                   "8080",
+			ReadTimeout:            5 * time.Second,
+			WriteTimeout:           10 * time.Second,
+			IdleTimeout:            15 * time.Second,
+			ReadHeaderTimeout:      2 * time.Second,
+			ShutdownTimeout:        15 * time.Second,
+			ShutdownReadinessDelay: 5 * time.Second,
+		},
+		DB: DBConfig{
+			User:            "postgres",
+			Password:        "postgres",
+			Host:            "127.0.0.1",
+			Port:            "5432",
+			Name:            "gopark",
+			SSLMode:         "disable",
+			MaxOpenConns:    10,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 3 * time.Minute,
+		},
+		Log: LogConfig{
+			Level: "debug",
+		},
+		Parking: ParkingConfig{
+			PlatePolicy: PlatePolicySingleLot,
+		},
+	}
+}
+
+// field binds a config value to its env variable and command line flag.
+type field struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var fields = []field{
+	{"STORAGE_BACKEND", "storage-backend", "storage backend, postgres or memory", str(func(c *Config) *string { return &c.StorageBackend })},
+	{"API_HOST", "api-host", "http server host", str(func(c *Config) *string { return &c.Server.Host })},
+	{"API_PORT", "api-port", "http server port", str(func(c *Config) *string { return &c.Server.Port })},
+	{"API_READ_TIMEOUT", "api-read-timeout", "http server read timeout", dur(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{"API_WRITE_TIMEOUT", "api-write-timeout", "http server write timeout", dur(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{"API_IDLE_TIMEOUT", "api-idle-timeout", "http server idle timeout", dur(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{"API_READ_HEADER_TIMEOUT", "api-read-header-timeout", "http server read header timeout", dur(func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "graceful shutdown deadline", dur(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{"SHUTDOWN_READINESS_DELAY", "shutdown-readiness-delay", "how long readiness fails before draining on shutdown", dur(func(c *Config) *time.Duration { return &c.Server.ShutdownReadinessDelay })},
+	{"DB_USER", "db-user", "postgres user", str(func(c *Config) *string { return &c.DB.User })},
+	{"DB_PASSWD", "db-passwd", "postgres password", str(func(c *Config) *string { return &c.DB.Password })},
+	{"DB_HOST", "db-host", "postgres host", str(func(c *Config) *string { return &c.DB.Host })},
+	{"DB_PORT", "db-port", "postgres port", str(func(c *Config) *string { return &c.DB.Port })},
+	{"DB_NAME", "db-name", "postgres database name", str(func(c *Config) *string { return &c.DB.Name })},
+	{"DB_SSLMODE", "db-sslmode", "postgres sslmode", str(func(c *Config) *string { return &c.DB.SSLMode })},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", integer(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", integer(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum database connection lifetime", dur(func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime })},
+	{"LOG_LEVEL", "log-level", "log level, debug, info, warn or error", str(func(c *Config) *string { return &c.Log.Level })},
+	{"PLATE_POLICY", "plate-policy", "whether a plate may be parked in several parking lots at once, single-lot or multi-lot", str(func(c *Config) *string { return &c.Parking.PlatePolicy })},
+	{"PLATE_PATTERNS", "plate-patterns", "valid plates by country, eg: GB=[A-Z]{2}[0-9]{2}[A-Z]{3};NL=[A-Z0-9]{6}", patterns(func(c *Config) *map[string]string { return &c.Parking.PlatePatterns })},
+}
+
+// Load builds the configuration from defaults, overridden in order by an optional YAML file, env variables and flags.
+// The file is given by the -config flag or CONFIG_FILE env variable. Returns the arguments left after flags,
+// eg: ["migrate", "up"] for `gopark -log-level info migrate up`.
+func Load(args []string, getenv func(string) string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("gopark", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to an optional YAML config file")
+
+	flagValues := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flagValues[f.flag] = fs.String(f.flag, "", fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		content, err := os.ReadFile(*configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading config file: %w", err)
+		}
+
+		if err = yaml.Unmarshal(content, &cfg); err != nil {
+			return nil, nil, fmt.Errorf("parsing config file %s: %w", *configFile, err)
+		}
+	}
+
+	for _, f := range fields {
+		if v := getenv(f.env); v != "" {
+			if err := f.set(&cfg, v); err != nil {
+				return nil, nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
+	}
+
+	var errs []error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				if err := f.set(&cfg, *flagValues[f.flag]); err != nil {
+					errs = append(errs, fmt.Errorf("flag -%s: %w", f.flag, err))
+				}
+			}
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, fs.Args(), nil
+}
+
+// Validate checks every value is usable, returning all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.StorageBackend != StorageBackendPostgres && c.StorageBackend != StorageBackendMemory {
+		errs = append(errs, fmt.Errorf("storage backend must be %s or %s, got %q", StorageBackendPostgres, StorageBackendMemory, c.StorageBackend))
+	}
+
+	if err := validatePort(c.Server.Port); err != nil {
+		errs = append(errs, fmt.Errorf("server port: %w", err))
+	}
+
+	for name, d := range map[string]time.Duration{
+		"server read timeout":        c.Server.ReadTimeout,
+		"server write timeout":       c.Server.WriteTimeout,
+		"server idle timeout":        c.Server.IdleTimeout,
+		"server read header timeout": c.Server.ReadHeaderTimeout,
+		"shutdown timeout":           c.Server.ShutdownTimeout,
+		"db connection max lifetime": c.DB.ConnMaxLifetime,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", name, d))
+		}
+	}
+
+	if c.Server.ShutdownReadinessDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdown readiness delay must not be negative, got %s", c.Server.ShutdownReadinessDelay))
+	}
+
+	if c.StorageBackend == StorageBackendPostgres {
+		if err := validatePort(c.DB.Port); err != nil {
+			errs = append(errs, fmt.Errorf("db port: %w", err))
+		}
+
+		if c.DB.User == "" || c.DB.Host == "" || c.DB.Name == "" {
+			errs = append(errs, errors.New("db user, host and name are required"))
+		}
+	}
+
+	switch c.DB.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("db sslmode %q is not a valid postgres sslmode", c.DB.SSLMode))
+	}
+
+	if c.DB.MaxOpenConns <= 0 || c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("db pool sizes must satisfy 0 <= max idle (%d) <= max open (%d), max open > 0",
+			c.DB.MaxIdleConns, c.DB.MaxOpenConns))
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Parking.PlatePolicy != PlatePolicySingleLot && c.Parking.PlatePolicy != PlatePolicyMultiLot {
+		errs = append(errs, fmt.Errorf("plate policy must be %s or %s, got %q", PlatePolicySingleLot, PlatePolicyMultiLot, c.Parking.PlatePolicy))
+	}
+
+	if _, err := plate.NewRules(c.Parking.PlatePatterns); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel parses the configured log level.
+func (l LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return 0, fmt.Errorf("log level %q must be debug, info, warn or error", l.Level)
+	}
+
+	return level, nil
+}
+
+// LogValue implements slog.LogValuer, logging the configuration with secrets redacted.
+func (c Config) LogValue() slog.Value {
+	db := c.DB
+	if db.Password != "" {
+		db.Password = redacted
+	}
+
+	return slog.GroupValue(
+		slog.String("storageBackend", c.StorageBackend),
+		slog.Any("server", c.Server),
+		slog.Any("db", db),
+		slog.String("logLevel", c.Log.Level),
+		slog.Any("parking", c.Parking),
+	)
+}
+
+func validatePort(port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+
+	return nil
+}
+
+func str(target func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*target(c) = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+func integer(target func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+
+		*target(c) = n
+		return nil
+	}
+}
+
+func dur(target func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, eg: 5s", v)
+		}
+
+		*target(c) = d
+		return nil
+	}
+}
+
+// patterns parses semicolon separated COUNTRY=pattern pairs, replacing every pattern set before.
+func patterns(target func(c *Config) *map[string]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		m := make(map[string]string)
+		for _, pair := range strings.Split(v, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+
+			country, pattern, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a COUNTRY=pattern pair", pair)
+			}
+
+			m[strings.TrimSpace(country)] = strings.TrimSpace(pattern)
+		}
+
+		*target(c) = m
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load(nil, envFrom(nil))
+	if err != nil {
+		t.Fatalf("Load returned error %v", err)
+	}
+
+	if !reflect.DeepEqual(*cfg, Default()) || len(args) != 0 {
+		t.Errorf("Load returned %+v, args %v; expected the defaults and no args", cfg, args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gopark.yaml")
+	content := "server:\n  port: \"9000\"\n  host: 0.0.0.0\n  shutdownTimeout: 30s\ndb:\n  name: fromfile\n  maxOpenConns: 20\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := envFrom(map[string]string{
+		"CONFIG_FILE":    file,
+		"API_PORT":       "9100",
+		"DB_NAME":        "fromenv",
+		"LOG_LEVEL":      "warn",
+		"PLATE_PATTERNS": "GB=[A-Z]{2}[0-9]{2}[A-Z]{3}; NL=[A-Z0-9]{6}",
+
+		"SHUTDOWN_READINESS_DELAY": "0s",
+	})
+
+	cfg, args, err := Load([]string{"-api-port", "9200", "-db-max-idle-conns", "5", "migrate", "up"}, env)
+	if err != nil {
+		t.Fatalf("Load returned error %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		actual   any
+		expected any
+	}{
+		{"flag overrides env and file", cfg.Server.Port, "9200"},
+		{"file overrides defaults", cfg.Server.Host, "0.0.0.0"},
+		{"file duration", cfg.Server.ShutdownTimeout, 30 * time.Second},
+		{"env overrides file", cfg.DB.Name, "fromenv"},
+		{"file int", cfg.DB.MaxOpenConns, 20},
+		{"flag int", cfg.DB.MaxIdleConns, 5},
+		{"env only", cfg.Log.Level, "warn"},
+		{"env zero readiness delay", cfg.Server.ShutdownReadinessDelay, time.Duration(0)},
+		{"env plate patterns", len(cfg.Parking.PlatePatterns), 2},
+		{"env plate pattern", cfg.Parking.PlatePatterns["GB"], "[A-Z]{2}[0-9]{2}[A-Z]{3}"},
+		{"default kept", cfg.DB.User, "postgres"},
+		{"remaining args", strings.Join(args, " "), "migrate up"},
+	}
+
+	for _, tt := range tests {
+		if tt.actual != tt.expected {
+			t.Errorf("%s: got %v; expected %v", tt.name, tt.actual, tt.expected)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name:     "bad duration",
+			env:      map[string]string{"SHUTDOWN_TIMEOUT": "soon"},
+			expected: []string{"SHUTDOWN_TIMEOUT"},
+		},
+		{
+			name:     "bad integer flag",
+			args:     []string{"-db-max-open-conns", "many"},
+			expected: []string{"-db-max-open-conns"},
+		},
+		{
+			name:     "bad plate patterns",
+			env:      map[string]string{"PLATE_PATTERNS": "GB"},
+			expected: []string{"PLATE_PATTERNS", "COUNTRY=pattern"},
+		},
+		{
+			name:     "missing config file",
+			env:      map[string]string{"CONFIG_FILE": "/does/not/exist.yaml"},
+			expected: []string{"reading config file"},
+		},
+		{
+			name: "every validation problem reported",
+			env: map[string]string{
+				"STORAGE_BACKEND":   "sqlite",
+				"API_PORT":          "70000",
+				"DB_SSLMODE":        "sometimes",
+				"DB_MAX_IDLE_CONNS": "50",
+				"LOG_LEVEL":         "loud",
+				"PLATE_POLICY":      "anywhere",
+				"PLATE_PATTERNS":    "GB=[A-Z",
+
+				"SHUTDOWN_READINESS_DELAY": "-1s",
+			},
+			expected: []string{"storage backend", "server port", "sslmode", "pool sizes", "log level", "plate policy", "plate pattern of GB", "readiness delay"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load(tt.args, envFrom(tt.env))
+			if err == nil {
+				t.Fatal("Load returned no error")
+			}
+
+			for _, s := range tt.expected {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("Load returned error %q; expected it to mention %q", err, s)
+				}
+			}
+		})
+	}
+}
+
+func TestLogValueRedactsPassword(t *testing.T) {
+	cfg := Default()
+	cfg.DB.Password = "s3cret"
+
+	if s := cfg.LogValue().String(); strings.Contains(s, "s3cret") || !strings.Contains(s, redacted) {
+		t.Errorf("LogValue returned %s; expected the password redacted", s)
+	}
+}
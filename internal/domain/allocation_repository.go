@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// GetAllocationStrategy returns the slot allocation strategy of a parking lot.
+func (r *ParkingLotRepoDB) GetAllocationStrategy(ctx context.Context, plUUID uuid.UUID) (AllocationStrategy, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	var strategy AllocationStrategy
+	if err := r.db.QueryRowContext(ctx, "SELECT allocation_strategy FROM parking_lots WHERE id = $1", plID).Scan(&strategy); err != nil {
+		r.l.Error("error fetching allocation strategy", "err", err)
+		return "", common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return strategy, nil
+}
+
+// SetAllocationStrategy changes the slot allocation strategy of a parking lot, applied from the next park.
+// Round-robin allocation continues after the last slot it allocated, if the lot used it before.
+func (r *ParkingLotRepoDB) SetAllocationStrategy(ctx context.Context, plUUID uuid.UUID, strategy AllocationStrategy) common.AppError {
+	if !strategy.Valid() {
+		return common.NewBadRequestError(fmt.Sprintf("unknown allocation strategy %q", strategy))
+	}
+
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return appErr
+	}
+
+	if _, err := r.db.ExecContext(ctx, "UPDATE parking_lots SET allocation_strategy = $1 WHERE id = $2", strategy, plID); err != nil {
+		r.l.Error("error updating allocation strategy", "err", err)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/ashtishad/gopark/internal/common"
+)
+
+// AllocationStrategy names how a parking lot picks among the free slots that fit a vehicle equally well.
+type AllocationStrategy string
+
+const (
+	// AllocationNearest picks a slot on the level closest to the entrance, then the lowest slot number.
+	AllocationNearest AllocationStrategy = "nearest"
+	// AllocationRoundRobin picks the lowest slot number after the last allocated one, wrapping around, to spread wear.
+	AllocationRoundRobin AllocationStrategy = "round-robin"
+	// AllocationLeastRecentlyUsed picks the slot released the longest ago, never used slots first.
+	AllocationLeastRecentlyUsed AllocationStrategy = "least-recently-used"
+	// AllocationRandom picks any slot at random.
+	AllocationRandom AllocationStrategy = "random"
+	// AllocationFillByZone picks a slot of the zone with the most occupied slots, filling zones one at a time,
+	// eg: so empty zones can be closed at night. Ties go to the nearest zone.
+	AllocationFillByZone AllocationStrategy = "fill-by-zone"
+
+	// DefaultAllocationStrategy is used by parking lots created without a strategy.
+	DefaultAllocationStrategy = AllocationNearest
+)
+
+// AllocationStrategies lists every allocation strategy.
+var AllocationStrategies = []AllocationStrategy{
+	AllocationNearest, AllocationRoundRobin, AllocationLeastRecentlyUsed, AllocationRandom, AllocationFillByZone,
+}
+
+// SlotAllocator picks the slot to park a vehicle in. Every strategy is implemented twice, as SQL ordering for
+// VehicleRepositoryDB and in Go for VehicleRepositoryMemory, and both must pick the same slot.
+// Slots are always ranked by type fit and the preferred level and zone first, an allocator only breaks the remaining ties.
+type SlotAllocator interface {
+	Strategy() AllocationStrategy
+
+	// orderBy returns the ORDER BY terms ranking free slots s of parking lot pl, on level lv.
+	orderBy() string
+
+	// choose returns the index of the candidate to allocate, candidates are ordered by slot number.
+	choose(candidates []slotCandidate) int
+}
+
+// slotCandidate is a free slot fitting the vehicle, with what allocators rank it by.
+type slotCandidate struct {
+	slot             *memSlot
+	entranceDistance int
+	zoneOccupied     int // occupied slots in the candidate's level and zone
+	lastAllocated    int // slot number of the lot's last round-robin allocation
+}
+
+var slotAllocators = map[AllocationStrategy]SlotAllocator{
+	AllocationNearest:           nearestAllocator{},
+	AllocationRoundRobin:        roundRobinAllocator{},
+	AllocationLeastRecentlyUsed: leastRecentlyUsedAllocator{},
+	AllocationRandom:            randomAllocator{},
+	AllocationFillByZone:        fillByZoneAllocator{},
+}
+
+// ParseAllocationStrategy parses an allocation strategy case-insensitively, an empty string is the default strategy.
+func ParseAllocationStrategy(s string) (AllocationStrategy, error) {
+	if s == "" {
+		return DefaultAllocationStrategy, nil
+	}
+
+	strategy := AllocationStrategy(strings.ToLower(s))
+	if !strategy.Valid() {
+		return "", fmt.Errorf("allocation strategy must be one of %v, got %q", AllocationStrategies, s)
+	}
+
+	return strategy, nil
+}
+
+// Valid reports whether s is a known allocation strategy.
+func (s AllocationStrategy) Valid() bool {
+	_, ok := slotAllocators[s]
+	return ok
+}
+
+// parseLotAllocationStrategy validates the allocation strategy of a parking lot to create, defaulting it when empty.
+func (lot *ParkingLot) parseLotAllocationStrategy() common.AppError {
+	strategy, err := ParseAllocationStrategy(string(lot.AllocationStrategy))
+	if err != nil {
+		return common.NewBadRequestError(err.Error())
+	}
+
+	lot.AllocationStrategy = strategy
+	return nil
+}
+
+// NewSlotAllocator returns the allocator of a strategy, the default one for unknown strategies.
+func NewSlotAllocator(strategy AllocationStrategy) SlotAllocator {
+	if a, ok := slotAllocators[strategy]; ok {
+		return a
+	}
+
+	return slotAllocators[DefaultAllocationStrategy]
+}
+
+// firstBy returns the index of the first candidate no other candidate is less than.
+func firstBy(candidates []slotCandidate, less func(a, b slotCandidate) bool) int {
+	best := 0
+	for i := 1; i < len(candidates); i++ {
+		if less(candidates[i], candidates[best]) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+type nearestAllocator struct{}
+
+func (nearestAllocator) Strategy() AllocationStrategy { return AllocationNearest }
+
+func (nearestAllocator) orderBy() string {
+	return "COALESCE(lv.entrance_distance, 0), s.slot_number"
+}
+
+func (nearestAllocator) choose(candidates []slotCandidate) int {
+	return firstBy(candidates, func(a, b slotCandidate) bool {
+		return a.entranceDistance < b.entranceDistance
+	})
+}
+
+type roundRobinAllocator struct{}
+
+func (roundRobinAllocator) Strategy() AllocationStrategy { return AllocationRoundRobin }
+
+// orderBy ranks slots numbered after the last allocated one first, false sorts before true.
+func (roundRobinAllocator) orderBy() string {
+	return "s.slot_number <= pl.last_allocated_slot, s.slot_number"
+}
+
+func (roundRobinAllocator) choose(candidates []slotCandidate) int {
+	return firstBy(candidates, func(a, b slotCandidate) bool {
+		return a.slot.SlotNumber > a.lastAllocated && b.slot.SlotNumber <= b.lastAllocated
+	})
+}
+
+type leastRecentlyUsedAllocator struct{}
+
+func (leastRecentlyUsedAllocator) Strategy() AllocationStrategy { return AllocationLeastRecentlyUsed }
+
+func (leastRecentlyUsedAllocator) orderBy() string {
+	return "s.last_released_at NULLS FIRST, s.slot_number"
+}
+
+func (leastRecentlyUsedAllocator) choose(candidates []slotCandidate) int {
+	return firstBy(candidates, func(a, b slotCandidate) bool {
+		switch {
+		case a.slot.lastReleasedAt == nil:
+			return b.slot.lastReleasedAt != nil
+		case b.slot.lastReleasedAt == nil:
+			return false
+		default:
+			return a.slot.lastReleasedAt.Before(*b.slot.lastReleasedAt)
+		}
+	})
+}
+
+type randomAllocator struct{}
+
+func (randomAllocator) Strategy() AllocationStrategy { return AllocationRandom }
+
+func (randomAllocator) orderBy() string {
+	return "random()"
+}
+
+func (randomAllocator) choose(candidates []slotCandidate) int {
+	return rand.IntN(len(candidates)) //nolint:gosec // slot allocation doesn't need a secure random source
+}
+
+type fillByZoneAllocator struct{}
+
+func (fillByZoneAllocator) Strategy() AllocationStrategy { return AllocationFillByZone }
+
+func (fillByZoneAllocator) orderBy() string {
+	return `(SELECT COUNT(*) FROM slots z
+                 WHERE z.parking_lot_id = s.parking_lot_id AND z.level_id IS NOT DISTINCT FROM s.level_id
+                   AND z.zone = s.zone AND z.is_available = false) DESC,
+                COALESCE(lv.entrance_distance, 0), s.slot_number`
+}
+
+func (fillByZoneAllocator) choose(candidates []slotCandidate) int {
+	return firstBy(candidates, func(a, b slotCandidate) bool {
+		if a.zoneOccupied != b.zoneOccupied {
+			return a.zoneOccupied > b.zoneOccupied
+		}
+
+		return a.entranceDistance < b.entranceDistance
+	})
+}
@@ -0,0 +1,323 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnalyticsQuery selects the period of occupancy analytics, extended to whole hours in the parking lot's time zone,
+// at most MaxReportBuckets hours. FromDate and ToDate take From and To as calendar dates like ReportQuery.
+type AnalyticsQuery struct {
+	From     time.Time
+	To       time.Time
+	FromDate bool
+	ToDate   bool
+}
+
+// reportQuery returns the hourly ReportQuery of the period in loc, to normalize and lay out the timeline.
+func (q AnalyticsQuery) reportQuery(loc *time.Location) ReportQuery {
+	return ReportQuery{From: q.From, To: q.To, FromDate: q.FromDate, ToDate: q.ToDate, Granularity: ReportGranularityHour, Location: loc}
+}
+
+// OccupancyAnalytics is how busy a parking lot was over a period, reconstructed from the intervals vehicles were parked.
+// Vehicles still parked occupy their slot until now, and time after now isn't counted, so a period reaching into the future
+// is measured up to now. Percentages and averages are rounded to 2 decimals.
+//
+// UtilizationPercent is the share of slot time occupied, AverageOccupancy the average number of vehicles parked.
+// PeakOccupancy is the most vehicles parked at the same time, first reached at PeakAt, nil if the lot stayed empty.
+// AverageTurnover is the vehicles parked during the period per slot.
+type OccupancyAnalytics struct {
+	ParkingLotID       uuid.UUID            `json:"parkingLotId"`
+	TimeZone           string               `json:"timeZone"`
+	From               time.Time            `json:"from"`
+	To                 time.Time            `json:"to"`
+	Capacity           int                  `json:"capacity"`
+	UtilizationPercent float64              `json:"utilizationPercent"`
+	AverageOccupancy   float64              `json:"averageOccupancy"`
+	PeakOccupancy      int                  `json:"peakOccupancy"`
+	PeakAt             *time.Time           `json:"peakAt"`
+	PeakHour           *HourOfDayOccupancy  `json:"peakHour"`
+	VehiclesParked     int                  `json:"vehiclesParked"`
+	AverageTurnover    float64              `json:"averageTurnover"`
+	Dwell              DwellDistribution    `json:"dwell"`
+	Hourly             []OccupancyBucket    `json:"hourly"`
+	HourOfDay          []HourOfDayOccupancy `json:"hourOfDay"`
+	Slots              []SlotUtilization    `json:"slots"`
+}
+
+// OccupancyBucket is the occupancy of a parking lot during an hour of the period.
+type OccupancyBucket struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	AverageOccupancy   float64   `json:"averageOccupancy"`
+	PeakOccupancy      int       `json:"peakOccupancy"`
+	UtilizationPercent float64   `json:"utilizationPercent"`
+}
+
+// HourOfDayOccupancy is the average occupancy of a local hour of the day, eg: 9 for 09:00-10:00, across every day of the period.
+// PeakHour is the hour of the day with the highest average occupancy, the earliest one on ties.
+type HourOfDayOccupancy struct {
+	Hour             int     `json:"hour"`
+	AverageOccupancy float64 `json:"averageOccupancy"`
+}
+
+// SlotUtilization is the share of the period a slot was occupied, and Turnover the vehicles parked in it during the period.
+type SlotUtilization struct {
+	SlotID             uuid.UUID `json:"slotId"`
+	SlotNumber         int       `json:"slotNumber"`
+	Label              string    `json:"label"`
+	OccupiedSeconds    int64     `json:"occupiedSeconds"`
+	UtilizationPercent float64   `json:"utilizationPercent"`
+	Turnover           int       `json:"turnover"`
+}
+
+// DwellDistribution summarizes how long the vehicles parked during the period stayed, counting the ones already unparked.
+// Percentiles use the nearest rank, eg: P90Seconds is the shortest stay at least 90% of the stays didn't exceed.
+type DwellDistribution struct {
+	Stays          int   `json:"stays"`
+	AverageSeconds int64 `json:"averageSeconds"`
+	P50Seconds     int64 `json:"p50Seconds"`
+	P90Seconds     int64 `json:"p90Seconds"`
+	P99Seconds     int64 `json:"p99Seconds"`
+}
+
+// InTimeZone renders every timestamp of the analytics in the parking lot's time zone instead of UTC.
+func (a *OccupancyAnalytics) InTimeZone() {
+	loc := lotLocation(a.TimeZone)
+	a.From, a.To = a.From.In(loc), a.To.In(loc)
+	timeIn(a.PeakAt, loc)
+	for i := range a.Hourly {
+		a.Hourly[i].Start, a.Hourly[i].End = a.Hourly[i].Start.In(loc), a.Hourly[i].End.In(loc)
+	}
+}
+
+// occupancyTimeline reconstructs the occupancy of a parking lot from the stays added to it one at a time,
+// so backends can stream stays without holding them. Stays are clipped to the period, and to now for the ones still parked.
+type occupancyTimeline struct {
+	q        ReportQuery
+	end      time.Time // the earliest of the period's end and now
+	starts   []time.Time
+	occupied []time.Duration // vehicle time by bucket
+	changes  map[time.Time]int
+	slots    []SlotUtilization
+	bySlot   map[uuid.UUID]int
+	slotTime []time.Duration
+	dwell    []int64
+	vehicles int
+}
+
+// newOccupancyTimeline starts the timeline of a normalized hourly query over the slots of a lot, ordered by slot number.
+func newOccupancyTimeline(q ReportQuery, slots []SlotUtilization, now time.Time) *occupancyTimeline {
+	t := &occupancyTimeline{
+		q:        q,
+		end:      q.To.UTC(),
+		starts:   q.bucketStarts(),
+		changes:  make(map[time.Time]int),
+		slots:    slots,
+		bySlot:   make(map[uuid.UUID]int, len(slots)),
+		slotTime: make([]time.Duration, len(slots)),
+	}
+
+	if now.Before(t.end) {
+		t.end = now.UTC()
+	}
+
+	t.occupied = make([]time.Duration, len(t.starts))
+	for i, slot := range slots {
+		t.bySlot[slot.SlotID] = i
+	}
+
+	return t
+}
+
+// add records the stay of a vehicle in a slot of the lot, unparkedAt is nil while it's still parked.
+func (t *occupancyTimeline) add(slotID uuid.UUID, parkedAt time.Time, unparkedAt *time.Time) {
+	slot, ok := t.bySlot[slotID]
+	if !ok {
+		return
+	}
+
+	if !parkedAt.Before(t.q.From) && parkedAt.Before(t.q.To) {
+		t.vehicles++
+		t.slots[slot].Turnover++
+		if unparkedAt != nil {
+			t.dwell = append(t.dwell, int64(unparkedAt.Sub(parkedAt)/time.Second))
+		}
+	}
+
+	start, stop := maxTime(parkedAt, t.q.From).UTC(), t.end
+	if unparkedAt != nil && unparkedAt.Before(stop) {
+		stop = unparkedAt.UTC()
+	}
+
+	if !stop.After(start) {
+		return
+	}
+
+	t.slotTime[slot] += stop.Sub(start)
+	t.changes[start]++
+	if stop.Before(t.end) {
+		t.changes[stop]--
+	}
+
+	for i := t.bucket(start); i < len(t.starts) && t.starts[i].Before(stop); i++ {
+		t.occupied[i] += minTime(stop, t.bucketEnd(i)).Sub(maxTime(start, t.starts[i]))
+	}
+}
+
+// bucket returns the index of the bucket at falls in.
+func (t *occupancyTimeline) bucket(at time.Time) int {
+	return sort.Search(len(t.starts), func(i int) bool { return t.starts[i].After(at) }) - 1
+}
+
+func (t *occupancyTimeline) bucketEnd(i int) time.Time {
+	if i+1 < len(t.starts) {
+		return t.starts[i+1]
+	}
+
+	return t.q.To.UTC()
+}
+
+// analytics summarizes the stays added so far.
+func (t *occupancyTimeline) analytics(plUUID uuid.UUID, timeZone string) *OccupancyAnalytics {
+	a := &OccupancyAnalytics{
+		ParkingLotID:   plUUID,
+		TimeZone:       timeZone,
+		From:           t.q.From.UTC(),
+		To:             t.q.To.UTC(),
+		Capacity:       len(t.slots),
+		VehiclesParked: t.vehicles,
+		Dwell:          newDwellDistribution(t.dwell),
+		Hourly:         make([]OccupancyBucket, len(t.starts)),
+		HourOfDay:      make([]HourOfDayOccupancy, 24),
+		Slots:          t.slots,
+	}
+
+	elapsed := t.end.Sub(t.q.From)
+	var occupied time.Duration
+	for i, d := range t.slotTime {
+		occupied += d
+		a.Slots[i].OccupiedSeconds = int64(d / time.Second)
+		a.Slots[i].UtilizationPercent = percent(d, elapsed)
+	}
+
+	a.AverageOccupancy = ratio(occupied, elapsed)
+	a.UtilizationPercent = percent(occupied, elapsed*time.Duration(a.Capacity))
+	if a.Capacity > 0 {
+		a.AverageTurnover = round2(float64(t.vehicles) / float64(a.Capacity))
+	}
+
+	// occupancy changes at the same instant are netted, a vehicle leaving as another one arrives doesn't make a peak.
+	instants := make([]time.Time, 0, len(t.changes))
+	for at := range t.changes {
+		instants = append(instants, at)
+	}
+
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+
+	var hourTime, hourOccupied [24]time.Duration
+	occupancy, next := 0, 0
+	for i, start := range t.starts {
+		end := t.bucketEnd(i)
+		b := OccupancyBucket{Start: start, End: end}
+		for ; next < len(instants) && !instants[next].After(start); next++ {
+			occupancy += t.changes[instants[next]]
+		}
+
+		if bucketElapsed := minTime(end, t.end).Sub(start); bucketElapsed > 0 {
+			b.PeakOccupancy = occupancy
+			for ; next < len(instants) && instants[next].Before(end); next++ {
+				occupancy += t.changes[instants[next]]
+				b.PeakOccupancy = max(b.PeakOccupancy, occupancy)
+			}
+
+			b.AverageOccupancy = ratio(t.occupied[i], bucketElapsed)
+			b.UtilizationPercent = percent(t.occupied[i], bucketElapsed*time.Duration(a.Capacity))
+
+			hour := start.In(t.q.Location).Hour()
+			hourTime[hour] += bucketElapsed
+			hourOccupied[hour] += t.occupied[i]
+		}
+
+		if b.PeakOccupancy > a.PeakOccupancy {
+			a.PeakOccupancy = b.PeakOccupancy
+		}
+
+		a.Hourly[i] = b
+	}
+
+	occupancy = 0
+	for _, at := range instants {
+		if occupancy += t.changes[at]; occupancy > 0 && occupancy == a.PeakOccupancy {
+			peakAt := at
+			a.PeakAt = &peakAt
+			break
+		}
+	}
+
+	for hour := range a.HourOfDay {
+		a.HourOfDay[hour] = HourOfDayOccupancy{Hour: hour, AverageOccupancy: ratio(hourOccupied[hour], hourTime[hour])}
+		if a.HourOfDay[hour].AverageOccupancy > 0 && (a.PeakHour == nil || a.HourOfDay[hour].AverageOccupancy > a.PeakHour.AverageOccupancy) {
+			peak := a.HourOfDay[hour]
+			a.PeakHour = &peak
+		}
+	}
+
+	return a
+}
+
+// newDwellDistribution summarizes the stays, in seconds.
+func newDwellDistribution(stays []int64) DwellDistribution {
+	d := DwellDistribution{Stays: len(stays)}
+	if len(stays) == 0 {
+		return d
+	}
+
+	sort.Slice(stays, func(i, j int) bool { return stays[i] < stays[j] })
+
+	var total int64
+	for _, s := range stays {
+		total += s
+	}
+
+	nearestRank := func(p float64) int64 {
+		return stays[int(math.Ceil(p/100*float64(len(stays))))-1]
+	}
+
+	d.AverageSeconds = total / int64(len(stays))
+	d.P50Seconds, d.P90Seconds, d.P99Seconds = nearestRank(50), nearestRank(90), nearestRank(99)
+	return d
+}
+
+// ratio returns part / whole rounded to 2 decimals, 0 for an empty whole.
+func ratio(part, whole time.Duration) float64 {
+	if whole <= 0 {
+		return 0
+	}
+
+	return round2(float64(part) / float64(whole))
+}
+
+// percent returns part as a percentage of whole rounded to 2 decimals, 0 for an empty whole.
+func percent(part, whole time.Duration) float64 {
+	if whole <= 0 {
+		return 0
+	}
+
+	return round2(float64(part) / float64(whole) * 100)
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+
+	return b
+}
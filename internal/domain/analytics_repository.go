@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// AnalyticsRepository defines the interface for analysing how parking lots are used over time,
+// implemented for the postgresql database by AnalyticsRepoDB and in memory by AnalyticsRepoMemory.
+type AnalyticsRepository interface {
+	GetOccupancyAnalytics(ctx context.Context, plUUID uuid.UUID, q AnalyticsQuery) (*OccupancyAnalytics, common.AppError)
+}
+
+var _ AnalyticsRepository = (*AnalyticsRepoDB)(nil)
+
+type AnalyticsRepoDB struct {
+	db  *sql.DB
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewAnalyticsRepoDB(db *sql.DB, l *slog.Logger) *AnalyticsRepoDB {
+	return &AnalyticsRepoDB{
+		db:  db,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock bounding the stays of vehicles still parked, lets tests control time.
+func (r *AnalyticsRepoDB) WithClock(now func() time.Time) *AnalyticsRepoDB {
+	r.now = now
+	return r
+}
+
+// GetOccupancyAnalytics reconstructs the occupancy of a parking lot over a period in the lot's time zone, streaming the stays
+// overlapping the period from the index on vehicles.parked_at. Returns a 404 Not Found error for unknown parking lots
+// and a 400 Bad Request error for an invalid period.
+func (r *AnalyticsRepoDB) GetOccupancyAnalytics(ctx context.Context, plUUID uuid.UUID, q AnalyticsQuery) (*OccupancyAnalytics, common.AppError) {
+	var plID int
+	var timeZone string
+	err := r.db.QueryRowContext(ctx, "SELECT id, time_zone FROM parking_lots WHERE uuid = $1", plUUID).Scan(&plID, &timeZone)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.l.Error("parking_lots not found", "err", err)
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	} else if err != nil {
+		r.l.Error("error fetching parking lot time zone", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	rq := q.reportQuery(lotLocation(timeZone))
+	if appErr := rq.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	slots, appErr := r.lotSlots(ctx, plID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	timeline := newOccupancyTimeline(rq, slots, r.now())
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT s.uuid, v.parked_at, v.unparked_at
+        FROM vehicles v
+        JOIN slots s ON v.slot_id = s.id
+        WHERE s.parking_lot_id = $1
+          AND v.parked_at < $3
+          AND (v.unparked_at IS NULL OR v.unparked_at > $2)`, plID, rq.From, rq.To)
+	if err != nil {
+		r.l.Error("error fetching stays", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slotUUID uuid.UUID
+		var parkedAt time.Time
+		var unparkedAt *time.Time
+		if err = rows.Scan(&slotUUID, &parkedAt, &unparkedAt); err != nil {
+			r.l.Error("error scanning stay", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		timeline.add(slotUUID, parkedAt, unparkedAt)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating stays", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return timeline.analytics(plUUID, timeZone), nil
+}
+
+// lotSlots returns the slots of a parking lot ordered by slot number, without usage.
+func (r *AnalyticsRepoDB) lotSlots(ctx context.Context, plID int) ([]SlotUtilization, common.AppError) {
+	rows, err := r.db.QueryContext(ctx, "SELECT uuid, slot_number, label FROM slots WHERE parking_lot_id = $1 ORDER BY slot_number", plID)
+	if err != nil {
+		r.l.Error("error fetching slots", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	slots := make([]SlotUtilization, 0)
+	for rows.Next() {
+		var slot SlotUtilization
+		if err = rows.Scan(&slot.SlotID, &slot.SlotNumber, &slot.Label); err != nil {
+			r.l.Error("error scanning slot", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		slots = append(slots, slot)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating slots", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return slots, nil
+}
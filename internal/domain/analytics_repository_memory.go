@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+var _ AnalyticsRepository = (*AnalyticsRepoMemory)(nil)
+
+// AnalyticsRepoMemory implements AnalyticsRepository in memory, with the same semantics as AnalyticsRepoDB.
+type AnalyticsRepoMemory struct {
+	s   *MemoryStore
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewAnalyticsRepoMemory(s *MemoryStore, l *slog.Logger) *AnalyticsRepoMemory {
+	return &AnalyticsRepoMemory{
+		s:   s,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock bounding the stays of vehicles still parked, lets tests control time.
+func (r *AnalyticsRepoMemory) WithClock(now func() time.Time) *AnalyticsRepoMemory {
+	r.now = now
+	return r
+}
+
+// GetOccupancyAnalytics reconstructs the occupancy of a parking lot over a period in the lot's time zone.
+func (r *AnalyticsRepoMemory) GetOccupancyAnalytics(_ context.Context, plUUID uuid.UUID, q AnalyticsQuery) (*OccupancyAnalytics, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	rq := q.reportQuery(lotLocation(lot.timeZone))
+	if appErr := rq.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	slots := make([]SlotUtilization, 0, len(lot.slots))
+	for _, slot := range lot.slots {
+		slots = append(slots, SlotUtilization{SlotID: slot.ID, SlotNumber: slot.SlotNumber, Label: slot.Label})
+	}
+
+	timeline := newOccupancyTimeline(rq, slots, r.now())
+	for _, v := range r.s.lotVehicles(plUUID) {
+		timeline.add(v.SlotID, v.ParkedAt, v.UnparkedAt)
+	}
+
+	return timeline.analytics(plUUID, lot.timeZone), nil
+}
@@ -17,6 +17,13 @@ const (
 	tableVehicles    = "vehicles"
 )
 
+// queryer is implemented by both *sql.DB and *sql.Tx, lets helpers run inside or outside a transaction.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func getIDByUUID(ctx context.Context, db *sql.DB, l *slog.Logger, tableName string, uuid uuid.UUID) (int, common.AppError) {
 	var id int
 
@@ -0,0 +1,191 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ashtishad/gopark/internal/common"
+)
+
+// Layout describes the levels of a parking lot, each one split into zones of slots.
+// Level and zone names can't contain dashes, they're joined with dashes into slot labels.
+type Layout struct {
+	Levels []LevelLayout `json:"levels"`
+}
+
+// LevelLayout is a level of a parking lot, EntranceDistance orders levels for allocation, lower is closer to the entrance.
+type LevelLayout struct {
+	Name             string       `json:"name"`
+	EntranceDistance int          `json:"entranceDistance"`
+	Zones            []ZoneLayout `json:"zones"`
+}
+
+// ZoneLayout is a zone of a level with its slot counts per type.
+type ZoneLayout struct {
+	Name      string              `json:"name"`
+	SlotTypes map[VehicleType]int `json:"slotTypes"`
+}
+
+// LevelStatus groups the slot statuses of a level by zone, levels are ordered by entrance distance.
+type LevelStatus struct {
+	Name             string       `json:"name"`
+	EntranceDistance int          `json:"entranceDistance"`
+	Zones            []ZoneStatus `json:"zones"`
+}
+
+// ZoneStatus lists the slot statuses of a zone.
+type ZoneStatus struct {
+	Name  string       `json:"name"`
+	Slots []SlotStatus `json:"slots"`
+}
+
+// slotSpec describes a slot to create, the slot number is its position in the plan + 1.
+type slotSpec struct {
+	Type  VehicleType
+	Level string
+	Zone  string
+	Label string
+}
+
+// slotPlan returns every slot to create in slot number order and the levels of the layout, validating the request.
+// Sets DesiredSlots to the number of slots. Without a layout there are no levels and slots are labelled by slot number,
+// grouped by type in the order of VehicleTypes, without SlotTypes every slot is a car slot.
+func (lot *ParkingLot) slotPlan() ([]slotSpec, []LevelLayout, common.AppError) {
+	if lot.Layout != nil {
+		if lot.SlotTypes != nil {
+			return nil, nil, common.NewBadRequestError("slot types and layout can't both be set, use slot types of layout zones")
+		}
+
+		return lot.layoutPlan()
+	}
+
+	var types []VehicleType
+	if len(lot.SlotTypes) == 0 {
+		if lot.DesiredSlots < 0 {
+			return nil, nil, common.NewBadRequestError("desired slots can't be negative")
+		}
+
+		for i := 0; i < lot.DesiredSlots; i++ {
+			types = append(types, DefaultVehicleType)
+		}
+	} else {
+		var appErr common.AppError
+		if types, appErr = typesByCount(lot.SlotTypes); appErr != nil {
+			return nil, nil, appErr
+		}
+	}
+
+	plan := make([]slotSpec, 0, len(types))
+	for i, t := range types {
+		plan = append(plan, slotSpec{Type: t, Label: strconv.Itoa(i + 1)})
+	}
+
+	lot.DesiredSlots = len(plan)
+	return plan, nil, nil
+}
+
+// layoutPlan numbers slots level by level and zone by zone in the order of the layout,
+// labels restart at 1 in every zone, eg: "B2-C-14".
+func (lot *ParkingLot) layoutPlan() ([]slotSpec, []LevelLayout, common.AppError) {
+	if len(lot.Layout.Levels) == 0 {
+		return nil, nil, common.NewBadRequestError("layout must have at least one level")
+	}
+
+	var plan []slotSpec
+	levelNames := make(map[string]bool)
+	for _, level := range lot.Layout.Levels {
+		if level.Name == "" || levelNames[level.Name] || strings.Contains(level.Name, "-") {
+			return nil, nil, common.NewBadRequestError("level names must be unique, not empty and without dashes")
+		}
+		levelNames[level.Name] = true
+
+		if level.EntranceDistance < 0 {
+			return nil, nil, common.NewBadRequestError("level entrance distance can't be negative")
+		}
+
+		if len(level.Zones) == 0 {
+			return nil, nil, common.NewBadRequestError(fmt.Sprintf("level %s must have at least one zone", level.Name))
+		}
+
+		zoneNames := make(map[string]bool)
+		for _, zone := range level.Zones {
+			if zone.Name == "" || zoneNames[zone.Name] || strings.Contains(zone.Name, "-") {
+				return nil, nil, common.NewBadRequestError(fmt.Sprintf("zone names of level %s must be unique, not empty and without dashes", level.Name))
+			}
+			zoneNames[zone.Name] = true
+
+			types, appErr := typesByCount(zone.SlotTypes)
+			if appErr != nil {
+				return nil, nil, appErr
+			}
+
+			for i, t := range types {
+				plan = append(plan, slotSpec{
+					Type:  t,
+					Level: level.Name,
+					Zone:  zone.Name,
+					Label: fmt.Sprintf("%s-%s-%d", level.Name, zone.Name, i+1),
+				})
+			}
+		}
+	}
+
+	lot.DesiredSlots = len(plan)
+	return plan, lot.Layout.Levels, nil
+}
+
+// typesByCount expands slot counts per type into a slot type per slot, grouped in the order of VehicleTypes.
+func typesByCount(counts map[VehicleType]int) ([]VehicleType, common.AppError) {
+	for t, count := range counts {
+		if !t.Valid() {
+			return nil, common.NewBadRequestError(fmt.Sprintf("unknown slot type %q", t))
+		}
+
+		if count < 0 {
+			return nil, common.NewBadRequestError("slot counts can't be negative")
+		}
+	}
+
+	var types []VehicleType
+	for _, t := range VehicleTypes {
+		for i := 0; i < counts[t]; i++ {
+			types = append(types, t)
+		}
+	}
+
+	return types, nil
+}
+
+// groupByLevel groups slot statuses, ordered by slot number, into their levels and zones.
+// Levels are ordered by entrance distance, then as in the layout, zones by their first slot.
+// Slots of parking lots without a layout form a single unnamed level and zone.
+func groupByLevel(slots []SlotStatus, levels []LevelLayout) []LevelStatus {
+	grouped := make([]LevelStatus, 0, len(levels))
+	levelIdx := make(map[string]int, len(levels))
+	for _, l := range levels {
+		levelIdx[l.Name] = len(grouped)
+		grouped = append(grouped, LevelStatus{Name: l.Name, EntranceDistance: l.EntranceDistance})
+	}
+
+	for _, slot := range slots {
+		i, ok := levelIdx[slot.Level]
+		if !ok {
+			i = len(grouped)
+			levelIdx[slot.Level] = i
+			grouped = append(grouped, LevelStatus{Name: slot.Level})
+		}
+
+		zones := grouped[i].Zones
+		if len(zones) == 0 || zones[len(zones)-1].Name != slot.Zone {
+			zones = append(zones, ZoneStatus{Name: slot.Zone})
+		}
+
+		zones[len(zones)-1].Slots = append(zones[len(zones)-1].Slots, slot)
+		grouped[i].Zones = zones
+	}
+
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].EntranceDistance < grouped[j].EntranceDistance })
+	return grouped
+}
@@ -0,0 +1,230 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore holds parking lots, slots and vehicles in memory, shared by ParkingLotRepoMemory and VehicleRepositoryMemory.
+// A single mutex guards every collection, so each repository method is atomic like a serializable transaction.
+type MemoryStore struct {
+	mu           sync.Mutex
+	lots         map[uuid.UUID]*memLot
+	slots        map[uuid.UUID]*memSlot
+	vehicles     []*Vehicle
+	maintenance  []*MaintenanceWindow
+	reservations []*Reservation
+	permits      []*Permit
+}
+
+type memLot struct {
+	id            uuid.UUID
+	name          string
+	timeZone      string
+	levels        []LevelLayout // without zones
+	slots         []*memSlot    // ordered by slot number
+	policy        *PricingPolicy
+	strategy      AllocationStrategy
+	lastAllocated int // slot number of the last round-robin allocation
+}
+
+type memSlot struct {
+	Slot
+	lotID          uuid.UUID
+	lastReleasedAt *time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lots:  make(map[uuid.UUID]*memLot),
+		slots: make(map[uuid.UUID]*memSlot),
+	}
+}
+
+// lotByName returns the parking lot with the given name, nil if none exists. Callers must hold mu.
+func (s *MemoryStore) lotByName(name string) *memLot {
+	for _, lot := range s.lots {
+		if lot.name == name {
+			return lot
+		}
+	}
+
+	return nil
+}
+
+// parkedVehicle returns the vehicle most recently parked with the registration number that is still parked, nil if none.
+// Callers must hold mu.
+func (s *MemoryStore) parkedVehicle(regNum string) *Vehicle {
+	for i := len(s.vehicles) - 1; i >= 0; i-- {
+		if v := s.vehicles[i]; v.RegistrationNumber == regNum && v.UnparkedAt == nil {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// parkedVehicleIn returns the vehicle currently parked with the registration number in the parking lot, nil if none.
+// Callers must hold mu.
+func (s *MemoryStore) parkedVehicleIn(plUUID uuid.UUID, regNum string) *Vehicle {
+	for _, v := range s.vehicles {
+		if v.RegistrationNumber == regNum && v.UnparkedAt == nil && s.slots[v.SlotID].lotID == plUUID {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// lotVehicles returns every vehicle parked in the parking lot, parked or unparked. Callers must hold mu.
+func (s *MemoryStore) lotVehicles(plUUID uuid.UUID) []*Vehicle {
+	var vehicles []*Vehicle
+	for _, v := range s.vehicles {
+		if s.slots[v.SlotID].lotID == plUUID {
+			vehicles = append(vehicles, v)
+		}
+	}
+
+	return vehicles
+}
+
+// lotMaintenance returns copies of the maintenance windows of the parking lot's slots, most recently started first,
+// ties broken by ID like ParkingLotRepoDB. Callers must hold mu.
+func (s *MemoryStore) lotMaintenance(plUUID uuid.UUID) []MaintenanceWindow {
+	var windows []MaintenanceWindow
+	for _, w := range s.maintenance {
+		if s.slots[w.SlotID].lotID != plUUID {
+			continue
+		}
+
+		window := *w
+		window.EndedAt = copyTime(w.EndedAt)
+		if w.EndedBy != nil {
+			endedBy := *w.EndedBy
+			window.EndedBy = &endedBy
+		}
+
+		windows = append(windows, window)
+	}
+
+	slices.SortFunc(windows, func(a, b MaintenanceWindow) int {
+		if c := b.StartedAt.Compare(a.StartedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(b.ID.String(), a.ID.String())
+	})
+
+	return windows
+}
+
+// heldReservation returns the reservation holding a slot of the lot for the vehicle of type vType at now, nil if none.
+// Callers must hold mu.
+func (s *MemoryStore) heldReservation(plUUID uuid.UUID, regNum string, vType VehicleType, now time.Time) *Reservation {
+	var held *Reservation
+	for _, r := range s.reservations {
+		if r.ParkingLotID == plUUID && r.RegistrationNumber == regNum && r.heldAt(now, vType) && (held == nil || r.StartsAt.Before(held.StartsAt)) {
+			held = r
+		}
+	}
+
+	return held
+}
+
+// slotHeld reports whether a reservation keeps vehicles parking without it off the slot at now. Callers must hold mu.
+func (s *MemoryStore) slotHeld(slotID uuid.UUID, now time.Time) bool {
+	for _, r := range s.reservations {
+		if r.SlotID == slotID && r.keepsWalkInsAt(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// slotReserved reports whether a reservation keeps the slot at any time between from and until at now. Callers must hold mu.
+func (s *MemoryStore) slotReserved(slotID uuid.UUID, from, until, now time.Time) bool {
+	for _, r := range s.reservations {
+		if r.SlotID == slotID && r.blocks(from, until, now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validPermit returns the permit letting the vehicle park in the lot at t, nil if none. Callers must hold mu.
+func (s *MemoryStore) validPermit(plUUID uuid.UUID, regNum string, t time.Time) *Permit {
+	for _, p := range s.permits {
+		if p.ParkingLotID == plUUID && p.hasPlate(regNum) && p.validAt(t) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// permitEnd returns when the permit with the id stops covering stays, nil without one. Callers must hold mu.
+func (s *MemoryStore) permitEnd(id *uuid.UUID) *time.Time {
+	if id == nil {
+		return nil
+	}
+
+	for _, p := range s.permits {
+		if p.ID == *id {
+			end := p.coverageEnd()
+			return &end
+		}
+	}
+
+	return nil
+}
+
+// slotAssigned reports whether an unrevoked permit assigns the slot at any time between from and until. Callers must hold mu.
+func (s *MemoryStore) slotAssigned(slotID uuid.UUID, from, until time.Time) bool {
+	for _, p := range s.permits {
+		if p.SlotID != nil && *p.SlotID == slotID && p.overlaps(from, until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// slotAssignedAt reports whether a permit valid at t assigns the slot. Callers must hold mu.
+func (s *MemoryStore) slotAssignedAt(slotID uuid.UUID, t time.Time) bool {
+	for _, p := range s.permits {
+		if p.SlotID != nil && *p.SlotID == slotID && p.validAt(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pricingPolicy returns a copy of the lot's pricing policy, the default policy if none is stored. Callers must hold mu.
+func (s *MemoryStore) pricingPolicy(lot *memLot) *PricingPolicy {
+	if lot.policy == nil {
+		return DefaultPricingPolicy(lot.id)
+	}
+
+	return copyPricingPolicy(lot.policy)
+}
+
+// copyPricingPolicy returns a deep copy of p, so the store never shares maps with callers.
+func copyPricingPolicy(p *PricingPolicy) *PricingPolicy {
+	c := *p
+	if p.VehicleTypeMultipliers != nil {
+		c.VehicleTypeMultipliers = make(map[VehicleType]float64, len(p.VehicleTypeMultipliers))
+		for t, m := range p.VehicleTypeMultipliers {
+			c.VehicleTypeMultipliers[t] = m
+		}
+	}
+
+	return &c
+}
@@ -3,38 +3,207 @@ package domain
 import (
 	"time"
 
+	"github.com/ashtishad/gopark/internal/common"
 	"github.com/google/uuid"
 )
 
+// ParkingLot is created with either DesiredSlots car slots, SlotTypes counts of slots per type,
+// or a Layout of levels and zones with slot counts per type. AllocationStrategy defaults to DefaultAllocationStrategy.
+// TimeZone is the IANA time zone reports slice days in, DefaultTimeZone when empty.
 type ParkingLot struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	DesiredSlots int       `json:"desiredSlots"`
-	Slots        []Slot    `json:"slots"`
+	ID                 uuid.UUID           `json:"id"`
+	Name               string              `json:"name"`
+	TimeZone           string              `json:"timeZone"`
+	DesiredSlots       int                 `json:"desiredSlots"`
+	SlotTypes          map[VehicleType]int `json:"slotTypes,omitempty"`
+	Layout             *Layout             `json:"layout,omitempty"`
+	AllocationStrategy AllocationStrategy  `json:"allocationStrategy"`
+	Slots              []Slot              `json:"slots"`
 }
 
+// ParkingLotStatus lists every slot in Slots, and the same slots grouped by level and zone in Levels.
+// Maintenance holds the ongoing maintenance windows only, see ParkingLotRepository.ListMaintenanceWindows for the history.
 type ParkingLotStatus struct {
-	ParkingLotID uuid.UUID    `json:"parkingLotId"`
-	Name         string       `json:"name"`
-	Slots        []SlotStatus `json:"slots"`
+	ParkingLotID uuid.UUID           `json:"parkingLotId"`
+	Name         string              `json:"name"`
+	TimeZone     string              `json:"timeZone"`
+	Slots        []SlotStatus        `json:"slots"`
+	Levels       []LevelStatus       `json:"levels"`
+	Maintenance  []MaintenanceWindow `json:"maintenance"`
 }
 
+// InTimeZone renders every timestamp of the status in the parking lot's time zone instead of UTC.
+func (s *ParkingLotStatus) InTimeZone() {
+	loc := lotLocation(s.TimeZone)
+	for i := range s.Slots {
+		timeIn(s.Slots[i].ParkedAt, loc)
+		timeIn(s.Slots[i].UnparkedAt, loc)
+	}
+
+	// slots grouped by level share their timestamps with Slots, rendering them again is a no-op.
+	for _, level := range s.Levels {
+		for _, zone := range level.Zones {
+			for i := range zone.Slots {
+				timeIn(zone.Slots[i].ParkedAt, loc)
+				timeIn(zone.Slots[i].UnparkedAt, loc)
+			}
+		}
+	}
+
+	for i := range s.Maintenance {
+		timeIn(&s.Maintenance[i].StartedAt, loc)
+		timeIn(s.Maintenance[i].EndedAt, loc)
+	}
+}
+
+// Slot is a single parking space, Level and Zone are empty for parking lots created without a layout.
+// Label identifies a slot to drivers, eg: "B2-C-14" for slot 14 of zone C on level B2, or the slot number without a layout.
 type Slot struct {
-	ID            uuid.UUID `json:"id"`
-	SlotNumber    int       `json:"slotNumber"`
-	IsAvailable   bool      `json:"isAvailable"`
-	IsMaintenance bool      `json:"isMaintenance"`
+	ID            uuid.UUID   `json:"id"`
+	SlotNumber    int         `json:"slotNumber"`
+	Label         string      `json:"label"`
+	Level         string      `json:"level,omitempty"`
+	Zone          string      `json:"zone,omitempty"`
+	Type          VehicleType `json:"type"`
+	IsAvailable   bool        `json:"isAvailable"`
+	IsMaintenance bool        `json:"isMaintenance"`
 }
 
 type SlotStatus struct {
-	SlotID          uuid.UUID  `json:"slotId"`
-	RegistrationNum *string    `json:"registrationNumber"`
-	ParkedAt        *time.Time `json:"parkedAt"`
-	UnparkedAt      *time.Time `json:"unparkedAt"`
+	SlotID             uuid.UUID   `json:"slotId"`
+	SlotLabel          string      `json:"slotLabel"`
+	Level              string      `json:"level,omitempty"`
+	Zone               string      `json:"zone,omitempty"`
+	SlotType           VehicleType `json:"slotType"`
+	RegistrationNum    *string     `json:"registrationNumber"`
+	RawRegistrationNum *string     `json:"rawRegistrationNumber"` // as entered on park, canonical for vehicles parked before it was stored
+	ParkedAt           *time.Time  `json:"parkedAt"`
+	UnparkedAt         *time.Time  `json:"unparkedAt"`
 }
 
+// DailyReport totals the parking of a day in the parking lot's time zone, split between stays under a permit and transient ones.
 type DailyReport struct {
-	TotalVehiclesParked *int `json:"totalVehiclesParked"`
-	TotalParkingHours   *int `json:"totalParkingHours"`
-	TotalFeeCollected   *int `json:"totalFeeCollected"`
+	TotalVehiclesParked int         `json:"totalVehiclesParked"`
+	TotalParkingHours   int         `json:"totalParkingHours"`
+	TotalFeeCollected   int         `json:"totalFeeCollected"`
+	Permit              UsageReport `json:"permit"`
+	Transient           UsageReport `json:"transient"`
+}
+
+// total sums the permit and transient usage into the report totals.
+func (r *DailyReport) total() {
+	r.TotalVehiclesParked = r.Permit.VehiclesParked + r.Transient.VehiclesParked
+	r.TotalParkingHours = r.Permit.ParkingHours + r.Transient.ParkingHours
+	r.TotalFeeCollected = r.Permit.FeeCollected + r.Transient.FeeCollected
+}
+
+// UsageReport totals the parking of one kind of stay.
+type UsageReport struct {
+	VehiclesParked int `json:"vehiclesParked"`
+	ParkingHours   int `json:"parkingHours"`
+	FeeCollected   int `json:"feeCollected"`
+}
+
+// LotOccupancy counts the slots of a parking lot by state. A slot forced into maintenance while occupied
+// counts as both occupied and maintenance, available slots are free and not under maintenance.
+type LotOccupancy struct {
+	ParkingLotID uuid.UUID
+	Name         string
+	Occupied     int
+	Available    int
+	Maintenance  int
+}
+
+// MaintenanceChange describes a request to move a slot into or out of maintenance.
+// Force allows an occupied slot to enter maintenance, the parked vehicle stays until unparked.
+type MaintenanceChange struct {
+	InMaintenance bool
+	Force         bool
+	PerformedBy   string
+	Reason        string
+}
+
+// forced reports whether entering maintenance overrides an occupied slot, available tells whether the slot is free.
+func (c MaintenanceChange) forced(available bool) bool {
+	return c.InMaintenance && c.Force && !available
+}
+
+// MaintenanceWindow is a single maintenance period of a slot, EndedAt is nil while it's ongoing.
+// Forced tells it was started on an occupied slot with MaintenanceChange.Force.
+type MaintenanceWindow struct {
+	ID         uuid.UUID  `json:"id"`
+	SlotID     uuid.UUID  `json:"slotId"`
+	SlotNumber int        `json:"slotNumber"`
+	Reason     string     `json:"reason"`
+	Forced     bool       `json:"forced"`
+	StartedBy  string     `json:"startedBy"`
+	StartedAt  time.Time  `json:"startedAt"`
+	EndedBy    *string    `json:"endedBy"`
+	EndedAt    *time.Time `json:"endedAt"`
+}
+
+const (
+	DefaultMaintenanceLimit = 50
+	MaxMaintenanceLimit     = 200
+)
+
+// MaintenanceQuery pages the maintenance history of a parking lot, newest window first.
+// Cursor is the NextCursor of the previous page, empty for the first page.
+type MaintenanceQuery struct {
+	Cursor string
+	Limit  int
+}
+
+// MaintenancePage is a page of maintenance windows, NextCursor is empty on the last page. TimeZone is the parking lot's.
+type MaintenancePage struct {
+	TimeZone   string              `json:"timeZone"`
+	Windows    []MaintenanceWindow `json:"windows"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+// InTimeZone renders the start and end of every window in the parking lot's time zone instead of UTC.
+func (p *MaintenancePage) InTimeZone() {
+	loc := lotLocation(p.TimeZone)
+	for i := range p.Windows {
+		timeIn(&p.Windows[i].StartedAt, loc)
+		timeIn(p.Windows[i].EndedAt, loc)
+	}
+}
+
+// normalize applies the default limit and decodes the cursor, nil for the first page.
+// Cursors have the session cursor's form, the start time and ID of the last window of a page.
+func (q *MaintenanceQuery) normalize() (*sessionCursor, common.AppError) {
+	if q.Limit == 0 {
+		q.Limit = DefaultMaintenanceLimit
+	}
+
+	if q.Limit < 0 || q.Limit > MaxMaintenanceLimit {
+		return nil, common.NewBadRequestError("limit must be between 1 and 200")
+	}
+
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	cursor, err := decodeSessionCursor(q.Cursor)
+	if err != nil {
+		return nil, common.NewBadRequestError("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// newMaintenancePage returns the first limit windows, and the cursor of the last one if more windows follow.
+// windows holds up to limit+1 windows, the extra one tells whether there's a next page.
+func newMaintenancePage(timeZone string, windows []MaintenanceWindow, limit int) *MaintenancePage {
+	if len(windows) <= limit {
+		return &MaintenancePage{TimeZone: timeZone, Windows: windows}
+	}
+
+	last := windows[limit-1]
+	return &MaintenancePage{
+		TimeZone:   timeZone,
+		Windows:    windows[:limit],
+		NextCursor: (&sessionCursor{parkedAt: last.StartedAt, id: last.ID}).encode(),
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -11,66 +12,95 @@ import (
 	"github.com/google/uuid"
 )
 
-// ParkingLotRepository defines the interface for interacting with parking lot data in the postgresql database.
+// ParkingLotRepository defines the interface for interacting with parking lot data,
+// implemented for the postgresql database by ParkingLotRepoDB and in memory by ParkingLotRepoMemory.
 type ParkingLotRepository interface {
 	CreateParkingLot(ctx context.Context, lot *ParkingLot) (*ParkingLot, common.AppError)
 	GetParkingLotStatus(ctx context.Context, plUUID uuid.UUID) (*ParkingLotStatus, common.AppError)
-	GetDailyReport(ctx context.Context, parkingLotID uuid.UUID, dateString string) (*DailyReport, common.AppError)
+	GetDailyReport(ctx context.Context, plUUID uuid.UUID, reportDate time.Time) (*DailyReport, common.AppError)
+	GetRangeReport(ctx context.Context, plUUID uuid.UUID, q ReportQuery) (*RangeReport, common.AppError)
+	GetPortfolioReport(ctx context.Context, q ReportQuery) (*PortfolioReport, common.AppError)
+	SetSlotMaintenance(ctx context.Context, plUUID uuid.UUID, slotUUID uuid.UUID, change MaintenanceChange) (*Slot, common.AppError)
+	ListMaintenanceWindows(ctx context.Context, plUUID uuid.UUID, q MaintenanceQuery) (*MaintenancePage, common.AppError)
+	GetPricingPolicy(ctx context.Context, plUUID uuid.UUID) (*PricingPolicy, common.AppError)
+	SetPricingPolicy(ctx context.Context, plUUID uuid.UUID, policy *PricingPolicy) (*PricingPolicy, common.AppError)
+	GetOccupancy(ctx context.Context) ([]LotOccupancy, common.AppError)
+	GetAllocationStrategy(ctx context.Context, plUUID uuid.UUID) (AllocationStrategy, common.AppError)
+	SetAllocationStrategy(ctx context.Context, plUUID uuid.UUID, strategy AllocationStrategy) common.AppError
 }
 
+var _ ParkingLotRepository = (*ParkingLotRepoDB)(nil)
+
 type ParkingLotRepoDB struct {
-	db *sql.DB
-	l  *slog.Logger
+	db  *sql.DB
+	l   *slog.Logger
+	now func() time.Time
 }
 
 func NewParkingLotRepoDB(db *sql.DB, l *slog.Logger) *ParkingLotRepoDB {
 	return &ParkingLotRepoDB{
-		db: db,
-		l:  l,
+		db:  db,
+		l:   l,
+		now: time.Now,
 	}
 }
 
+// WithClock replaces the clock used for timestamps, lets tests control time.
+func (r *ParkingLotRepoDB) WithClock(now func() time.Time) *ParkingLotRepoDB {
+	r.now = now
+	return r
+}
+
 // CreateParkingLot performs the following within a serializable transaction to ensure consistency:
 // 1. Verifies uniqueness of the parking lot name to prevent duplicates (returning a 409 Conflict error if a duplicate exists).
 // 2. Inserts the new parking lot record into the database.
-// 3. Creates multiple slots associated with the parking lot, using incrementing slot numbers.
-// 4. Returns a 500 Internal Server Error if unexpected database errors occur during the process.
+// 3. Creates the levels of its layout, if any.
+// 4. Creates multiple slots associated with the parking lot, using incrementing slot numbers, level by level and zone by zone,
+// grouped by slot type.
+// 5. Returns a 400 Bad Request error for an invalid layout, unknown slot types, negative counts, an unknown allocation strategy
+// or time zone.
+// 6. Returns a 500 Internal Server Error if unexpected database errors occur during the process.
 func (r *ParkingLotRepoDB) CreateParkingLot(ctx context.Context, lot *ParkingLot) (*ParkingLot, common.AppError) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
-	if err != nil {
-		r.l.Error(common.ErrTXBegin, "err", err, "src", "CreateParkingLot")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	if appErr := lot.parseLotAllocationStrategy(); appErr != nil {
+		return nil, appErr
 	}
 
-	defer func() {
-		if err != nil {
-			txErr := tx.Rollback()
-			if txErr != nil {
-				r.l.Error(common.ErrTXRollback, "err", txErr, "src", "CreateParkingLot")
-			}
-		}
-	}()
+	if appErr := lot.parseLotTimeZone(); appErr != nil {
+		return nil, appErr
+	}
 
-	if appErr := r.parkingLotExistsByName(ctx, tx, lot.Name); appErr != nil {
+	plan, levels, appErr := lot.slotPlan()
+	if appErr != nil {
 		return nil, appErr
 	}
 
 	var plUUID uuid.UUID
-	var plID int
-	err = tx.QueryRowContext(ctx, "INSERT INTO parking_lots (name) VALUES ($1) RETURNING id, uuid;", lot.Name).Scan(&plID, &plUUID)
-	if err != nil {
-		r.l.Error("error creating parking lot", "err", err)
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
-	}
+	var slots []Slot
 
-	slots, csErr := r.createSlots(ctx, tx, plID, lot.DesiredSlots)
-	if err != nil {
-		return nil, csErr
-	}
+	appErr = withSerializableTx(ctx, r.db, r.l, "CreateParkingLot", func(tx *sql.Tx) common.AppError {
+		if appErr := r.parkingLotExistsByName(ctx, tx, lot.Name); appErr != nil {
+			return appErr
+		}
+
+		var plID int
+		err := tx.QueryRowContext(ctx, "INSERT INTO parking_lots (name, allocation_strategy, time_zone) VALUES ($1, $2, $3) RETURNING id, uuid;",
+			lot.Name, lot.AllocationStrategy, lot.TimeZone).Scan(&plID, &plUUID)
+		if err != nil {
+			r.l.Error("error creating parking lot", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
 
-	if cmtErr := tx.Commit(); cmtErr != nil {
-		r.l.Error(common.ErrTxCommit, "err", err, "src", "CreateParkingLot")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, cmtErr)
+		levelIDs, clErr := r.createLevels(ctx, tx, plID, levels)
+		if clErr != nil {
+			return clErr
+		}
+
+		var csErr common.AppError
+		slots, csErr = r.createSlots(ctx, tx, plID, plan, levelIDs)
+		return csErr
+	})
+	if appErr != nil {
+		return nil, appErr
 	}
 
 	lot.Slots = slots
@@ -95,11 +125,31 @@ func (r *ParkingLotRepoDB) parkingLotExistsByName(ctx context.Context, tx *sql.T
 	return nil
 }
 
-// createSlots inserts bulk amount of slots and returns error if exists.
-func (r *ParkingLotRepoDB) createSlots(ctx context.Context, tx *sql.Tx, lotID int, numSlots int) ([]Slot, common.AppError) {
+// createLevels inserts the levels of a layout, returns their IDs by name.
+func (r *ParkingLotRepoDB) createLevels(ctx context.Context, tx *sql.Tx, lotID int, levels []LevelLayout) (map[string]int, common.AppError) {
+	levelIDs := make(map[string]int, len(levels))
+	for _, level := range levels {
+		var levelID int
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO parking_levels (parking_lot_id, name, entrance_distance)
+            VALUES ($1, $2, $3)
+            RETURNING id`, lotID, level.Name, level.EntranceDistance).Scan(&levelID)
+		if err != nil {
+			r.l.Error("error creating parking level", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		levelIDs[level.Name] = levelID
+	}
+
+	return levelIDs, nil
+}
+
+// createSlots inserts every slot of the plan, numbered by their position, and returns error if exists.
+func (r *ParkingLotRepoDB) createSlots(ctx context.Context, tx *sql.Tx, lotID int, plan []slotSpec, levelIDs map[string]int) ([]Slot, common.AppError) {
 	stmt, err := tx.PrepareContext(ctx, `
-        INSERT INTO slots (parking_lot_id, slot_number) 
-        VALUES ($1, $2)
+        INSERT INTO slots (parking_lot_id, slot_number, slot_type, level_id, zone, label) 
+        VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING uuid
     `)
 	if err != nil {
@@ -109,10 +159,16 @@ func (r *ParkingLotRepoDB) createSlots(ctx context.Context, tx *sql.Tx, lotID in
 
 	defer stmt.Close()
 
-	createdSlots := make([]Slot, 0, numSlots)
-	for i := 1; i <= numSlots; i++ {
+	createdSlots := make([]Slot, 0, len(plan))
+	for idx, spec := range plan {
+		i := idx + 1
+		var levelID *int
+		if id, ok := levelIDs[spec.Level]; ok {
+			levelID = &id
+		}
+
 		var slotUUID uuid.UUID
-		execErr := stmt.QueryRowContext(ctx, lotID, i).Scan(&slotUUID)
+		execErr := stmt.QueryRowContext(ctx, lotID, i, spec.Type, levelID, spec.Zone, spec.Label).Scan(&slotUUID)
 		if execErr != nil {
 			r.l.Error("error creating slots", "err", execErr)
 			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, execErr)
@@ -121,6 +177,10 @@ func (r *ParkingLotRepoDB) createSlots(ctx context.Context, tx *sql.Tx, lotID in
 		createdSlots = append(createdSlots, Slot{
 			ID:            slotUUID,
 			SlotNumber:    i,
+			Label:         spec.Label,
+			Level:         spec.Level,
+			Zone:          spec.Zone,
+			Type:          spec.Type,
 			IsAvailable:   true,
 			IsMaintenance: false,
 		})
@@ -130,7 +190,7 @@ func (r *ParkingLotRepoDB) createSlots(ctx context.Context, tx *sql.Tx, lotID in
 }
 
 // GetParkingLotStatus retrieves the current status of a parking lot, including the name of the
-// parking lot and the status of each slot. This information is essential for parking managers
+// parking lot and the status of each slot, also grouped by level and zone. This information is essential for parking managers
 // to monitor occupancy and identify available parking spaces, returns errors if exists.
 func (r *ParkingLotRepoDB) GetParkingLotStatus(ctx context.Context, plUUID uuid.UUID) (*ParkingLotStatus, common.AppError) {
 	plID, apiErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
@@ -138,11 +198,11 @@ func (r *ParkingLotRepoDB) GetParkingLotStatus(ctx context.Context, plUUID uuid.
 		return nil, apiErr
 	}
 
-	var parkingLotName string
+	var parkingLotName, timeZone string
 	var slots []SlotStatus
 
 	if err := r.db.QueryRowContext(ctx, `
-        SELECT name FROM parking_lots WHERE id = $1`, plID).Scan(&parkingLotName); err != nil {
+        SELECT name, time_zone FROM parking_lots WHERE id = $1`, plID).Scan(&parkingLotName, &timeZone); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, common.NewNotFoundError("parking lot not found")
 		} else if err != nil {
@@ -152,10 +212,13 @@ func (r *ParkingLotRepoDB) GetParkingLotStatus(ctx context.Context, plUUID uuid.
 	}
 
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT s.uuid, v.registration_number, v.parked_at, v.unparked_at
+        SELECT s.uuid, s.label, COALESCE(lv.name, ''), s.zone, s.slot_type, v.registration_number,
+               COALESCE(v.registration_number_raw, v.registration_number), v.parked_at, v.unparked_at
         FROM slots s
+        LEFT JOIN parking_levels lv ON s.level_id = lv.id
         LEFT JOIN vehicles v ON v.slot_id = s.id
-        WHERE s.parking_lot_id = $1`, plID)
+        WHERE s.parking_lot_id = $1
+        ORDER BY s.slot_number, v.parked_at`, plID)
 	if err != nil {
 		r.l.Error("unable to get slot info", "err", err)
 		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
@@ -164,7 +227,8 @@ func (r *ParkingLotRepoDB) GetParkingLotStatus(ctx context.Context, plUUID uuid.
 
 	for rows.Next() {
 		var slot SlotStatus
-		if scnErr := rows.Scan(&slot.SlotID, &slot.RegistrationNum, &slot.ParkedAt, &slot.UnparkedAt); scnErr != nil {
+		if scnErr := rows.Scan(&slot.SlotID, &slot.SlotLabel, &slot.Level, &slot.Zone, &slot.SlotType, &slot.RegistrationNum,
+			&slot.RawRegistrationNum, &slot.ParkedAt, &slot.UnparkedAt); scnErr != nil {
 			r.l.Error("unable to scan slot info", "err", scnErr)
 			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, scnErr)
 		}
@@ -172,53 +236,461 @@ func (r *ParkingLotRepoDB) GetParkingLotStatus(ctx context.Context, plUUID uuid.
 		slots = append(slots, slot)
 	}
 
+	levels, appErr := r.getLevels(ctx, plID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	maintenance, appErr := r.getMaintenanceWindows(ctx, plID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
 	return &ParkingLotStatus{
 		ParkingLotID: plUUID,
 		Name:         parkingLotName,
+		TimeZone:     timeZone,
 		Slots:        slots,
+		Levels:       groupByLevel(slots, levels),
+		Maintenance:  maintenance,
 	}, nil
 }
 
+// getLevels lists the levels of a parking lot in layout order, without zones.
+func (r *ParkingLotRepoDB) getLevels(ctx context.Context, plID int) ([]LevelLayout, common.AppError) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT name, entrance_distance FROM parking_levels
+        WHERE parking_lot_id = $1
+        ORDER BY id`, plID)
+	if err != nil {
+		r.l.Error("unable to get parking levels", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	var levels []LevelLayout
+	for rows.Next() {
+		var level LevelLayout
+		if scnErr := rows.Scan(&level.Name, &level.EntranceDistance); scnErr != nil {
+			r.l.Error("unable to scan parking level", "err", scnErr)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, scnErr)
+		}
+
+		levels = append(levels, level)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("unable to iterate parking levels", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return levels, nil
+}
+
+// GetOccupancy counts occupied, available and maintenance slots of every parking lot, ordered by name.
+func (r *ParkingLotRepoDB) GetOccupancy(ctx context.Context) ([]LotOccupancy, common.AppError) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT pl.uuid, pl.name,
+               COUNT(s.id) FILTER (WHERE NOT s.is_available),
+               COUNT(s.id) FILTER (WHERE s.is_available AND NOT s.is_maintenance),
+               COUNT(s.id) FILTER (WHERE s.is_maintenance)
+        FROM parking_lots pl
+        LEFT JOIN slots s ON s.parking_lot_id = pl.id
+        GROUP BY pl.id
+        ORDER BY pl.name`)
+	if err != nil {
+		r.l.Error("unable to get occupancy", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	occupancy := make([]LotOccupancy, 0)
+	for rows.Next() {
+		var o LotOccupancy
+		if scnErr := rows.Scan(&o.ParkingLotID, &o.Name, &o.Occupied, &o.Available, &o.Maintenance); scnErr != nil {
+			r.l.Error("unable to scan occupancy", "err", scnErr)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, scnErr)
+		}
+
+		occupancy = append(occupancy, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("unable to iterate occupancy", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return occupancy, nil
+}
+
+// getMaintenanceWindows lists the ongoing maintenance windows of the parking lot's slots, most recently started first.
+func (r *ParkingLotRepoDB) getMaintenanceWindows(ctx context.Context, plID int) ([]MaintenanceWindow, common.AppError) {
+	windows := make([]MaintenanceWindow, 0)
+	appErr := r.queryMaintenanceWindows(ctx, `
+        WHERE s.parking_lot_id = $1 AND m.ended_at IS NULL
+        ORDER BY m.started_at DESC, m.uuid DESC`, func(w MaintenanceWindow) { windows = append(windows, w) }, plID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return windows, nil
+}
+
+// ListMaintenanceWindows pages the maintenance history of a parking lot, ongoing and ended windows, newest started first.
+// Each page continues strictly after the cursor's window, start times never change so pages stay stable.
+// Returns a 404 Not Found error for unknown parking lots, 400 Bad Request for an invalid limit or cursor.
+func (r *ParkingLotRepoDB) ListMaintenanceWindows(ctx context.Context, plUUID uuid.UUID, q MaintenanceQuery) (*MaintenancePage, common.AppError) {
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	plID, timeZone, appErr := r.getLotTimeZone(ctx, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var cursorStartedAt *time.Time
+	var cursorID *uuid.UUID
+	if cursor != nil {
+		cursorStartedAt, cursorID = &cursor.parkedAt, &cursor.id
+	}
+
+	windows := make([]MaintenanceWindow, 0, q.Limit+1)
+	appErr = r.queryMaintenanceWindows(ctx, `
+        WHERE s.parking_lot_id = $1
+          AND ($2::timestamptz IS NULL OR (m.started_at, m.uuid) < ($2::timestamptz, $3::uuid))
+        ORDER BY m.started_at DESC, m.uuid DESC
+        LIMIT $4`, func(w MaintenanceWindow) { windows = append(windows, w) }, plID, cursorStartedAt, cursorID, q.Limit+1)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return newMaintenancePage(timeZone, windows, q.Limit), nil
+}
+
+// queryMaintenanceWindows calls fn with every maintenance window matched by the where and order clauses, a constant.
+func (r *ParkingLotRepoDB) queryMaintenanceWindows(ctx context.Context, where string, fn func(MaintenanceWindow), args ...any) common.AppError {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT m.uuid, s.uuid, s.slot_number, m.reason, m.forced, m.started_by, m.started_at, m.ended_by, m.ended_at
+        FROM slot_maintenance_logs m
+        JOIN slots s ON m.slot_id = s.id`+where, args...)
+	if err != nil {
+		r.l.Error("unable to get maintenance windows", "err", err)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w MaintenanceWindow
+		if scnErr := rows.Scan(&w.ID, &w.SlotID, &w.SlotNumber, &w.Reason, &w.Forced, &w.StartedBy, &w.StartedAt, &w.EndedBy,
+			&w.EndedAt); scnErr != nil {
+			r.l.Error("unable to scan maintenance window", "err", scnErr)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, scnErr)
+		}
+
+		fn(w)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating maintenance windows", "err", err)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// SetSlotMaintenance performs the following within a serializable transaction to ensure consistency:
+// 1. Locks the slot, which must belong to the given parking lot (404 Not Found otherwise).
+// 2. Returns a 409 Conflict error if the slot is already in the requested maintenance state.
+// 3. Refuses to put an occupied slot into maintenance with a 409 Conflict error, unless change.Force is set.
+// 4. Opens a maintenance window on enter, closes the ongoing one on exit, recording who, why, when and whether force overrode
+// an occupied slot.
+// 5. Returns a 500 Internal Server Error if unexpected database errors occur during the process.
+func (r *ParkingLotRepoDB) SetSlotMaintenance(ctx context.Context, plUUID uuid.UUID, slotUUID uuid.UUID, change MaintenanceChange) (*Slot, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var slot Slot
+	appErr = withSerializableTx(ctx, r.db, r.l, "SetSlotMaintenance", func(tx *sql.Tx) common.AppError {
+		var slotID int
+		slot = Slot{ID: slotUUID}
+		err := tx.QueryRowContext(ctx, `
+            SELECT s.id, s.slot_number, s.label, COALESCE(lv.name, ''), s.zone, s.slot_type, s.is_available, s.is_maintenance
+            FROM slots s
+            LEFT JOIN parking_levels lv ON s.level_id = lv.id
+            WHERE s.uuid = $1 AND s.parking_lot_id = $2
+            FOR UPDATE OF s`, slotUUID, plID).Scan(&slotID, &slot.SlotNumber, &slot.Label, &slot.Level, &slot.Zone, &slot.Type,
+			&slot.IsAvailable, &slot.IsMaintenance)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.l.Error("slot not found in parking lot", "slot", slotUUID, "parking_lot_id", plID)
+			return common.NewNotFoundError("slot not found in this parking lot")
+		} else if err != nil {
+			r.l.Error("error finding slot", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		switch {
+		case change.InMaintenance && slot.IsMaintenance:
+			return common.NewConflictError("slot is already under maintenance")
+		case !change.InMaintenance && !slot.IsMaintenance:
+			return common.NewConflictError("slot is not under maintenance")
+		case change.InMaintenance && !slot.IsAvailable && !change.Force:
+			return common.NewConflictError("slot is occupied, use force to put it under maintenance")
+		}
+
+		now := r.now().UTC()
+		if change.InMaintenance {
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO slot_maintenance_logs (slot_id, reason, forced, started_by, started_at)
+                VALUES ($1, $2, $3, $4, $5)`, slotID, change.Reason, change.forced(slot.IsAvailable), change.PerformedBy, now)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+                UPDATE slot_maintenance_logs SET ended_by = $1, ended_at = $2
+                WHERE slot_id = $3 AND ended_at IS NULL`, change.PerformedBy, now, slotID)
+		}
+
+		if err != nil {
+			r.l.Error("error recording slot maintenance", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE slots SET is_maintenance = $1 WHERE id = $2", change.InMaintenance, slotID); err != nil {
+			r.l.Error("error updating slot maintenance status", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		return nil
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	r.l.Info("slot maintenance status changed", "slot number", slot.SlotNumber, "maintenance", change.InMaintenance,
+		"by", change.PerformedBy, "forced", change.forced(slot.IsAvailable))
+
+	slot.IsMaintenance = change.InMaintenance
+	return &slot, nil
+}
+
 // GetDailyReport generates a report summarizing parking activity for a specific parking lot on a given date.
 // This includes the total number of vehicles parked, total parking hours (rounded up), and total fees collected.
 // The report is essential for parking lot managers to analyze usage and revenue.
 // Query Explanation:
-// 1. Calculates the total vehicles parked using COUNT(*).
+// 1. Calculates the total vehicles parked using COUNT(*), including the ones still parked.
 // 2. Calculates total parking hours by summing durations (in seconds) after applying CEIL to round up to the nearest hour.
-// 3. Calculates total fees by multiplying the rounded parking hours with the hourly rate (10).
+// 3. Sums the fees stored on unpark, so pricing policy changes never alter historical revenue.
+// 4. Splits each total between stays under a permit and transient ones with FILTER on the vehicle's permit.
+// The day is the calendar date of reportDate in the parking lot's time zone, 23 or 25 hours long across DST transitions.
 func (r *ParkingLotRepoDB) GetDailyReport(ctx context.Context, plUUID uuid.UUID, reportDate time.Time) (*DailyReport, common.AppError) {
-	startDate := reportDate
-	endDate := reportDate.AddDate(0, 0, 1)
-
-	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	plID, timeZone, appErr := r.getLotTimeZone(ctx, plUUID)
 	if appErr != nil {
 		return nil, appErr
 	}
 
+	startDate, endDate := localDay(reportDate, lotLocation(timeZone))
+
 	var report DailyReport
 	sqlDailyReport := `
-   SELECT 
-       COUNT(*) as total_vehicles_parked,
-       SUM(CEIL(EXTRACT(EPOCH FROM (v.unparked_at - v.parked_at))/3600)) as total_parking_hours, -- Ceil parking duration
-       SUM(CEIL(EXTRACT(EPOCH FROM (v.unparked_at - v.parked_at))/3600) * 10)::int as total_fee_collected
-   FROM vehicles v
-   JOIN slots s ON v.slot_id = s.id
-   JOIN parking_lots pl ON s.parking_lot_id = pl.id
-   WHERE pl.id = $1 
-    AND v.parked_at >= $2 AND v.parked_at < $3 
+   WITH stays AS (
+       SELECT v.permit_id IS NOT NULL as is_permit,
+              CEIL(EXTRACT(EPOCH FROM (v.unparked_at - v.parked_at))/3600) as hours, -- Ceil parking duration
+              v.fee
+       FROM vehicles v
+       JOIN slots s ON v.slot_id = s.id
+       WHERE s.parking_lot_id = $1
+        AND v.parked_at >= $2 AND v.parked_at < $3
+   )
+   SELECT
+       COUNT(*) FILTER (WHERE is_permit) as permit_vehicles_parked,
+       COALESCE(SUM(hours) FILTER (WHERE is_permit), 0)::int as permit_parking_hours,
+       COALESCE(SUM(fee) FILTER (WHERE is_permit), 0)::int as permit_fee_collected,
+       COUNT(*) FILTER (WHERE NOT is_permit) as transient_vehicles_parked,
+       COALESCE(SUM(hours) FILTER (WHERE NOT is_permit), 0)::int as transient_parking_hours,
+       COALESCE(SUM(fee) FILTER (WHERE NOT is_permit), 0)::int as transient_fee_collected
+   FROM stays
 `
 	err := r.db.QueryRowContext(ctx, sqlDailyReport, plID, startDate, endDate).Scan(
-		&report.TotalVehiclesParked,
-		&report.TotalParkingHours,
-		&report.TotalFeeCollected)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		r.l.Error("no records found for this date or parking lot", "err", err)
-		return nil, common.NewNotFoundError("no records found for this date or parking lot")
-	} else if err != nil {
+		&report.Permit.VehiclesParked,
+		&report.Permit.ParkingHours,
+		&report.Permit.FeeCollected,
+		&report.Transient.VehiclesParked,
+		&report.Transient.ParkingHours,
+		&report.Transient.FeeCollected)
+	if err != nil {
 		r.l.Error("error generating daily report", "err", err)
 		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
 	}
 
+	report.total()
+
 	return &report, nil
 }
+
+// GetRangeReport returns the usage of a parking lot over a period as a time series in the lot's time zone, see rangeUsage.
+// Returns a 400 Bad Request error for an invalid period or granularity, 404 Not Found for unknown parking lots.
+func (r *ParkingLotRepoDB) GetRangeReport(ctx context.Context, plUUID uuid.UUID, q ReportQuery) (*RangeReport, common.AppError) {
+	plID, timeZone, appErr := r.getLotTimeZone(ctx, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	q.Location = lotLocation(timeZone)
+	if appErr = q.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	usage, appErr := r.rangeUsage(ctx, q, "(s.parking_lot_id)", "s.parking_lot_id = $4", plID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	report := RangeReport{ParkingLotID: plUUID, TimeZone: timeZone, From: q.From.UTC(), To: q.To.UTC(), Granularity: q.Granularity}
+	report.Buckets, report.Total = newReportSeries(q, usage[plID])
+	return &report, nil
+}
+
+// GetPortfolioReport returns the usage of every parking lot combined over a period as a time series in the query's time zone, and the totals
+// of each lot ordered by name, from a single pass of rangeUsage that aggregates each stay for its lot and for the portfolio.
+// Returns a 400 Bad Request error for an invalid period or granularity.
+func (r *ParkingLotRepoDB) GetPortfolioReport(ctx context.Context, q ReportQuery) (*PortfolioReport, common.AppError) {
+	if appErr := q.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	// lot id 0 is the portfolio, serial ids start at 1.
+	usage, appErr := r.rangeUsage(ctx, q, "(s.parking_lot_id), (0)", "TRUE")
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, uuid, name FROM parking_lots ORDER BY name")
+	if err != nil {
+		r.l.Error("unable to get parking lots", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	report := PortfolioReport{TimeZone: q.Location.String(), From: q.From.UTC(), To: q.To.UTC(), Granularity: q.Granularity, Lots: make([]LotReport, 0)}
+	for rows.Next() {
+		var plID int
+		var lot LotReport
+		if err = rows.Scan(&plID, &lot.ParkingLotID, &lot.Name); err != nil {
+			r.l.Error("unable to scan parking lot", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		_, lot.Total = newReportSeries(q, usage[plID])
+		report.Lots = append(report.Lots, lot)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("unable to iterate parking lots", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	report.Buckets, report.Total = newReportSeries(q, usage[0])
+	return &report, nil
+}
+
+// rangeUsage aggregates the stays overlapping the query period by parking lot id and bucket, only buckets with activity are returned.
+// lots is a constant VALUES list of the lot ids each stay counts for, filter a constant condition on the stays, on $4 if any.
+// Query Explanation:
+// 1. stays are the vehicles parked before the period ends and not unparked before it starts, found through the index on parked_at.
+// 2. usage counts the stays parked in each bucket like GetDailyReport, width_bucket finds the bucket among the bucket starts
+// computed by ReportQuery, so both backends agree on local days and DST transitions.
+// 3. changes nets arrivals, clamped to the period start, and departures by instant, occupancy is their running sum per lot.
+// 4. peaks keeps the highest occupancy of each bucket, its first change and closing occupancy, so newReportSeries can carry
+// the occupancy of quiet buckets forward.
+func (r *ParkingLotRepoDB) rangeUsage(ctx context.Context, q ReportQuery, lots, filter string, args ...any) (map[int][]bucketUsage, common.AppError) {
+	query := fmt.Sprintf(`
+   WITH stays AS (
+       SELECT g.lot_id, v.parked_at, v.unparked_at, v.fee
+       FROM vehicles v
+       JOIN slots s ON v.slot_id = s.id
+       CROSS JOIN LATERAL (VALUES %s) g(lot_id)
+       WHERE %s
+        AND v.parked_at < $2 AND (v.unparked_at IS NULL OR v.unparked_at > $1)
+   ),
+   usage AS (
+       SELECT lot_id, width_bucket(parked_at, $3::timestamptz[]) - 1 as bucket,
+              COUNT(*) as vehicles,
+              COALESCE(SUM(CEIL(EXTRACT(EPOCH FROM (unparked_at - parked_at))/3600)), 0)::int as hours,
+              COALESCE(SUM(fee), 0)::int as fee,
+              COUNT(unparked_at) as stays,
+              COALESCE(SUM(FLOOR(EXTRACT(EPOCH FROM (unparked_at - parked_at)))), 0)::bigint as stay_seconds
+       FROM stays
+       WHERE parked_at >= $1
+       GROUP BY lot_id, bucket
+   ),
+   changes AS (
+       SELECT lot_id, at, SUM(delta) as delta
+       FROM (
+           SELECT lot_id, GREATEST(parked_at, $1) as at, 1 as delta FROM stays
+           UNION ALL
+           SELECT lot_id, unparked_at, -1 FROM stays WHERE unparked_at < $2
+       ) events
+       GROUP BY lot_id, at
+   ),
+   occupancy AS (
+       SELECT lot_id, at, SUM(delta) OVER (PARTITION BY lot_id ORDER BY at) as occupied
+       FROM changes
+   ),
+   peaks AS (
+       SELECT lot_id, width_bucket(at, $3::timestamptz[]) - 1 as bucket, MIN(at) as first_change_at, MAX(occupied)::int as peak,
+              (ARRAY_AGG(occupied ORDER BY at DESC))[1]::int as closing
+       FROM occupancy
+       GROUP BY lot_id, bucket
+   )
+   SELECT COALESCE(u.lot_id, p.lot_id), COALESCE(u.bucket, p.bucket),
+          COALESCE(u.vehicles, 0), COALESCE(u.hours, 0), COALESCE(u.fee, 0), COALESCE(u.stays, 0), COALESCE(u.stay_seconds, 0),
+          p.first_change_at, COALESCE(p.peak, 0), COALESCE(p.closing, 0)
+   FROM usage u
+   FULL JOIN peaks p ON u.lot_id = p.lot_id AND u.bucket = p.bucket
+`, lots, filter)
+
+	rows, err := r.db.QueryContext(ctx, query, append([]any{q.From, q.To, q.bucketStarts()}, args...)...)
+	if err != nil {
+		r.l.Error("error generating range report", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	usage := make(map[int][]bucketUsage)
+	for rows.Next() {
+		var plID int
+		var u bucketUsage
+		if err = rows.Scan(&plID, &u.bucket, &u.vehicles, &u.hours, &u.fee, &u.stays, &u.staySeconds,
+			&u.firstChangeAt, &u.peak, &u.closing); err != nil {
+			r.l.Error("error scanning range report", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		usage[plID] = append(usage[plID], u)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating range report", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return usage, nil
+}
+
+// getLotTimeZone returns the ID and time zone of a parking lot, 404 Not Found for unknown parking lots.
+func (r *ParkingLotRepoDB) getLotTimeZone(ctx context.Context, plUUID uuid.UUID) (int, string, common.AppError) {
+	var plID int
+	var timeZone string
+	err := r.db.QueryRowContext(ctx, "SELECT id, time_zone FROM parking_lots WHERE uuid = $1", plUUID).Scan(&plID, &timeZone)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.l.Error("parking_lots not found", "err", err)
+		return 0, "", common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	} else if err != nil {
+		r.l.Error("error fetching parking lot time zone", "err", err)
+		return 0, "", common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return plID, timeZone, nil
+}
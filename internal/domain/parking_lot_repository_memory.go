@@ -0,0 +1,426 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+var _ ParkingLotRepository = (*ParkingLotRepoMemory)(nil)
+
+// ParkingLotRepoMemory implements ParkingLotRepository in memory, with the same semantics as ParkingLotRepoDB.
+type ParkingLotRepoMemory struct {
+	s   *MemoryStore
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewParkingLotRepoMemory(s *MemoryStore, l *slog.Logger) *ParkingLotRepoMemory {
+	return &ParkingLotRepoMemory{
+		s:   s,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used for timestamps, lets tests control time.
+func (r *ParkingLotRepoMemory) WithClock(now func() time.Time) *ParkingLotRepoMemory {
+	r.now = now
+	return r
+}
+
+// CreateParkingLot creates a parking lot with slots numbered 1..n level by level and zone by zone, grouped by slot type.
+// Returns a 400 Bad Request error for an invalid layout, 409 Conflict error if the name is taken.
+func (r *ParkingLotRepoMemory) CreateParkingLot(_ context.Context, lot *ParkingLot) (*ParkingLot, common.AppError) {
+	if appErr := lot.parseLotAllocationStrategy(); appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := lot.parseLotTimeZone(); appErr != nil {
+		return nil, appErr
+	}
+
+	plan, levels, appErr := lot.slotPlan()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.lotByName(lot.Name) != nil {
+		r.l.Error("parking lot with this name already exists", "name", lot.Name)
+		return nil, common.NewConflictError("parking lot with this name already exists")
+	}
+
+	ml := &memLot{id: uuid.New(), name: lot.Name, timeZone: lot.TimeZone, strategy: lot.AllocationStrategy}
+	for _, level := range levels {
+		ml.levels = append(ml.levels, LevelLayout{Name: level.Name, EntranceDistance: level.EntranceDistance})
+	}
+
+	slots := make([]Slot, 0, len(plan))
+	for idx, spec := range plan {
+		slot := &memSlot{
+			Slot: Slot{
+				ID:            uuid.New(),
+				SlotNumber:    idx + 1,
+				Label:         spec.Label,
+				Level:         spec.Level,
+				Zone:          spec.Zone,
+				Type:          spec.Type,
+				IsAvailable:   true,
+				IsMaintenance: false,
+			},
+			lotID: ml.id,
+		}
+
+		ml.slots = append(ml.slots, slot)
+		r.s.slots[slot.ID] = slot
+		slots = append(slots, slot.Slot)
+	}
+
+	r.s.lots[ml.id] = ml
+
+	lot.Slots = slots
+	lot.ID = ml.id
+	return lot, nil
+}
+
+// GetParkingLotStatus lists every slot with each vehicle ever parked in it, slots without vehicles are listed once.
+func (r *ParkingLotRepoMemory) GetParkingLotStatus(_ context.Context, plUUID uuid.UUID) (*ParkingLotStatus, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	var slots []SlotStatus
+	for _, slot := range lot.slots {
+		hasVehicles := false
+		for _, v := range r.s.vehicles {
+			if v.SlotID != slot.ID {
+				continue
+			}
+
+			regNum, rawRegNum, parkedAt := v.RegistrationNumber, v.RawRegistrationNumber, v.ParkedAt
+			slots = append(slots, SlotStatus{
+				SlotID:             slot.ID,
+				SlotLabel:          slot.Label,
+				Level:              slot.Level,
+				Zone:               slot.Zone,
+				SlotType:           slot.Type,
+				RegistrationNum:    &regNum,
+				RawRegistrationNum: &rawRegNum,
+				ParkedAt:           &parkedAt,
+				UnparkedAt:         copyTime(v.UnparkedAt),
+			})
+			hasVehicles = true
+		}
+
+		if !hasVehicles {
+			slots = append(slots, SlotStatus{SlotID: slot.ID, SlotLabel: slot.Label, Level: slot.Level, Zone: slot.Zone, SlotType: slot.Type})
+		}
+	}
+
+	maintenance := make([]MaintenanceWindow, 0)
+	for _, w := range r.s.lotMaintenance(plUUID) {
+		if w.EndedAt == nil {
+			maintenance = append(maintenance, w)
+		}
+	}
+
+	return &ParkingLotStatus{
+		ParkingLotID: plUUID,
+		Name:         lot.name,
+		TimeZone:     lot.timeZone,
+		Slots:        slots,
+		Levels:       groupByLevel(slots, lot.levels),
+		Maintenance:  maintenance,
+	}, nil
+}
+
+// GetDailyReport counts vehicles parked on reportDate in the parking lot's time zone, sums started hours and stored fees
+// of the unparked ones, split between stays under a permit and transient ones.
+func (r *ParkingLotRepoMemory) GetDailyReport(_ context.Context, plUUID uuid.UUID, reportDate time.Time) (*DailyReport, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	reportDate, endDate := localDay(reportDate, lotLocation(lot.timeZone))
+
+	var report DailyReport
+	for _, v := range r.s.vehicles {
+		if r.s.slots[v.SlotID].lotID != plUUID || v.ParkedAt.Before(reportDate) || !v.ParkedAt.Before(endDate) {
+			continue
+		}
+
+		usage := &report.Transient
+		if v.PermitID != nil {
+			usage = &report.Permit
+		}
+
+		usage.VehiclesParked++
+		if v.UnparkedAt != nil {
+			usage.ParkingHours += billableHours(v.ParkedAt, *v.UnparkedAt)
+			usage.FeeCollected += v.Fee
+		}
+	}
+
+	report.total()
+	return &report, nil
+}
+
+// GetRangeReport returns the usage of a parking lot over a period as a time series in the lot's time zone, with the same semantics as
+// ParkingLotRepoDB.GetRangeReport.
+func (r *ParkingLotRepoMemory) GetRangeReport(_ context.Context, plUUID uuid.UUID, q ReportQuery) (*RangeReport, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	q.Location = lotLocation(lot.timeZone)
+	if appErr := q.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	report := RangeReport{ParkingLotID: plUUID, TimeZone: lot.timeZone, From: q.From.UTC(), To: q.To.UTC(), Granularity: q.Granularity}
+	report.Buckets, report.Total = newReportSeries(q, aggregateStays(q, r.s.lotVehicles(plUUID)))
+	return &report, nil
+}
+
+// GetPortfolioReport returns the usage of every parking lot combined over a period as a time series in the query's time zone, and the totals
+// of each lot ordered by name.
+func (r *ParkingLotRepoMemory) GetPortfolioReport(_ context.Context, q ReportQuery) (*PortfolioReport, common.AppError) {
+	if appErr := q.normalize(); appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	report := PortfolioReport{
+		TimeZone:    q.Location.String(),
+		From:        q.From.UTC(),
+		To:          q.To.UTC(),
+		Granularity: q.Granularity,
+		Lots:        make([]LotReport, 0, len(r.s.lots)),
+	}
+	for _, lot := range r.s.lots {
+		lotReport := LotReport{ParkingLotID: lot.id, Name: lot.name}
+		_, lotReport.Total = newReportSeries(q, aggregateStays(q, r.s.lotVehicles(lot.id)))
+		report.Lots = append(report.Lots, lotReport)
+	}
+
+	sort.Slice(report.Lots, func(i, j int) bool { return report.Lots[i].Name < report.Lots[j].Name })
+	report.Buckets, report.Total = newReportSeries(q, aggregateStays(q, r.s.vehicles))
+	return &report, nil
+}
+
+// SetSlotMaintenance moves a slot into or out of maintenance with the same rules as ParkingLotRepoDB.SetSlotMaintenance.
+func (r *ParkingLotRepoMemory) SetSlotMaintenance(_ context.Context, plUUID uuid.UUID, slotUUID uuid.UUID, change MaintenanceChange) (*Slot, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	slot, ok := r.s.slots[slotUUID]
+	if !ok || slot.lotID != plUUID {
+		return nil, common.NewNotFoundError("slot not found in this parking lot")
+	}
+
+	switch {
+	case change.InMaintenance && slot.IsMaintenance:
+		return nil, common.NewConflictError("slot is already under maintenance")
+	case !change.InMaintenance && !slot.IsMaintenance:
+		return nil, common.NewConflictError("slot is not under maintenance")
+	case change.InMaintenance && !slot.IsAvailable && !change.Force:
+		return nil, common.NewConflictError("slot is occupied, use force to put it under maintenance")
+	}
+
+	now := r.now().UTC()
+	if change.InMaintenance {
+		r.s.maintenance = append(r.s.maintenance, &MaintenanceWindow{
+			ID:         uuid.New(),
+			SlotID:     slot.ID,
+			SlotNumber: slot.SlotNumber,
+			Reason:     change.Reason,
+			Forced:     change.forced(slot.IsAvailable),
+			StartedBy:  change.PerformedBy,
+			StartedAt:  now,
+		})
+	} else {
+		for _, w := range r.s.maintenance {
+			if w.SlotID == slot.ID && w.EndedAt == nil {
+				endedBy := change.PerformedBy
+				w.EndedBy, w.EndedAt = &endedBy, &now
+			}
+		}
+	}
+
+	slot.IsMaintenance = change.InMaintenance
+	result := slot.Slot
+	return &result, nil
+}
+
+// ListMaintenanceWindows pages the maintenance history of a parking lot like ParkingLotRepoDB.ListMaintenanceWindows.
+func (r *ParkingLotRepoMemory) ListMaintenanceWindows(_ context.Context, plUUID uuid.UUID, q MaintenanceQuery) (*MaintenancePage, common.AppError) {
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	windows := make([]MaintenanceWindow, 0, q.Limit+1)
+	for _, w := range r.s.lotMaintenance(plUUID) {
+		if len(windows) > q.Limit {
+			break
+		}
+
+		if cursor == nil || cursor.after(w.StartedAt, w.ID, SessionOrderDesc) {
+			windows = append(windows, w)
+		}
+	}
+
+	return newMaintenancePage(lot.timeZone, windows, q.Limit), nil
+}
+
+// GetPricingPolicy returns the pricing policy currently in effect for a parking lot.
+func (r *ParkingLotRepoMemory) GetPricingPolicy(_ context.Context, plUUID uuid.UUID) (*PricingPolicy, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	return r.s.pricingPolicy(lot), nil
+}
+
+// SetPricingPolicy stores the pricing policy of a parking lot, replacing the existing one and bumping its version.
+func (r *ParkingLotRepoMemory) SetPricingPolicy(_ context.Context, plUUID uuid.UUID, policy *PricingPolicy) (*PricingPolicy, common.AppError) {
+	if appErr := policy.Validate(); appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	policy.ParkingLotID = plUUID
+	policy.Version = r.s.pricingPolicy(lot).Version + 1
+
+	if len(policy.VehicleTypeMultipliers) == 0 {
+		policy.VehicleTypeMultipliers = nil
+	}
+
+	lot.policy = copyPricingPolicy(policy)
+	return policy, nil
+}
+
+// GetAllocationStrategy returns the slot allocation strategy of a parking lot.
+func (r *ParkingLotRepoMemory) GetAllocationStrategy(_ context.Context, plUUID uuid.UUID) (AllocationStrategy, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return "", common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	return lot.strategy, nil
+}
+
+// SetAllocationStrategy changes the slot allocation strategy of a parking lot, applied from the next park.
+func (r *ParkingLotRepoMemory) SetAllocationStrategy(_ context.Context, plUUID uuid.UUID, strategy AllocationStrategy) common.AppError {
+	if !strategy.Valid() {
+		return common.NewBadRequestError(fmt.Sprintf("unknown allocation strategy %q", strategy))
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	lot.strategy = strategy
+	return nil
+}
+
+// GetOccupancy counts occupied, available and maintenance slots of every parking lot, ordered by name.
+func (r *ParkingLotRepoMemory) GetOccupancy(_ context.Context) ([]LotOccupancy, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	occupancy := make([]LotOccupancy, 0, len(r.s.lots))
+	for _, lot := range r.s.lots {
+		o := LotOccupancy{ParkingLotID: lot.id, Name: lot.name}
+		for _, slot := range lot.slots {
+			if !slot.IsAvailable {
+				o.Occupied++
+			}
+
+			if slot.IsAvailable && !slot.IsMaintenance {
+				o.Available++
+			}
+
+			if slot.IsMaintenance {
+				o.Maintenance++
+			}
+		}
+
+		occupancy = append(occupancy, o)
+	}
+
+	sort.Slice(occupancy, func(i, j int) bool { return occupancy[i].Name < occupancy[j].Name })
+	return occupancy, nil
+}
+
+// copyTime returns a copy of t, so callers can't mutate the store through returned pointers.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	c := *t
+	return &c
+}
+
+// copyUUID returns a copy of id, so callers can't mutate the store through returned pointers.
+func copyUUID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+
+	c := *id
+	return &c
+}
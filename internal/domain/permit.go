@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+// Permit lets subscriber vehicles park in a parking lot without paying between ValidFrom and ValidUntil.
+// A permit with an assigned slot keeps that slot for its plates, other vehicles never park or reserve it while the permit
+// is valid. Floating permits, without a slot, park like any other vehicle. Stays that start under a valid permit are free
+// until the permit expires or is revoked.
+type Permit struct {
+	ID           uuid.UUID  `json:"id"`
+	ParkingLotID uuid.UUID  `json:"parkingLotId"`
+	Holder       string     `json:"holder"`
+	Plates       []string   `json:"plates"`              // canonical, see plate.Canonicalize
+	RawPlates    []string   `json:"rawPlates,omitempty"` // as entered in the order of Plates, canonical for plates stored before
+	SlotID       *uuid.UUID `json:"slotId,omitempty"`
+	SlotLabel    string     `json:"slotLabel,omitempty"`
+	ValidFrom    time.Time  `json:"validFrom"`
+	ValidUntil   time.Time  `json:"validUntil"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// PermitRequest describes a permit to issue. ValidFrom defaults to now and ValidUntil to a month after ValidFrom,
+// a nil SlotID issues a floating permit.
+type PermitRequest struct {
+	Holder     string
+	Plates     []string
+	SlotID     *uuid.UUID
+	ValidFrom  time.Time
+	ValidUntil time.Time
+
+	rawPlates []string // as entered, set by normalize
+}
+
+// normalize applies the defaults of the validity period at now, canonicalizes the plates keeping them as entered,
+// and validates the request.
+func (r *PermitRequest) normalize(now time.Time) common.AppError {
+	if r.ValidFrom.IsZero() {
+		r.ValidFrom = now
+	}
+
+	if r.ValidUntil.IsZero() {
+		r.ValidUntil = r.ValidFrom.AddDate(0, 1, 0)
+	}
+
+	r.ValidFrom, r.ValidUntil = r.ValidFrom.UTC(), r.ValidUntil.UTC()
+
+	switch {
+	case r.Holder == "":
+		return common.NewBadRequestError("permit holder can't be empty")
+	case len(r.Plates) == 0:
+		return common.NewBadRequestError("permit needs at least one plate")
+	case !r.ValidUntil.After(r.ValidFrom):
+		return common.NewBadRequestError("permit must be valid until after it's valid from")
+	}
+
+	seen := make(map[string]bool, len(r.Plates))
+	plates := make([]string, 0, len(r.Plates))
+	r.rawPlates = make([]string, 0, len(r.Plates))
+	for _, raw := range r.Plates {
+		p := plate.Canonicalize(raw)
+		if p == "" {
+			return common.NewBadRequestError("permit plates can't be empty")
+		}
+
+		if seen[p] {
+			return common.NewBadRequestError("permit plate " + p + " is listed twice")
+		}
+
+		seen[p] = true
+		plates = append(plates, p)
+		r.rawPlates = append(r.rawPlates, strings.TrimSpace(raw))
+	}
+
+	r.Plates = plates
+	return nil
+}
+
+// validAt reports whether the permit lets its plates park at t.
+func (p *Permit) validAt(t time.Time) bool {
+	return p.RevokedAt == nil && !t.Before(p.ValidFrom) && t.Before(p.ValidUntil)
+}
+
+// coverageEnd returns when the permit stops covering stays, ValidUntil or its revocation if it was revoked earlier.
+func (p *Permit) coverageEnd() time.Time {
+	if p.RevokedAt != nil && p.RevokedAt.Before(p.ValidUntil) {
+		return *p.RevokedAt
+	}
+
+	return p.ValidUntil
+}
+
+// overlaps reports whether the permit is unrevoked and valid at any time between from and until.
+func (p *Permit) overlaps(from, until time.Time) bool {
+	return p.RevokedAt == nil && p.ValidFrom.Before(until) && p.ValidUntil.After(from)
+}
+
+// hasPlate reports whether the permit covers the registration number.
+func (p *Permit) hasPlate(regNum string) bool {
+	for _, plate := range p.Plates {
+		if plate == regNum {
+			return true
+		}
+	}
+
+	return false
+}
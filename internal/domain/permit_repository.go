@@ -0,0 +1,264 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// PermitRepository defines the interface for issuing and revoking subscriber permits,
+// implemented for the postgresql database by PermitRepoDB and in memory by PermitRepoMemory.
+// VehicleRepository.ParkVehicle assigns permit slots and UnparkVehicle waives the fee of permit stays.
+type PermitRepository interface {
+	CreatePermit(ctx context.Context, plUUID uuid.UUID, req PermitRequest) (*Permit, common.AppError)
+	ListPermits(ctx context.Context, plUUID uuid.UUID) ([]Permit, common.AppError)
+	RevokePermit(ctx context.Context, plUUID, permitUUID uuid.UUID) (*Permit, common.AppError)
+}
+
+var _ PermitRepository = (*PermitRepoDB)(nil)
+
+type PermitRepoDB struct {
+	db  *sql.DB
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewPermitRepoDB(db *sql.DB, l *slog.Logger) *PermitRepoDB {
+	return &PermitRepoDB{
+		db:  db,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used for default validity periods and revocations, lets tests control time.
+func (r *PermitRepoDB) WithClock(now func() time.Time) *PermitRepoDB {
+	r.now = now
+	return r
+}
+
+// CreatePermit performs the following within a serializable transaction to prevent conflicting permits:
+// 1. Returns a 409 Conflict error if one of the plates already has an unrevoked permit of the parking lot overlapping the period.
+// 2. For an assigned slot, locks the slot, 404 Not Found if it belongs to another parking lot, and returns a 409 Conflict error
+// if another unrevoked permit assigns it or a live reservation books it during the period.
+// 3. Stores the permit and its plates, in the order given.
+// Returns a 400 Bad Request error for a missing holder or plates, duplicated plates or an invalid period.
+func (r *PermitRepoDB) CreatePermit(ctx context.Context, plUUID uuid.UUID, req PermitRequest) (*Permit, common.AppError) {
+	now := r.now().UTC()
+	if appErr := req.normalize(now); appErr != nil {
+		return nil, appErr
+	}
+
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var permit *Permit
+	appErr = withSerializableTx(ctx, r.db, r.l, "CreatePermit", func(tx *sql.Tx) common.AppError {
+		var overlaps bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT EXISTS(SELECT 1 FROM permits p
+                          JOIN permit_plates pp ON pp.permit_id = p.id
+                          WHERE p.parking_lot_id = $1 AND p.revoked_at IS NULL AND pp.registration_number = ANY($2::text[])
+                            AND p.valid_from < $3 AND p.valid_until > $4)`,
+			plID, req.Plates, req.ValidUntil, req.ValidFrom).Scan(&overlaps)
+		if err != nil {
+			r.l.Error("error checking overlapping permits", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		} else if overlaps {
+			return common.NewConflictError(common.ErrPermitPlateOverlaps)
+		}
+
+		var slotID sql.NullInt64
+		if req.SlotID != nil {
+			var slotErr common.AppError
+			if slotID, slotErr = r.lockAssignableSlot(ctx, tx, plID, *req.SlotID, req, now); slotErr != nil {
+				return slotErr
+			}
+		}
+
+		var permitID int
+		var permitUUID uuid.UUID
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO permits (parking_lot_id, holder, slot_id, valid_from, valid_until, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, uuid`, plID, req.Holder, slotID, req.ValidFrom, req.ValidUntil, now).Scan(&permitID, &permitUUID)
+		if err != nil {
+			r.l.Error("error creating permit", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		for i, plate := range req.Plates {
+			if _, err = tx.ExecContext(ctx, `
+                INSERT INTO permit_plates (permit_id, registration_number, registration_number_raw, position)
+                VALUES ($1, $2, $3, $4)`, permitID, plate, req.rawPlates[i], i); err != nil {
+				r.l.Error("error creating permit plate", "err", err)
+				return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+			}
+		}
+
+		permits, appErr := r.getPermits(ctx, tx, plID, plUUID, &permitUUID)
+		if appErr != nil {
+			return appErr
+		}
+
+		permit = &permits[0]
+		r.l.Info("permit issued", "holder", permit.Holder, "plates", permit.Plates, "slot", permit.SlotLabel, "valid_until", permit.ValidUntil)
+		return nil
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return permit, nil
+}
+
+// lockAssignableSlot locks the slot to assign to a permit and returns its id. Returns a 404 Not Found error if the slot
+// belongs to another parking lot, 409 Conflict if an unrevoked permit assigns it or a live reservation books it during the period.
+func (r *PermitRepoDB) lockAssignableSlot(ctx context.Context, tx *sql.Tx, plID int, slotUUID uuid.UUID, req PermitRequest,
+	now time.Time) (sql.NullInt64, common.AppError) {
+	var slotID sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM slots WHERE uuid = $1 AND parking_lot_id = $2 FOR UPDATE", slotUUID, plID).Scan(&slotID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return slotID, common.NewNotFoundError("slot not found in this parking lot")
+	} else if err != nil {
+		r.l.Error("error fetching slot", "err", err)
+		return slotID, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM permits
+                      WHERE slot_id = $1 AND revoked_at IS NULL AND valid_from < $2 AND valid_until > $3)
+            OR EXISTS(SELECT 1 FROM reservations
+                      WHERE slot_id = $1 AND starts_at < $2 AND ends_at > $3
+                        AND (status = $4 OR (status = $5 AND hold_until >= $6)))`,
+		slotID, req.ValidUntil, req.ValidFrom, ReservationFulfilled, ReservationActive, now).Scan(&taken)
+	if err != nil {
+		r.l.Error("error checking slot assignments", "err", err)
+		return slotID, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	} else if taken {
+		return slotID, common.NewConflictError(common.ErrPermitSlotTaken)
+	}
+
+	return slotID, nil
+}
+
+// ListPermits returns every permit issued for a parking lot, revoked ones included, ordered by start of validity.
+func (r *PermitRepoDB) ListPermits(ctx context.Context, plUUID uuid.UUID) ([]Permit, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return r.getPermits(ctx, r.db, plID, plUUID, nil)
+}
+
+// RevokePermit ends a permit now, returns a 409 Conflict error if it's already revoked.
+// Vehicles parked under the permit are charged for the rest of their stay from now.
+func (r *PermitRepoDB) RevokePermit(ctx context.Context, plUUID, permitUUID uuid.UUID) (*Permit, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var permit *Permit
+	appErr = withSerializableTx(ctx, r.db, r.l, "RevokePermit", func(tx *sql.Tx) common.AppError {
+		var revokedAt *time.Time
+		err := tx.QueryRowContext(ctx, "SELECT revoked_at FROM permits WHERE uuid = $1 AND parking_lot_id = $2 FOR UPDATE",
+			permitUUID, plID).Scan(&revokedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NewNotFoundError("permit not found in this parking lot")
+		} else if err != nil {
+			r.l.Error("error fetching permit", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		if revokedAt != nil {
+			return common.NewConflictError("permit is already revoked")
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE permits SET revoked_at = $1 WHERE uuid = $2", r.now().UTC(), permitUUID); err != nil {
+			r.l.Error("error revoking permit", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		permits, appErr := r.getPermits(ctx, tx, plID, plUUID, &permitUUID)
+		if appErr != nil {
+			return appErr
+		}
+
+		permit = &permits[0]
+		return nil
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return permit, nil
+}
+
+// getPermits loads the permits of a parking lot with their plates, only the one with permitUUID if it isn't nil.
+// Returns a 404 Not Found error if permitUUID doesn't match a permit of the parking lot.
+func (r *PermitRepoDB) getPermits(ctx context.Context, q queryer, plID int, plUUID uuid.UUID, permitUUID *uuid.UUID) ([]Permit, common.AppError) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT p.id, p.uuid, p.holder, s.uuid, COALESCE(s.label, ''), p.valid_from, p.valid_until, p.revoked_at, p.created_at,
+               pp.registration_number, COALESCE(pp.registration_number_raw, pp.registration_number)
+        FROM permits p
+        JOIN permit_plates pp ON pp.permit_id = p.id
+        LEFT JOIN slots s ON p.slot_id = s.id
+        WHERE p.parking_lot_id = $1 AND ($2::uuid IS NULL OR p.uuid = $2::uuid)
+        ORDER BY p.valid_from, p.id, pp.position`, plID, permitUUID)
+	if err != nil {
+		r.l.Error("error fetching permits", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	permits := make([]Permit, 0)
+	lastID := 0
+	for rows.Next() {
+		var id int
+		var p Permit
+		var plate, rawPlate string
+		if err = rows.Scan(&id, &p.ID, &p.Holder, &p.SlotID, &p.SlotLabel, &p.ValidFrom, &p.ValidUntil, &p.RevokedAt, &p.CreatedAt,
+			&plate, &rawPlate); err != nil {
+			r.l.Error("error scanning permit", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		// rows hold one plate each, grouped by permit.
+		if id != lastID {
+			p.ParkingLotID = plUUID
+			p.ValidFrom, p.ValidUntil, p.CreatedAt = p.ValidFrom.UTC(), p.ValidUntil.UTC(), p.CreatedAt.UTC()
+			if p.RevokedAt != nil {
+				revokedAt := p.RevokedAt.UTC()
+				p.RevokedAt = &revokedAt
+			}
+
+			permits = append(permits, p)
+			lastID = id
+		}
+
+		last := &permits[len(permits)-1]
+		last.Plates = append(last.Plates, plate)
+		last.RawPlates = append(last.RawPlates, rawPlate)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating permits", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	if permitUUID != nil && len(permits) == 0 {
+		return nil, common.NewNotFoundError("permit not found in this parking lot")
+	}
+
+	return permits, nil
+}
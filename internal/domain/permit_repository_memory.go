@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+var _ PermitRepository = (*PermitRepoMemory)(nil)
+
+// PermitRepoMemory implements PermitRepository in memory, with the same semantics as PermitRepoDB.
+type PermitRepoMemory struct {
+	s   *MemoryStore
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewPermitRepoMemory(s *MemoryStore, l *slog.Logger) *PermitRepoMemory {
+	return &PermitRepoMemory{
+		s:   s,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used for default validity periods and revocations, lets tests control time.
+func (r *PermitRepoMemory) WithClock(now func() time.Time) *PermitRepoMemory {
+	r.now = now
+	return r
+}
+
+// CreatePermit issues a permit with the same rules as PermitRepoDB.CreatePermit.
+func (r *PermitRepoMemory) CreatePermit(_ context.Context, plUUID uuid.UUID, req PermitRequest) (*Permit, common.AppError) {
+	now := r.now().UTC()
+	if appErr := req.normalize(now); appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	for _, p := range r.s.permits {
+		if p.ParkingLotID != plUUID || !p.overlaps(req.ValidFrom, req.ValidUntil) {
+			continue
+		}
+
+		for _, plate := range req.Plates {
+			if p.hasPlate(plate) {
+				return nil, common.NewConflictError(common.ErrPermitPlateOverlaps)
+			}
+		}
+	}
+
+	permit := &Permit{
+		ID:           uuid.New(),
+		ParkingLotID: plUUID,
+		Holder:       req.Holder,
+		Plates:       slices.Clone(req.Plates),
+		RawPlates:    slices.Clone(req.rawPlates),
+		ValidFrom:    req.ValidFrom,
+		ValidUntil:   req.ValidUntil,
+		CreatedAt:    now,
+	}
+
+	if req.SlotID != nil {
+		slot, ok := r.s.slots[*req.SlotID]
+		if !ok || slot.lotID != plUUID {
+			return nil, common.NewNotFoundError("slot not found in this parking lot")
+		}
+
+		if r.s.slotAssigned(slot.ID, req.ValidFrom, req.ValidUntil) || r.s.slotReserved(slot.ID, req.ValidFrom, req.ValidUntil, now) {
+			return nil, common.NewConflictError(common.ErrPermitSlotTaken)
+		}
+
+		slotID := slot.ID
+		permit.SlotID, permit.SlotLabel = &slotID, slot.Label
+	}
+
+	r.s.permits = append(r.s.permits, permit)
+	r.l.Info("permit issued", "holder", permit.Holder, "plates", permit.Plates, "slot", permit.SlotLabel, "valid_until", permit.ValidUntil)
+
+	return copyPermit(permit), nil
+}
+
+// ListPermits returns every permit issued for a parking lot, revoked ones included, ordered by start of validity.
+func (r *PermitRepoMemory) ListPermits(_ context.Context, plUUID uuid.UUID) ([]Permit, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	permits := make([]Permit, 0)
+	for _, p := range r.s.permits {
+		if p.ParkingLotID == plUUID {
+			permits = append(permits, *copyPermit(p))
+		}
+	}
+
+	// permits are stored in creation order, same tie break as PermitRepoDB.
+	sort.SliceStable(permits, func(i, j int) bool { return permits[i].ValidFrom.Before(permits[j].ValidFrom) })
+	return permits, nil
+}
+
+// RevokePermit ends a permit now, returns a 409 Conflict error if it's already revoked.
+func (r *PermitRepoMemory) RevokePermit(_ context.Context, plUUID, permitUUID uuid.UUID) (*Permit, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	for _, p := range r.s.permits {
+		if p.ID != permitUUID || p.ParkingLotID != plUUID {
+			continue
+		}
+
+		if p.RevokedAt != nil {
+			return nil, common.NewConflictError("permit is already revoked")
+		}
+
+		revokedAt := r.now().UTC()
+		p.RevokedAt = &revokedAt
+		return copyPermit(p), nil
+	}
+
+	return nil, common.NewNotFoundError("permit not found in this parking lot")
+}
+
+// copyPermit returns a deep copy of p, so the store never shares plates or pointers with callers.
+func copyPermit(p *Permit) *Permit {
+	c := *p
+	c.Plates = slices.Clone(p.Plates)
+	c.RawPlates = slices.Clone(p.RawPlates)
+	c.SlotID = copyUUID(p.SlotID)
+	c.RevokedAt = copyTime(p.RevokedAt)
+	return &c
+}
@@ -0,0 +1,278 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultHourlyRate is charged per started hour when a parking lot has no pricing policy of its own.
+	defaultHourlyRate = 10
+
+	// defaultCurrency is the ISO 4217 code of fees when a pricing policy doesn't specify one.
+	defaultCurrency = "USD"
+)
+
+// PricingPolicy holds the rates of a parking lot, all amounts are in whole currency units.
+// Zero valued optional rates fall back to the regular ones: FirstHourRate and weekend rates to HourlyRate,
+// a zero DailyCap means uncapped, and the night flat rate only applies when NightStartHour != NightEndHour.
+// VehicleTypeMultipliers scale the fee per vehicle type, eg: 0.5 for motorcycles, types without one pay the full fee.
+// Version 0 denotes the default policy of a parking lot that never had one stored.
+type PricingPolicy struct {
+	ParkingLotID           uuid.UUID               `json:"parkingLotId"`
+	Version                int                     `json:"version"`
+	HourlyRate             int                     `json:"hourlyRate"`
+	FirstHourRate          int                     `json:"firstHourRate"`
+	GracePeriodMinutes     int                     `json:"gracePeriodMinutes"`
+	DailyCap               int                     `json:"dailyCap"`
+	NightFlatRate          int                     `json:"nightFlatRate"`
+	NightStartHour         int                     `json:"nightStartHour"`
+	NightEndHour           int                     `json:"nightEndHour"`
+	WeekendHourlyRate      int                     `json:"weekendHourlyRate"`
+	WeekendFirstHourRate   int                     `json:"weekendFirstHourRate"`
+	Currency               string                  `json:"currency"`
+	VehicleTypeMultipliers map[VehicleType]float64 `json:"vehicleTypeMultipliers,omitempty"`
+}
+
+// DefaultPricingPolicy returns the policy used for parking lots without a stored one, a flat hourly rate.
+func DefaultPricingPolicy(plUUID uuid.UUID) *PricingPolicy {
+	return &PricingPolicy{
+		ParkingLotID: plUUID,
+		HourlyRate:   defaultHourlyRate,
+		Currency:     defaultCurrency,
+	}
+}
+
+// Validate checks rates aren't negative, night hours are valid hours of a day, the currency is a 3 letter code
+// and vehicle type multipliers are positive for known types. An empty currency is set to the default one.
+func (p *PricingPolicy) Validate() common.AppError {
+	if p.Currency == "" {
+		p.Currency = defaultCurrency
+	}
+
+	if len(p.Currency) != 3 {
+		return common.NewBadRequestError("currency must be a 3 letter ISO 4217 code")
+	}
+
+	p.Currency = strings.ToUpper(p.Currency)
+
+	for _, rate := range []int{p.HourlyRate, p.FirstHourRate, p.GracePeriodMinutes, p.DailyCap, p.NightFlatRate,
+		p.WeekendHourlyRate, p.WeekendFirstHourRate} {
+		if rate < 0 {
+			return common.NewBadRequestError("pricing rates, caps and grace period can't be negative")
+		}
+	}
+
+	if p.NightStartHour < 0 || p.NightStartHour > 23 || p.NightEndHour < 0 || p.NightEndHour > 23 {
+		return common.NewBadRequestError("night start and end hours must be between 0 and 23")
+	}
+
+	for t, m := range p.VehicleTypeMultipliers {
+		if !t.Valid() {
+			return common.NewBadRequestError(fmt.Sprintf("unknown vehicle type %q in vehicle type multipliers", t))
+		}
+
+		if m <= 0 {
+			return common.NewBadRequestError("vehicle type multipliers must be positive")
+		}
+	}
+
+	return nil
+}
+
+// billableHours returns the number of started hours between parkedAt and unparkedAt.
+func billableHours(parkedAt, unparkedAt time.Time) int {
+	duration := unparkedAt.Sub(parkedAt)
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(duration.Hours())) // Round up to the nearest hour
+}
+
+// FeeLineKind identifies what a line of a fee breakdown charges or discounts.
+type FeeLineKind string
+
+const (
+	FeeLineGracePeriod      FeeLineKind = "grace_period"
+	FeeLineFirstHour        FeeLineKind = "first_hour"
+	FeeLineHourly           FeeLineKind = "hourly"
+	FeeLineWeekendFirstHour FeeLineKind = "weekend_first_hour"
+	FeeLineWeekendHourly    FeeLineKind = "weekend_hourly"
+	FeeLineNightFlat        FeeLineKind = "night_flat"
+	FeeLineDailyCap         FeeLineKind = "daily_cap"    // discount down to the cap of a 24 hours block
+	FeeLineVehicleType      FeeLineKind = "vehicle_type" // adjustment of the vehicle type multiplier
+	FeeLinePermit           FeeLineKind = "permit"       // discount of stays under a permit
+)
+
+// FeeLineItem is a line of a fee breakdown, discounts have a negative Amount.
+// Consecutive hours billed at the same rate share a line, a night line covers every hour of the night.
+type FeeLineItem struct {
+	Kind       FeeLineKind `json:"kind"`
+	Hours      int         `json:"hours,omitempty"`
+	Rate       int         `json:"rate,omitempty"`
+	Multiplier float64     `json:"multiplier,omitempty"`
+	Amount     int         `json:"amount"`
+}
+
+// FeeBreakdown itemizes the fee of a stay, Total is the sum of the line amounts.
+type FeeBreakdown struct {
+	BillableHours int           `json:"billableHours"`
+	LineItems     []FeeLineItem `json:"lineItems"`
+	Total         int           `json:"total"`
+}
+
+// CalculateFee is the single pricing engine, used for receipts on unpark and for reports.
+// 1. Stays not longer than the grace period are free.
+// 2. Every started hour is billed, the first one at the first hour rate, hours starting on a weekend at weekend rates.
+// 3. Consecutive hours starting inside the night window are billed once at the night flat rate.
+// 4. Every 24 hours block since parkedAt is capped at the daily cap.
+// Hours are evaluated in the location of parkedAt. See feeLines for the itemized fee.
+func (p *PricingPolicy) CalculateFee(parkedAt, unparkedAt time.Time) int {
+	return sumFeeLines(p.feeLines(parkedAt, unparkedAt))
+}
+
+// CalculateVehicleFee is CalculateFee adjusted for the vehicle type, rounded to whole currency units.
+func (p *PricingPolicy) CalculateVehicleFee(vType VehicleType, parkedAt, unparkedAt time.Time) int {
+	return p.QuoteFee(vType, parkedAt, unparkedAt, nil).Total
+}
+
+// QuoteFee itemizes the fee of a stay of the vehicle type from parkedAt until. A stay that started under a permit is free until
+// permitEnd, when the permit expired or was revoked, the hours after it are charged at the rates they have in the stay.
+// permitEnd is nil for stays without a permit. Its total is what UnparkVehicle charges when unparking at until,
+// both go through this method. Night and weekend hours are read in parkedAt's location, the parking lot's time zone.
+func (p *PricingPolicy) QuoteFee(vType VehicleType, parkedAt, until time.Time, permitEnd *time.Time) FeeBreakdown {
+	lines, fee := p.vehicleFeeLines(vType, parkedAt, until)
+
+	if permitEnd != nil && fee > 0 {
+		covered := fee
+		if permitEnd.Before(until) {
+			_, coveredFee := p.vehicleFeeLines(vType, parkedAt, *permitEnd)
+			covered = min(coveredFee, fee)
+		}
+
+		if covered > 0 {
+			lines = append(lines, FeeLineItem{Kind: FeeLinePermit, Amount: -covered})
+			fee -= covered
+		}
+	}
+
+	return FeeBreakdown{BillableHours: billableHours(parkedAt, until), LineItems: lines, Total: fee}
+}
+
+// vehicleFeeLines itemizes the fee of a stay from parkedAt until adjusted for the vehicle type, and returns the fee.
+func (p *PricingPolicy) vehicleFeeLines(vType VehicleType, parkedAt, until time.Time) ([]FeeLineItem, int) {
+	lines := p.feeLines(parkedAt, until)
+	fee := sumFeeLines(lines)
+
+	if m, ok := p.VehicleTypeMultipliers[vType]; ok {
+		adjusted := int(math.Round(float64(fee) * m))
+		lines = append(lines, FeeLineItem{Kind: FeeLineVehicleType, Multiplier: m, Amount: adjusted - fee})
+		fee = adjusted
+	}
+
+	return lines, fee
+}
+
+// feeLines itemizes CalculateFee hour by hour, closing every 24 hours block with its daily cap line.
+func (p *PricingPolicy) feeLines(parkedAt, unparkedAt time.Time) []FeeLineItem {
+	hours := billableHours(parkedAt, unparkedAt)
+	if unparkedAt.Sub(parkedAt) <= time.Duration(p.GracePeriodMinutes)*time.Minute {
+		return []FeeLineItem{{Kind: FeeLineGracePeriod, Hours: hours}}
+	}
+
+	lines := make([]FeeLineItem, 0)
+	dayStart, dayTotal := 0, 0 // lines[dayStart:] belong to the current 24 hours block
+	night := -1                // index of the line of the ongoing night, -1 outside the night window
+
+	for h := 0; h < hours; h++ {
+		if h > 0 && h%24 == 0 {
+			lines = p.capDay(lines, dayTotal)
+			dayStart, dayTotal = len(lines), 0
+		}
+
+		start := parkedAt.Add(time.Duration(h) * time.Hour)
+		if p.isNight(start) {
+			if night < 0 {
+				lines = append(lines, FeeLineItem{Kind: FeeLineNightFlat, Rate: p.NightFlatRate, Amount: p.NightFlatRate})
+				dayTotal += p.NightFlatRate
+				night = len(lines) - 1
+			}
+
+			lines[night].Hours++
+			continue
+		}
+
+		night = -1
+		kind, rate := p.hourRate(h, start)
+		dayTotal += rate
+
+		if last := len(lines) - 1; last >= dayStart && lines[last].Kind == kind && lines[last].Rate == rate {
+			lines[last].Hours++
+			lines[last].Amount += rate
+			continue
+		}
+
+		lines = append(lines, FeeLineItem{Kind: kind, Hours: 1, Rate: rate, Amount: rate})
+	}
+
+	return p.capDay(lines, dayTotal)
+}
+
+func sumFeeLines(lines []FeeLineItem) int {
+	total := 0
+	for _, line := range lines {
+		total += line.Amount
+	}
+
+	return total
+}
+
+// hourRate returns the kind and rate of the h-th (zero based) billed hour starting at start.
+func (p *PricingPolicy) hourRate(h int, start time.Time) (FeeLineKind, int) {
+	weekend := start.Weekday() == time.Saturday || start.Weekday() == time.Sunday
+
+	if h == 0 {
+		if weekend && p.WeekendFirstHourRate > 0 {
+			return FeeLineWeekendFirstHour, p.WeekendFirstHourRate
+		}
+
+		if p.FirstHourRate > 0 {
+			return FeeLineFirstHour, p.FirstHourRate
+		}
+	}
+
+	if weekend && p.WeekendHourlyRate > 0 {
+		return FeeLineWeekendHourly, p.WeekendHourlyRate
+	}
+
+	return FeeLineHourly, p.HourlyRate
+}
+
+// isNight reports whether an hour starting at t falls in the night window, windows may wrap around midnight.
+func (p *PricingPolicy) isNight(t time.Time) bool {
+	if p.NightFlatRate == 0 || p.NightStartHour == p.NightEndHour {
+		return false
+	}
+
+	hour := t.Hour()
+	if p.NightStartHour < p.NightEndHour {
+		return hour >= p.NightStartHour && hour < p.NightEndHour
+	}
+
+	return hour >= p.NightStartHour || hour < p.NightEndHour
+}
+
+// capDay discounts the lines of a single 24 hours block totalling dayTotal down to the daily cap.
+func (p *PricingPolicy) capDay(lines []FeeLineItem, dayTotal int) []FeeLineItem {
+	if p.DailyCap > 0 && dayTotal > p.DailyCap {
+		return append(lines, FeeLineItem{Kind: FeeLineDailyCap, Rate: p.DailyCap, Amount: p.DailyCap - dayTotal})
+	}
+
+	return lines
+}
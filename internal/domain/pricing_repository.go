@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// getPricingPolicy loads the pricing policy of a parking lot, falls back to the default policy if none is stored.
+func getPricingPolicy(ctx context.Context, q queryer, l *slog.Logger, plID int, plUUID uuid.UUID) (*PricingPolicy, common.AppError) {
+	p := PricingPolicy{ParkingLotID: plUUID}
+	var multipliers []byte
+	err := q.QueryRowContext(ctx, `
+        SELECT version, hourly_rate, first_hour_rate, grace_period_minutes, daily_cap,
+               night_flat_rate, night_start_hour, night_end_hour, weekend_hourly_rate, weekend_first_hour_rate, currency,
+               vehicle_type_multipliers
+        FROM pricing_policies
+        WHERE parking_lot_id = $1`, plID).Scan(
+		&p.Version, &p.HourlyRate, &p.FirstHourRate, &p.GracePeriodMinutes, &p.DailyCap,
+		&p.NightFlatRate, &p.NightStartHour, &p.NightEndHour, &p.WeekendHourlyRate, &p.WeekendFirstHourRate, &p.Currency,
+		&multipliers)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPricingPolicy(plUUID), nil
+	} else if err != nil {
+		l.Error("error fetching pricing policy", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	if err = json.Unmarshal(multipliers, &p.VehicleTypeMultipliers); err != nil {
+		l.Error("error decoding vehicle type multipliers", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	if len(p.VehicleTypeMultipliers) == 0 {
+		p.VehicleTypeMultipliers = nil
+	}
+
+	return &p, nil
+}
+
+// GetPricingPolicy returns the pricing policy currently in effect for a parking lot.
+func (r *ParkingLotRepoDB) GetPricingPolicy(ctx context.Context, plUUID uuid.UUID) (*PricingPolicy, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return getPricingPolicy(ctx, r.db, r.l, plID, plUUID)
+}
+
+// SetPricingPolicy stores the pricing policy of a parking lot, replacing the existing one and bumping its version.
+// Fees of vehicles unparked afterwards are calculated with the new policy.
+func (r *ParkingLotRepoDB) SetPricingPolicy(ctx context.Context, plUUID uuid.UUID, policy *PricingPolicy) (*PricingPolicy, common.AppError) {
+	if appErr := policy.Validate(); appErr != nil {
+		return nil, appErr
+	}
+
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	multipliers := []byte("{}")
+	if len(policy.VehicleTypeMultipliers) > 0 {
+		var err error
+		if multipliers, err = json.Marshal(policy.VehicleTypeMultipliers); err != nil {
+			r.l.Error("error encoding vehicle type multipliers", "err", err)
+			return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO pricing_policies (parking_lot_id, hourly_rate, first_hour_rate, grace_period_minutes, daily_cap,
+                                      night_flat_rate, night_start_hour, night_end_hour, weekend_hourly_rate, weekend_first_hour_rate, currency,
+                                      vehicle_type_multipliers)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (parking_lot_id) DO UPDATE SET
+            version                  = pricing_policies.version + 1,
+            hourly_rate              = EXCLUDED.hourly_rate,
+            first_hour_rate          = EXCLUDED.first_hour_rate,
+            grace_period_minutes     = EXCLUDED.grace_period_minutes,
+            daily_cap                = EXCLUDED.daily_cap,
+            night_flat_rate          = EXCLUDED.night_flat_rate,
+            night_start_hour         = EXCLUDED.night_start_hour,
+            night_end_hour           = EXCLUDED.night_end_hour,
+            weekend_hourly_rate      = EXCLUDED.weekend_hourly_rate,
+            weekend_first_hour_rate  = EXCLUDED.weekend_first_hour_rate,
+            currency                 = EXCLUDED.currency,
+            vehicle_type_multipliers = EXCLUDED.vehicle_type_multipliers,
+            updated_at               = now()
+        RETURNING version`,
+		plID, policy.HourlyRate, policy.FirstHourRate, policy.GracePeriodMinutes, policy.DailyCap,
+		policy.NightFlatRate, policy.NightStartHour, policy.NightEndHour, policy.WeekendHourlyRate, policy.WeekendFirstHourRate, policy.Currency,
+		multipliers,
+	).Scan(&policy.Version)
+	if err != nil {
+		r.l.Error("error storing pricing policy", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	policy.ParkingLotID = plUUID
+	return policy, nil
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestCalculateFee tests the pricing engine shared by unpark and reports against every pricing rule.
+func TestCalculateFee(t *testing.T) {
+	wednesday := time.Date(2024, time.March, 13, 10, 0, 0, 0, time.UTC)
+	saturday := time.Date(2024, time.March, 16, 10, 0, 0, 0, time.UTC)
+	wednesdayEvening := time.Date(2024, time.March, 13, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   PricingPolicy
+		parkedAt time.Time
+		duration time.Duration
+		expected int
+	}{
+		{"default rate rounds up started hours", *DefaultPricingPolicy(uuid.Nil), wednesday, 150 * time.Minute, 30},
+		{"zero duration is free", *DefaultPricingPolicy(uuid.Nil), wednesday, 0, 0},
+		{"within grace period is free", PricingPolicy{HourlyRate: 10, GracePeriodMinutes: 15}, wednesday, 10 * time.Minute, 0},
+		{"after grace period is billed", PricingPolicy{HourlyRate: 10, GracePeriodMinutes: 15}, wednesday, 20 * time.Minute, 10},
+		{"first hour rate", PricingPolicy{HourlyRate: 10, FirstHourRate: 20}, wednesday, 3 * time.Hour, 40},
+		{"daily cap", PricingPolicy{HourlyRate: 10, DailyCap: 50}, wednesday, 8 * time.Hour, 50},
+		{"daily cap per 24 hours block", PricingPolicy{HourlyRate: 10, DailyCap: 50}, wednesday, 26 * time.Hour, 70},
+		{
+			"night flat rate wrapping midnight",
+			PricingPolicy{HourlyRate: 10, NightFlatRate: 30, NightStartHour: 22, NightEndHour: 6},
+			wednesdayEvening, 10 * time.Hour, 50,
+		},
+		{
+			"night flat rate without night window",
+			PricingPolicy{HourlyRate: 10, NightFlatRate: 30},
+			wednesdayEvening, 10 * time.Hour, 100,
+		},
+		{
+			"weekend rates",
+			PricingPolicy{HourlyRate: 10, FirstHourRate: 20, WeekendHourlyRate: 15, WeekendFirstHourRate: 25},
+			saturday, 3 * time.Hour, 55,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.policy.CalculateFee(tt.parkedAt, tt.parkedAt.Add(tt.duration))
+			if result != tt.expected {
+				t.Errorf("CalculateFee() returned %d; expected %d", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCalculateVehicleFee tests vehicle type multipliers scale the fee, types without one pay the full fee.
+func TestCalculateVehicleFee(t *testing.T) {
+	parkedAt := time.Date(2024, time.March, 13, 10, 0, 0, 0, time.UTC)
+	policy := PricingPolicy{
+		HourlyRate:             10,
+		VehicleTypeMultipliers: map[VehicleType]float64{VehicleTypeMotorcycle: 0.5, VehicleTypeTruck: 2.25},
+	}
+
+	tests := []struct {
+		vType    VehicleType
+		expected int
+	}{
+		{VehicleTypeMotorcycle, 15},
+		{VehicleTypeCar, 30},
+		{VehicleTypeTruck, 68},
+	}
+
+	for _, tt := range tests {
+		if result := policy.CalculateVehicleFee(tt.vType, parkedAt, parkedAt.Add(150*time.Minute)); result != tt.expected {
+			t.Errorf("CalculateVehicleFee(%s) = %d; expected %d", tt.vType, result, tt.expected)
+		}
+	}
+}
+
+// TestQuoteFee tests the line items of a fee breakdown add up to the fee charged on unpark.
+func TestQuoteFee(t *testing.T) {
+	wednesday := time.Date(2024, time.March, 13, 10, 0, 0, 0, time.UTC)
+	wednesdayEvening := time.Date(2024, time.March, 13, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   PricingPolicy
+		vType    VehicleType
+		parkedAt time.Time
+		duration time.Duration
+		permit   time.Duration // how long a permit covers the stay, 0 without one
+		expected []FeeLineItem
+	}{
+		{
+			"grace period", PricingPolicy{HourlyRate: 10, GracePeriodMinutes: 15}, VehicleTypeCar, wednesday, 10 * time.Minute, 0,
+			[]FeeLineItem{{Kind: FeeLineGracePeriod, Hours: 1}},
+		},
+		{
+			"first hour then hourly", PricingPolicy{HourlyRate: 10, FirstHourRate: 20}, VehicleTypeCar, wednesday, 150 * time.Minute, 0,
+			[]FeeLineItem{{Kind: FeeLineFirstHour, Hours: 1, Rate: 20, Amount: 20}, {Kind: FeeLineHourly, Hours: 2, Rate: 10, Amount: 20}},
+		},
+		{
+			"night flat rate and daily cap",
+			PricingPolicy{HourlyRate: 10, DailyCap: 60, NightFlatRate: 30, NightStartHour: 22, NightEndHour: 6},
+			VehicleTypeCar, wednesdayEvening, 26 * time.Hour, 0,
+			[]FeeLineItem{
+				{Kind: FeeLineHourly, Hours: 2, Rate: 10, Amount: 20},
+				{Kind: FeeLineNightFlat, Hours: 8, Rate: 30, Amount: 30},
+				{Kind: FeeLineHourly, Hours: 14, Rate: 10, Amount: 140},
+				{Kind: FeeLineDailyCap, Rate: 60, Amount: -130},
+				{Kind: FeeLineHourly, Hours: 2, Rate: 10, Amount: 20},
+			},
+		},
+		{
+			"vehicle type multiplier and permit",
+			PricingPolicy{HourlyRate: 10, VehicleTypeMultipliers: map[VehicleType]float64{VehicleTypeVan: 1.5}},
+			VehicleTypeVan, wednesday, 3 * time.Hour, 24 * time.Hour,
+			[]FeeLineItem{
+				{Kind: FeeLineHourly, Hours: 3, Rate: 10, Amount: 30},
+				{Kind: FeeLineVehicleType, Multiplier: 1.5, Amount: 15},
+				{Kind: FeeLinePermit, Amount: -45},
+			},
+		},
+		{
+			"permit ended during the stay",
+			PricingPolicy{HourlyRate: 10, FirstHourRate: 20, VehicleTypeMultipliers: map[VehicleType]float64{VehicleTypeVan: 1.5}},
+			VehicleTypeVan, wednesday, 3 * time.Hour, 90 * time.Minute,
+			[]FeeLineItem{
+				{Kind: FeeLineFirstHour, Hours: 1, Rate: 20, Amount: 20},
+				{Kind: FeeLineHourly, Hours: 2, Rate: 10, Amount: 20},
+				{Kind: FeeLineVehicleType, Multiplier: 1.5, Amount: 20},
+				{Kind: FeeLinePermit, Amount: -45},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until := tt.parkedAt.Add(tt.duration)
+
+			var permitEnd *time.Time
+			if tt.permit > 0 {
+				end := tt.parkedAt.Add(tt.permit)
+				permitEnd = &end
+			}
+
+			quote := tt.policy.QuoteFee(tt.vType, tt.parkedAt, until, permitEnd)
+			if !reflect.DeepEqual(quote.LineItems, tt.expected) {
+				t.Errorf("QuoteFee() returned line items %+v; expected %+v", quote.LineItems, tt.expected)
+			}
+
+			if sum := sumFeeLines(quote.LineItems); quote.Total != sum {
+				t.Errorf("QuoteFee() returned total %d; expected the sum of its line items %d", quote.Total, sum)
+			}
+
+			if permitEnd == nil && quote.Total != tt.policy.CalculateVehicleFee(tt.vType, tt.parkedAt, until) {
+				t.Errorf("QuoteFee() returned total %d; expected CalculateVehicleFee() %d", quote.Total,
+					tt.policy.CalculateVehicleFee(tt.vType, tt.parkedAt, until))
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package domain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// ReportGranularity is the length of the buckets of a ranged report, calendar aligned in the report's time zone,
+// so days around DST transitions last 23 or 25 hours. Weeks start on Monday.
+type ReportGranularity string
+
+const (
+	ReportGranularityHour  ReportGranularity = "hour"
+	ReportGranularityDay   ReportGranularity = "day"
+	ReportGranularityWeek  ReportGranularity = "week"
+	ReportGranularityMonth ReportGranularity = "month"
+)
+
+// MaxReportBuckets bounds the buckets of a ranged report, eg: 41 days by the hour.
+const MaxReportBuckets = 1000
+
+// ReportQuery selects the period of a ranged report. From is moved back to the start of its bucket and To forward to the end
+// of its bucket, so every bucket is a whole hour, day, week or month. Granularity defaults to ReportGranularityDay.
+// Location is the time zone of the buckets of a portfolio report, UTC when nil. Reports of a parking lot use the lot's time zone.
+// FromDate and ToDate take From and To as calendar dates, by their year, month and day, at midnight in that time zone.
+type ReportQuery struct {
+	From        time.Time
+	To          time.Time
+	FromDate    bool
+	ToDate      bool
+	Granularity ReportGranularity
+	Location    *time.Location
+}
+
+// RangeReport is the time series of a parking lot's usage over a period, one bucket per hour, day, week or month.
+type RangeReport struct {
+	ParkingLotID uuid.UUID         `json:"parkingLotId"`
+	TimeZone     string            `json:"timeZone"`
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	Granularity  ReportGranularity `json:"granularity"`
+	Buckets      []ReportBucket    `json:"buckets"`
+	Total        ReportBucket      `json:"total"`
+}
+
+// PortfolioReport is the time series of every parking lot combined, with the totals of each lot.
+// Peak occupancy of the combined buckets counts vehicles parked at the same time in any lot.
+type PortfolioReport struct {
+	TimeZone    string            `json:"timeZone"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Granularity ReportGranularity `json:"granularity"`
+	Buckets     []ReportBucket    `json:"buckets"`
+	Total       ReportBucket      `json:"total"`
+	Lots        []LotReport       `json:"lots"`
+}
+
+// LotReport is the totals of a parking lot in a PortfolioReport.
+type LotReport struct {
+	ParkingLotID uuid.UUID    `json:"parkingLotId"`
+	Name         string       `json:"name"`
+	Total        ReportBucket `json:"total"`
+}
+
+// ReportBucket totals the stays parked in [Start, End) like DailyReport: stays still parked count as vehicles without hours
+// or fees, and fees are the ones stored on unpark. AverageStaySeconds averages the stays already unparked.
+// PeakOccupancy is the most vehicles parked at the same time during the bucket, including the ones parked before it.
+type ReportBucket struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	VehiclesParked     int       `json:"vehiclesParked"`
+	ParkingHours       int       `json:"parkingHours"`
+	FeeCollected       int       `json:"feeCollected"`
+	AverageStaySeconds int64     `json:"averageStaySeconds"`
+	PeakOccupancy      int       `json:"peakOccupancy"`
+}
+
+// ParseReportGranularity returns the granularity named s, ReportGranularityDay for an empty s.
+func ParseReportGranularity(s string) (ReportGranularity, common.AppError) {
+	switch g := ReportGranularity(s); g {
+	case "":
+		return ReportGranularityDay, nil
+	case ReportGranularityHour, ReportGranularityDay, ReportGranularityWeek, ReportGranularityMonth:
+		return g, nil
+	default:
+		return "", common.NewBadRequestError("granularity must be hour, day, week or month, got " + s)
+	}
+}
+
+// truncate returns the start of the bucket t falls in, in loc.
+func (g ReportGranularity) truncate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch g {
+	case ReportGranularityHour:
+		// local hours, not t.Truncate(time.Hour): zones like Asia/Kolkata are offset by half an hour.
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case ReportGranularityWeek:
+		return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case ReportGranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next returns the start of the bucket after the one starting at start, in loc. Days follow the calendar, not 24 hours.
+func (g ReportGranularity) next(start time.Time, loc *time.Location) time.Time {
+	start = start.In(loc)
+	switch g {
+	case ReportGranularityHour:
+		return start.Add(time.Hour)
+	case ReportGranularityWeek:
+		return time.Date(start.Year(), start.Month(), start.Day()+7, 0, 0, 0, 0, loc)
+	case ReportGranularityMonth:
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+	}
+}
+
+// normalize applies the default granularity and time zone, aligns the period to whole buckets and validates it.
+func (q *ReportQuery) normalize() common.AppError {
+	g, appErr := ParseReportGranularity(string(q.Granularity))
+	if appErr != nil {
+		return appErr
+	}
+
+	if q.From.IsZero() || q.To.IsZero() {
+		return common.NewBadRequestError("from and to are required")
+	}
+
+	if q.Location == nil {
+		q.Location = time.UTC
+	}
+
+	if q.FromDate {
+		q.From, _ = localDay(q.From, q.Location)
+	}
+
+	if q.ToDate {
+		q.To, _ = localDay(q.To, q.Location)
+	}
+
+	if !q.To.After(q.From) {
+		return common.NewBadRequestError("to must be after from")
+	}
+
+	q.Granularity = g
+	q.From = g.truncate(q.From, q.Location)
+	if to := g.truncate(q.To, q.Location); to.Before(q.To) {
+		q.To = g.next(to, q.Location)
+	} else {
+		q.To = to
+	}
+
+	buckets := 0
+	for start := q.From; start.Before(q.To); start = g.next(start, q.Location) {
+		if buckets++; buckets > MaxReportBuckets {
+			return common.NewBadRequestError("report period is too long for its granularity, at most 1000 buckets")
+		}
+	}
+
+	return nil
+}
+
+// bucketStarts returns the start of every bucket of the normalized query, in UTC.
+func (q *ReportQuery) bucketStarts() []time.Time {
+	var starts []time.Time
+	for start := q.From; start.Before(q.To); start = q.Granularity.next(start, q.Location) {
+		starts = append(starts, start.UTC())
+	}
+
+	return starts
+}
+
+// InTimeZone renders every timestamp of the report in the parking lot's time zone instead of UTC.
+func (r *RangeReport) InTimeZone() {
+	loc := lotLocation(r.TimeZone)
+	r.From, r.To = r.From.In(loc), r.To.In(loc)
+	bucketsIn(r.Buckets, loc)
+	r.Total.Start, r.Total.End = r.Total.Start.In(loc), r.Total.End.In(loc)
+}
+
+// InTimeZone renders every timestamp of the report in its time zone instead of UTC.
+func (r *PortfolioReport) InTimeZone() {
+	loc := lotLocation(r.TimeZone)
+	r.From, r.To = r.From.In(loc), r.To.In(loc)
+	bucketsIn(r.Buckets, loc)
+	r.Total.Start, r.Total.End = r.Total.Start.In(loc), r.Total.End.In(loc)
+	for i := range r.Lots {
+		r.Lots[i].Total.Start, r.Lots[i].Total.End = r.Lots[i].Total.Start.In(loc), r.Lots[i].Total.End.In(loc)
+	}
+}
+
+func bucketsIn(buckets []ReportBucket, loc *time.Location) {
+	for i := range buckets {
+		buckets[i].Start, buckets[i].End = buckets[i].Start.In(loc), buckets[i].End.In(loc)
+	}
+}
+
+// bucketUsage is the usage of a parking lot, or of every lot, in the bucket-th bucket of a query, as aggregated by a backend.
+// firstChangeAt is when occupancy first changed in the bucket, nil if it never did, and closing the occupancy after the last change.
+type bucketUsage struct {
+	bucket        int
+	vehicles      int
+	hours         int
+	fee           int
+	stays         int
+	staySeconds   int64
+	firstChangeAt *time.Time
+	peak          int
+	closing       int
+}
+
+// newReportSeries lays out every bucket of the query period in UTC, filled from the usage of the buckets with activity,
+// and their total. The occupancy a bucket starts with is carried from the closing occupancy of the last bucket that changed it.
+func newReportSeries(q ReportQuery, usage []bucketUsage) ([]ReportBucket, ReportBucket) {
+	starts := q.bucketStarts()
+	byBucket := make(map[int]bucketUsage, len(usage))
+	for _, u := range usage {
+		byBucket[u.bucket] = u
+	}
+
+	total := ReportBucket{Start: q.From.UTC(), End: q.To.UTC()}
+	var totalStays int
+	var totalStaySeconds int64
+
+	buckets := make([]ReportBucket, 0, len(starts))
+	occupied := 0
+	for i, start := range starts {
+		u := byBucket[i]
+		b := ReportBucket{
+			Start:          start,
+			End:            total.End,
+			VehiclesParked: u.vehicles,
+			ParkingHours:   u.hours,
+			FeeCollected:   u.fee,
+			PeakOccupancy:  u.peak,
+		}
+
+		if i+1 < len(starts) {
+			b.End = starts[i+1]
+		}
+
+		if u.stays > 0 {
+			b.AverageStaySeconds = u.staySeconds / int64(u.stays)
+		}
+
+		// the occupancy carried in holds until the first change, unless the bucket starts with one.
+		if u.firstChangeAt == nil || u.firstChangeAt.After(start) {
+			b.PeakOccupancy = max(b.PeakOccupancy, occupied)
+		}
+
+		if u.firstChangeAt != nil {
+			occupied = u.closing
+		}
+
+		buckets = append(buckets, b)
+
+		total.VehiclesParked += b.VehiclesParked
+		total.ParkingHours += b.ParkingHours
+		total.FeeCollected += b.FeeCollected
+		total.PeakOccupancy = max(total.PeakOccupancy, b.PeakOccupancy)
+		totalStays += u.stays
+		totalStaySeconds += u.staySeconds
+	}
+
+	if totalStays > 0 {
+		total.AverageStaySeconds = totalStaySeconds / int64(totalStays)
+	}
+
+	return buckets, total
+}
+
+// aggregateStays computes the usage by bucket of the vehicles, the in-memory counterpart of ParkingLotRepoDB.rangeUsage.
+func aggregateStays(q ReportQuery, vehicles []*Vehicle) []bucketUsage {
+	starts := q.bucketStarts()
+	usage := make(map[int]*bucketUsage)
+	bucket := func(t time.Time) *bucketUsage {
+		i := sort.Search(len(starts), func(i int) bool { return starts[i].After(t) }) - 1
+		if usage[i] == nil {
+			usage[i] = &bucketUsage{bucket: i}
+		}
+
+		return usage[i]
+	}
+
+	changes := make(map[time.Time]int)
+	for _, v := range vehicles {
+		if !v.ParkedAt.Before(q.To) || (v.UnparkedAt != nil && !v.UnparkedAt.After(q.From)) {
+			continue
+		}
+
+		changes[maxTime(v.ParkedAt, q.From).UTC()]++
+		if v.UnparkedAt != nil && v.UnparkedAt.Before(q.To) {
+			changes[v.UnparkedAt.UTC()]--
+		}
+
+		if v.ParkedAt.Before(q.From) {
+			continue
+		}
+
+		u := bucket(v.ParkedAt)
+		u.vehicles++
+		if v.UnparkedAt != nil {
+			u.hours += billableHours(v.ParkedAt, *v.UnparkedAt)
+			u.fee += v.Fee
+			u.stays++
+			u.staySeconds += int64(v.UnparkedAt.Sub(v.ParkedAt) / time.Second)
+		}
+	}
+
+	// departures at the same instant as arrivals are netted, like the grouped changes of the postgres query.
+	instants := make([]time.Time, 0, len(changes))
+	for at := range changes {
+		instants = append(instants, at)
+	}
+
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+
+	occupied := 0
+	for _, at := range instants {
+		occupied += changes[at]
+		u := bucket(at)
+		if u.firstChangeAt == nil {
+			first := at
+			u.firstChangeAt, u.peak = &first, occupied
+		}
+
+		u.peak = max(u.peak, occupied)
+		u.closing = occupied
+	}
+
+	result := make([]bucketUsage, 0, len(usage))
+	for _, u := range usage {
+		result = append(result, *u)
+	}
+
+	return result
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
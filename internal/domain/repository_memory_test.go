@@ -0,0 +1,28 @@
+package domain_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/domain/repotest"
+)
+
+// TestRepositoriesMemory runs the repository conformance suite against the in-memory repositories.
+func TestRepositoriesMemory(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repotest.Run(t, func(_ *testing.T, now func() time.Time, platePolicy domain.PlatePolicy) repotest.Repositories {
+		store := domain.NewMemoryStore()
+		return repotest.Repositories{
+			Lots:         domain.NewParkingLotRepoMemory(store, logger).WithClock(now),
+			Vehicles:     domain.NewVehicleRepoMemory(store, logger).WithClock(now).WithPlatePolicy(platePolicy),
+			Reservations: domain.NewReservationRepoMemory(store, logger).WithClock(now),
+			Permits:      domain.NewPermitRepoMemory(store, logger).WithClock(now),
+			Sessions:     domain.NewSessionRepoMemory(store, logger).WithClock(now),
+			Analytics:    domain.NewAnalyticsRepoMemory(store, logger).WithClock(now),
+		}
+	})
+}
@@ -0,0 +1,2100 @@
+// Package repotest provides a conformance suite for domain.ParkingLotRepository, domain.VehicleRepository,
+// domain.ReservationRepository, domain.PermitRepository, domain.SessionRepository and domain.AnalyticsRepository, every backend must pass it so handlers behave the same regardless of storage.
+package repotest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// Repositories are the repositories of a backend, sharing the same storage.
+type Repositories struct {
+	Lots         domain.ParkingLotRepository
+	Vehicles     domain.VehicleRepository
+	Reservations domain.ReservationRepository
+	Permits      domain.PermitRepository
+	Sessions     domain.SessionRepository
+	Analytics    domain.AnalyticsRepository
+}
+
+// Factory returns repositories over fresh, empty storage sharing the same data, using now for every timestamp
+// and parking plates under platePolicy.
+type Factory func(t *testing.T, now func() time.Time, platePolicy domain.PlatePolicy) Repositories
+
+// Clock is a manually advanced clock, safe for concurrent use.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
+
+// suite is the state of a single conformance test: repositories over fresh storage and their clock.
+type suite struct {
+	t            *testing.T
+	ctx          context.Context
+	clock        *Clock
+	newRepos     Factory
+	lots         domain.ParkingLotRepository
+	vehicles     domain.VehicleRepository
+	reservations domain.ReservationRepository
+	permits      domain.PermitRepository
+	sessions     domain.SessionRepository
+	analytics    domain.AnalyticsRepository
+}
+
+// Run runs every conformance test as a subtest, each one against repositories from a new factory call.
+// Subtests run sequentially, so backends may share a single database.
+func Run(t *testing.T, newRepos Factory) {
+	tests := []struct {
+		name string
+		fn   func(s *suite)
+	}{
+		{"CreateParkingLot", testCreateParkingLot},
+		{"GetParkingLotStatus", testGetParkingLotStatus},
+		{"ParkNearestSlotOrdering", testParkNearestSlotOrdering},
+		{"ParkSkipsMaintenance", testParkSkipsMaintenance},
+		{"ParkFullLot", testParkFullLot},
+		{"ParkTwice", testParkTwice},
+		{"ParkUnknownLot", testParkUnknownLot},
+		{"UnparkUnknownPlate", testUnparkUnknownPlate},
+		{"UnparkOtherLot", testUnparkOtherLot},
+		{"PlatePolicyMultiLot", testPlatePolicyMultiLot},
+		{"CanonicalPlates", testCanonicalPlates},
+		{"UnparkFee", testUnparkFee},
+		{"DailyReport", testDailyReport},
+		{"RangeReport", testRangeReport},
+		{"PortfolioReport", testPortfolioReport},
+		{"TimeZoneReports", testTimeZoneReports},
+		{"PricingPolicy", testPricingPolicy},
+		{"SlotMaintenance", testSlotMaintenance},
+		{"MaintenancePagination", testMaintenancePagination},
+		{"Occupancy", testOccupancy},
+		{"CreateParkingLotSlotTypes", testCreateParkingLotSlotTypes},
+		{"ParkBestFittingSlot", testParkBestFittingSlot},
+		{"UnparkVehicleTypeFee", testUnparkVehicleTypeFee},
+		{"CreateParkingLotLayout", testCreateParkingLotLayout},
+		{"ParkLayoutPreferences", testParkLayoutPreferences},
+		{"AllocationStrategy", testAllocationStrategy},
+		{"AllocationRoundRobin", testAllocationRoundRobin},
+		{"AllocationLeastRecentlyUsed", testAllocationLeastRecentlyUsed},
+		{"AllocationRandom", testAllocationRandom},
+		{"AllocationFillByZone", testAllocationFillByZone},
+		{"ReservationParks", testReservationParks},
+		{"ReservationHoldsSlot", testReservationHoldsSlot},
+		{"ReservationConflicts", testReservationConflicts},
+		{"ReservationHoldExpiry", testReservationHoldExpiry},
+		{"ReservationCancel", testReservationCancel},
+		{"PermitParks", testPermitParks},
+		{"PermitEndsDuringStay", testPermitEndsDuringStay},
+		{"PermitConflicts", testPermitConflicts},
+		{"PermitRevoke", testPermitRevoke},
+		{"PermitDailyReport", testPermitDailyReport},
+		{"FindParkedVehicle", testFindParkedVehicle},
+		{"QuoteFee", testQuoteFee},
+		{"LotLocalPricing", testLotLocalPricing},
+		{"VehicleSessions", testVehicleSessions},
+		{"LotSessionsPagination", testLotSessionsPagination},
+		{"StreamSessions", testStreamSessions},
+		{"OccupancyAnalytics", testOccupancyAnalytics},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a Wednesday morning, far from day boundaries and weekends.
+			clock := &Clock{t: time.Date(2024, time.March, 13, 10, 0, 0, 0, time.UTC)}
+			s := &suite{
+				t:        t,
+				ctx:      context.Background(),
+				clock:    clock,
+				newRepos: newRepos,
+			}
+
+			s.usePlatePolicy(domain.PlatePolicySingleLot)
+			tt.fn(s)
+		})
+	}
+}
+
+// usePlatePolicy replaces the repositories with ones over fresh storage, parking plates under the plate policy.
+func (s *suite) usePlatePolicy(policy domain.PlatePolicy) {
+	repos := s.newRepos(s.t, s.clock.Now, policy)
+	s.lots, s.vehicles, s.reservations = repos.Lots, repos.Vehicles, repos.Reservations
+	s.permits, s.sessions, s.analytics = repos.Permits, repos.Sessions, repos.Analytics
+}
+
+// createLot creates a parking lot, failing the test on error.
+func (s *suite) createLot(name string, slots int) *domain.ParkingLot {
+	s.t.Helper()
+
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: name, DesiredSlots: slots})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot(%s) returned error %v", name, appErr)
+	}
+
+	return lot
+}
+
+// park parks a car, failing the test on error.
+func (s *suite) park(plUUID uuid.UUID, regNum string) *domain.Vehicle {
+	s.t.Helper()
+
+	return s.parkType(plUUID, regNum, domain.VehicleTypeCar)
+}
+
+// parkType parks a vehicle of the given type, failing the test on error.
+func (s *suite) parkType(plUUID uuid.UUID, regNum string, vType domain.VehicleType) *domain.Vehicle {
+	s.t.Helper()
+
+	return s.parkWith(plUUID, regNum, domain.ParkOptions{VehicleType: vType})
+}
+
+// parkWith parks a vehicle with the given options, failing the test on error.
+func (s *suite) parkWith(plUUID uuid.UUID, regNum string, opts domain.ParkOptions) *domain.Vehicle {
+	s.t.Helper()
+
+	v, appErr := s.vehicles.ParkVehicle(s.ctx, plUUID, regNum, opts)
+	if appErr != nil {
+		s.t.Fatalf("ParkVehicle(%s) returned error %v", regNum, appErr)
+	}
+
+	return v
+}
+
+// unpark unparks a vehicle from a parking lot, failing the test on error.
+func (s *suite) unpark(plUUID uuid.UUID, regNum string) *domain.Vehicle {
+	s.t.Helper()
+
+	v, appErr := s.vehicles.UnparkVehicle(s.ctx, plUUID, regNum)
+	if appErr != nil {
+		s.t.Fatalf("UnparkVehicle(%s) returned error %v", regNum, appErr)
+	}
+
+	return v
+}
+
+// expectCode fails the test unless appErr has the expected http status code.
+func (s *suite) expectCode(op string, appErr common.AppError, expected int) {
+	s.t.Helper()
+
+	if appErr == nil {
+		s.t.Errorf("%s returned no error; expected status %d", op, expected)
+		return
+	}
+
+	if appErr.Code() != expected {
+		s.t.Errorf("%s returned status %d (%v); expected %d", op, appErr.Code(), appErr, expected)
+	}
+}
+
+func testCreateParkingLot(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+
+	if lot.ID == uuid.Nil || len(lot.Slots) != 3 {
+		s.t.Fatalf("CreateParkingLot returned %+v; expected an ID and 3 slots", lot)
+	}
+
+	for i, slot := range lot.Slots {
+		if slot.SlotNumber != i+1 || slot.Type != domain.VehicleTypeCar || !slot.IsAvailable || slot.IsMaintenance || slot.ID == uuid.Nil {
+			s.t.Errorf("CreateParkingLot returned slot %+v; expected available car slot number %d", slot, i+1)
+		}
+	}
+
+	_, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 1", DesiredSlots: 1})
+	s.expectCode("CreateParkingLot with a duplicate name", appErr, http.StatusConflict)
+}
+
+func testGetParkingLotStatus(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	s.park(lot.ID, "ABC-123")
+
+	status, appErr := s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetParkingLotStatus returned error %v", appErr)
+	}
+
+	if status.ParkingLotID != lot.ID || status.Name != lot.Name || len(status.Slots) != 2 {
+		s.t.Fatalf("GetParkingLotStatus returned %+v; expected 2 slots of %s", status, lot.Name)
+	}
+
+	first, second := status.Slots[0], status.Slots[1]
+	if first.SlotID != lot.Slots[0].ID || first.RegistrationNum == nil || *first.RegistrationNum != "ABC123" || first.UnparkedAt != nil {
+		s.t.Errorf("GetParkingLotStatus returned first slot %+v; expected ABC123 parked", first)
+	}
+
+	if first.RawRegistrationNum == nil || *first.RawRegistrationNum != "ABC-123" {
+		s.t.Errorf("GetParkingLotStatus returned first slot %+v; expected the plate as entered, ABC-123", first)
+	}
+
+	if second.SlotID != lot.Slots[1].ID || second.RegistrationNum != nil || second.RawRegistrationNum != nil {
+		s.t.Errorf("GetParkingLotStatus returned second slot %+v; expected it empty", second)
+	}
+
+	_, appErr = s.lots.GetParkingLotStatus(s.ctx, uuid.New())
+	s.expectCode("GetParkingLotStatus of an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testParkNearestSlotOrdering(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+
+	for i, regNum := range []string{"ABC-1", "ABC-2", "ABC-3"} {
+		if v := s.park(lot.ID, regNum); v.SlotID != lot.Slots[i].ID {
+			s.t.Errorf("ParkVehicle(%s) chose slot %s; expected slot number %d", regNum, v.SlotID, i+1)
+		}
+	}
+
+	s.unpark(lot.ID, "ABC-3")
+	s.unpark(lot.ID, "ABC-2")
+
+	if v := s.park(lot.ID, "ABC-4"); v.SlotID != lot.Slots[1].ID {
+		s.t.Errorf("ParkVehicle chose slot %s; expected the lowest freed slot number 2", v.SlotID)
+	}
+}
+
+func testParkSkipsMaintenance(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	_, appErr := s.lots.SetSlotMaintenance(s.ctx, lot.ID, lot.Slots[0].ID,
+		domain.MaintenanceChange{InMaintenance: true, PerformedBy: "ops"})
+	if appErr != nil {
+		s.t.Fatalf("SetSlotMaintenance returned error %v", appErr)
+	}
+
+	if v := s.park(lot.ID, "ABC-1"); v.SlotID != lot.Slots[1].ID {
+		s.t.Errorf("ParkVehicle chose slot %s; expected slot number 2, slot 1 is under maintenance", v.SlotID)
+	}
+
+	_, appErr = s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-2", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle with only maintenance slots left", appErr, http.StatusConflict)
+}
+
+func testParkFullLot(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+	s.park(lot.ID, "ABC-1")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-2", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle in a full lot", appErr, http.StatusConflict)
+
+	s.unpark(lot.ID, "ABC-1")
+	s.park(lot.ID, "ABC-2")
+}
+
+func testParkTwice(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 2)
+	s.park(lot.ID, "ABC-1")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle of an already parked vehicle", appErr, http.StatusConflict)
+
+	_, appErr = s.vehicles.ParkVehicle(s.ctx, other.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle of a vehicle parked in another lot", appErr, http.StatusConflict)
+}
+
+func testParkUnknownLot(s *suite) {
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, uuid.New(), "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle in an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testUnparkUnknownPlate(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+
+	_, appErr := s.vehicles.UnparkVehicle(s.ctx, lot.ID, "ABC-1")
+	s.expectCode("UnparkVehicle of an unknown plate", appErr, http.StatusConflict)
+
+	s.park(lot.ID, "ABC-1")
+	s.unpark(lot.ID, "ABC-1")
+
+	_, appErr = s.vehicles.UnparkVehicle(s.ctx, lot.ID, "ABC-1")
+	s.expectCode("UnparkVehicle of an already unparked vehicle", appErr, http.StatusConflict)
+
+	_, appErr = s.vehicles.UnparkVehicle(s.ctx, uuid.New(), "ABC-1")
+	s.expectCode("UnparkVehicle from an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testUnparkOtherLot(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+	other := s.createLot("Parking Lot 2", 1)
+	s.park(lot.ID, "ABC-1")
+
+	_, appErr := s.vehicles.UnparkVehicle(s.ctx, other.ID, "ABC-1")
+	s.expectCode("UnparkVehicle from another lot", appErr, http.StatusConflict)
+
+	if appErr != nil && appErr.Error() != common.ErrVehicleParkedInOther {
+		s.t.Errorf("UnparkVehicle from another lot returned %q; expected %q", appErr.Error(), common.ErrVehicleParkedInOther)
+	}
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.UnparkedAt == nil {
+		s.t.Errorf("UnparkVehicle returned %+v; expected the vehicle still parked in its own lot", v)
+	}
+}
+
+func testPlatePolicyMultiLot(s *suite) {
+	s.usePlatePolicy(domain.PlatePolicyMultiLot)
+
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 2)
+	s.park(lot.ID, "ABC-1")
+	s.clock.Advance(time.Hour)
+	s.park(other.ID, "ABC-1")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle of a vehicle already parked in the lot", appErr, http.StatusConflict)
+
+	if pv, appErr := s.vehicles.FindParkedVehicle(s.ctx, "ABC-1"); appErr != nil || pv.ParkingLotID != other.ID {
+		s.t.Errorf("FindParkedVehicle returned %+v, %v; expected the most recent stay in %s", pv, appErr, other.ID)
+	}
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.Fee != 10 {
+		s.t.Errorf("UnparkVehicle returned fee %d; expected 10 for the hour in the first lot", v.Fee)
+	}
+
+	_, appErr = s.vehicles.UnparkVehicle(s.ctx, lot.ID, "ABC-1")
+	s.expectCode("UnparkVehicle of a vehicle parked in another lot", appErr, http.StatusConflict)
+
+	if quote, appErr := s.vehicles.QuoteFee(s.ctx, other.ID, "ABC-1", time.Time{}); appErr != nil || quote.ParkingLotID != other.ID {
+		s.t.Errorf("QuoteFee returned %+v, %v; expected a quote of the stay in %s", quote, appErr, other.ID)
+	}
+
+	s.unpark(other.ID, "ABC-1")
+}
+
+func testCanonicalPlates(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	parked := s.park(lot.ID, " abc 123 ")
+	if parked.RegistrationNumber != "ABC123" || parked.RawRegistrationNumber != "abc 123" {
+		s.t.Errorf("ParkVehicle returned plate %q, raw %q; expected ABC123 entered as \"abc 123\"", parked.RegistrationNumber,
+			parked.RawRegistrationNumber)
+	}
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-123", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle of the same plate written differently", appErr, http.StatusConflict)
+
+	_, appErr = s.vehicles.ParkVehicle(s.ctx, lot.ID, " - ", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle without a letter or digit", appErr, http.StatusBadRequest)
+
+	if pv, appErr := s.vehicles.FindParkedVehicle(s.ctx, "ＡＢＣ１２３"); appErr != nil || pv.ID != parked.ID {
+		s.t.Errorf("FindParkedVehicle of the fullwidth plate returned %+v, %v; expected %s", pv, appErr, parked.ID)
+	}
+
+	if v := s.unpark(lot.ID, "Abc.123"); v.ID != parked.ID || v.RawRegistrationNumber != "abc 123" {
+		s.t.Errorf("UnparkVehicle returned %+v; expected %s entered as \"abc 123\"", v, parked.ID)
+	}
+
+	page, appErr := s.sessions.ListVehicleSessions(s.ctx, "abc123", domain.SessionQuery{})
+	if appErr != nil || len(page.Sessions) != 1 || page.Sessions[0].RawRegistrationNumber != "abc 123" {
+		s.t.Errorf("ListVehicleSessions returned %+v, %v; expected the session entered as \"abc 123\"", page, appErr)
+	}
+}
+
+func testUnparkFee(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+	parked := s.park(lot.ID, "ABC-1")
+
+	s.clock.Advance(150 * time.Minute)
+	v := s.unpark(lot.ID, "ABC-1")
+
+	if v.ID != parked.ID || v.SlotID != parked.SlotID || v.UnparkedAt == nil {
+		s.t.Fatalf("UnparkVehicle returned %+v; expected the parked vehicle %+v with unparkedAt", v, parked)
+	}
+
+	if !v.UnparkedAt.Equal(s.clock.Now()) || !v.ParkedAt.Equal(parked.ParkedAt) {
+		s.t.Errorf("UnparkVehicle returned parkedAt %s, unparkedAt %s; expected %s, %s",
+			v.ParkedAt, v.UnparkedAt, parked.ParkedAt, s.clock.Now())
+	}
+
+	if v.Fee != 30 || v.Currency != "USD" || v.PricingPolicyVersion != 0 {
+		s.t.Errorf("UnparkVehicle returned fee %d %s (policy v%d); expected 30 USD (policy v0) for 3 started hours",
+			v.Fee, v.Currency, v.PricingPolicyVersion)
+	}
+}
+
+func testDailyReport(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+	other := s.createLot("Parking Lot 2", 1)
+	day := s.clock.Now().Truncate(24 * time.Hour)
+
+	// parked the day before, excluded from the report.
+	s.clock.Advance(-24 * time.Hour)
+	s.park(lot.ID, "OLD-1")
+	s.clock.Advance(time.Hour)
+	s.unpark(lot.ID, "OLD-1")
+	s.clock.Advance(23 * time.Hour)
+
+	// 10:00 -> 12:30, 3 started hours and 30 fee.
+	s.park(lot.ID, "ABC-1")
+	s.park(other.ID, "OTHER-1")
+	s.clock.Advance(150 * time.Minute)
+	s.unpark(lot.ID, "ABC-1")
+
+	// 12:30 -> 13:10, 1 started hour and 10 fee, then a pricing change which must not alter the stored fee.
+	s.park(lot.ID, "ABC-2")
+	s.clock.Advance(40 * time.Minute)
+	s.unpark(lot.ID, "ABC-2")
+	if _, appErr := s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{HourlyRate: 100}); appErr != nil {
+		s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+	}
+
+	// still parked, counted without hours or fees.
+	s.park(lot.ID, "ABC-3")
+
+	report, appErr := s.lots.GetDailyReport(s.ctx, lot.ID, day)
+	if appErr != nil {
+		s.t.Fatalf("GetDailyReport returned error %v", appErr)
+	}
+
+	expected := domain.DailyReport{
+		TotalVehiclesParked: 3,
+		TotalParkingHours:   4,
+		TotalFeeCollected:   40,
+		Transient:           domain.UsageReport{VehiclesParked: 3, ParkingHours: 4, FeeCollected: 40},
+	}
+	if *report != expected {
+		s.t.Errorf("GetDailyReport returned %+v; expected %+v", *report, expected)
+	}
+
+	_, appErr = s.lots.GetDailyReport(s.ctx, uuid.New(), day)
+	s.expectCode("GetDailyReport of an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testRangeReport(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+	other := s.createLot("Parking Lot 2", 1)
+	at := func(hour, minute int) time.Time { return time.Date(2024, time.March, 13, hour, minute, 0, 0, time.UTC) }
+
+	// parked at 07:00, before the period, it counts towards occupancy only.
+	s.clock.Advance(-3 * time.Hour)
+	s.park(lot.ID, "PRE-1")
+	s.clock.Advance(3 * time.Hour)
+
+	// 10:00 -> 10:30 and 10:00 -> 12:00, 1 and 2 started hours.
+	s.park(lot.ID, "ABC-1")
+	s.park(lot.ID, "ABC-2")
+	s.park(other.ID, "OTHER-1")
+	s.clock.Advance(30 * time.Minute)
+	s.unpark(lot.ID, "ABC-1")
+
+	// PRE-1 leaves as ABC-3 arrives at 11:15, occupancy stays at 2.
+	s.clock.Advance(45 * time.Minute)
+	s.unpark(lot.ID, "PRE-1")
+	s.park(lot.ID, "ABC-3")
+
+	// ABC-2 leaves right as the 12:00 bucket starts, which never has more than ABC-3 parked.
+	s.clock.Advance(45 * time.Minute)
+	s.unpark(lot.ID, "ABC-2")
+
+	report, appErr := s.lots.GetRangeReport(s.ctx, lot.ID, domain.ReportQuery{
+		From:        at(9, 30),
+		To:          at(12, 59),
+		Granularity: domain.ReportGranularityHour,
+	})
+	if appErr != nil {
+		s.t.Fatalf("GetRangeReport returned error %v", appErr)
+	}
+
+	expected := domain.RangeReport{
+		ParkingLotID: lot.ID,
+		TimeZone:     "UTC",
+		From:         at(9, 0),
+		To:           at(13, 0),
+		Granularity:  domain.ReportGranularityHour,
+		Buckets: []domain.ReportBucket{
+			{Start: at(9, 0), End: at(10, 0), PeakOccupancy: 1},
+			{Start: at(10, 0), End: at(11, 0), VehiclesParked: 2, ParkingHours: 3, FeeCollected: 30, AverageStaySeconds: 4500, PeakOccupancy: 3},
+			{Start: at(11, 0), End: at(12, 0), VehiclesParked: 1, PeakOccupancy: 2},
+			{Start: at(12, 0), End: at(13, 0), PeakOccupancy: 1},
+		},
+		Total: domain.ReportBucket{
+			Start: at(9, 0), End: at(13, 0), VehiclesParked: 3, ParkingHours: 3, FeeCollected: 30, AverageStaySeconds: 4500, PeakOccupancy: 3,
+		},
+	}
+	if !reflect.DeepEqual(*report, expected) {
+		s.t.Errorf("GetRangeReport returned %+v; expected %+v", *report, expected)
+	}
+
+	_, appErr = s.lots.GetRangeReport(s.ctx, uuid.New(), domain.ReportQuery{From: at(9, 0), To: at(13, 0)})
+	s.expectCode("GetRangeReport of an unknown lot", appErr, http.StatusNotFound)
+
+	_, appErr = s.lots.GetRangeReport(s.ctx, lot.ID, domain.ReportQuery{From: at(13, 0), To: at(9, 0)})
+	s.expectCode("GetRangeReport ending before it starts", appErr, http.StatusBadRequest)
+
+	_, appErr = s.lots.GetRangeReport(s.ctx, lot.ID, domain.ReportQuery{From: at(9, 0), To: at(13, 0), Granularity: "year"})
+	s.expectCode("GetRangeReport of an unknown granularity", appErr, http.StatusBadRequest)
+}
+
+func testPortfolioReport(s *suite) {
+	lotB := s.createLot("Parking Lot B", 1)
+	lotA := s.createLot("Parking Lot A", 1)
+	lotC := s.createLot("Parking Lot C", 1)
+	day := s.clock.Now().Truncate(24 * time.Hour)
+
+	// 10:00 -> 11:00 in A, 10:30 onwards in B, both parked from 10:30 to 11:00.
+	s.park(lotA.ID, "ABC-1")
+	s.clock.Advance(30 * time.Minute)
+	s.park(lotB.ID, "ABC-2")
+	s.clock.Advance(30 * time.Minute)
+	s.unpark(lotA.ID, "ABC-1")
+
+	report, appErr := s.lots.GetPortfolioReport(s.ctx, domain.ReportQuery{From: day, To: day.Add(time.Hour)})
+	if appErr != nil {
+		s.t.Fatalf("GetPortfolioReport returned error %v", appErr)
+	}
+
+	total := domain.ReportBucket{
+		Start: day, End: day.AddDate(0, 0, 1), VehiclesParked: 2, ParkingHours: 1, FeeCollected: 10, AverageStaySeconds: 3600, PeakOccupancy: 2,
+	}
+	expected := domain.PortfolioReport{
+		TimeZone:    "UTC",
+		From:        day,
+		To:          day.AddDate(0, 0, 1),
+		Granularity: domain.ReportGranularityDay,
+		Buckets:     []domain.ReportBucket{total},
+		Total:       total,
+		Lots: []domain.LotReport{
+			{ParkingLotID: lotA.ID, Name: "Parking Lot A", Total: domain.ReportBucket{
+				Start: day, End: day.AddDate(0, 0, 1), VehiclesParked: 1, ParkingHours: 1, FeeCollected: 10, AverageStaySeconds: 3600, PeakOccupancy: 1,
+			}},
+			{ParkingLotID: lotB.ID, Name: "Parking Lot B", Total: domain.ReportBucket{
+				Start: day, End: day.AddDate(0, 0, 1), VehiclesParked: 1, PeakOccupancy: 1,
+			}},
+			{ParkingLotID: lotC.ID, Name: "Parking Lot C", Total: domain.ReportBucket{Start: day, End: day.AddDate(0, 0, 1)}},
+		},
+	}
+	if !reflect.DeepEqual(*report, expected) {
+		s.t.Errorf("GetPortfolioReport returned %+v; expected %+v", *report, expected)
+	}
+}
+
+func testTimeZoneReports(s *suite) {
+	_, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 1", DesiredSlots: 1, TimeZone: "Mars/Olympus"})
+	s.expectCode("CreateParkingLot with an unknown time zone", appErr, http.StatusBadRequest)
+
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 1", DesiredSlots: 2, TimeZone: "America/New_York"})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	status, appErr := s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil || status.TimeZone != "America/New_York" {
+		s.t.Fatalf("GetParkingLotStatus returned %+v, %v; expected the America/New_York time zone", status, appErr)
+	}
+
+	// 2024-03-13 03:00 UTC is 23:00 on the 12th in New York, 05:30 UTC is 01:30 on the 13th.
+	s.clock.Advance(-7 * time.Hour)
+	s.park(lot.ID, "ABC-1")
+	s.clock.Advance(150 * time.Minute)
+	s.unpark(lot.ID, "ABC-1")
+
+	for _, tt := range []struct {
+		date     time.Time
+		expected int
+	}{
+		{time.Date(2024, time.March, 12, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC), 0},
+	} {
+		report, appErr := s.lots.GetDailyReport(s.ctx, lot.ID, tt.date)
+		if appErr != nil || report.TotalVehiclesParked != tt.expected {
+			s.t.Errorf("GetDailyReport(%s) returned %+v, %v; expected %d vehicles", tt.date.Format("2006-01-02"), report, appErr, tt.expected)
+		}
+	}
+
+	// clocks moved forward on 2024-03-10 in New York, that day lasts 23 hours.
+	report, appErr := s.lots.GetRangeReport(s.ctx, lot.ID, domain.ReportQuery{
+		From: time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, time.March, 11, 12, 0, 0, 0, time.UTC),
+	})
+	if appErr != nil {
+		s.t.Fatalf("GetRangeReport returned error %v", appErr)
+	}
+
+	starts := []time.Time{
+		time.Date(2024, time.March, 9, 5, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 10, 5, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 11, 4, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 12, 4, 0, 0, 0, time.UTC),
+	}
+	if report.TimeZone != "America/New_York" || len(report.Buckets) != 3 {
+		s.t.Fatalf("GetRangeReport returned %+v; expected 3 days in America/New_York", report)
+	}
+
+	for i, b := range report.Buckets {
+		if !b.Start.Equal(starts[i]) || !b.End.Equal(starts[i+1]) {
+			s.t.Errorf("GetRangeReport returned bucket %d from %s to %s; expected %s to %s", i, b.Start, b.End, starts[i], starts[i+1])
+		}
+	}
+
+	// dates are local midnights, the 12th and 13th in New York.
+	report, appErr = s.lots.GetRangeReport(s.ctx, lot.ID, domain.ReportQuery{
+		From:     time.Date(2024, time.March, 12, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC),
+		FromDate: true,
+		ToDate:   true,
+	})
+	if appErr != nil || len(report.Buckets) != 2 || report.Buckets[0].VehiclesParked != 1 || report.Buckets[1].PeakOccupancy != 1 {
+		s.t.Errorf("GetRangeReport returned %+v, %v; expected ABC-1 parked on the 12th and staying into the 13th, local time", report, appErr)
+	}
+
+	page, appErr := s.sessions.ListLotSessions(s.ctx, lot.ID, domain.SessionQuery{})
+	if appErr != nil || len(page.Sessions) != 1 || page.Sessions[0].TimeZone != "America/New_York" {
+		s.t.Fatalf("ListLotSessions returned %+v, %v; expected the session in America/New_York", page, appErr)
+	}
+
+	page.InTimeZone()
+	if parkedAt := page.Sessions[0].ParkedAt; parkedAt.Format(time.RFC3339) != "2024-03-12T23:00:00-04:00" {
+		s.t.Errorf("SessionPage.InTimeZone rendered the park time as %s; expected 2024-03-12T23:00:00-04:00", parkedAt.Format(time.RFC3339))
+	}
+}
+
+func testPricingPolicy(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+
+	policy, appErr := s.lots.GetPricingPolicy(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetPricingPolicy returned error %v", appErr)
+	}
+
+	if !reflect.DeepEqual(policy, domain.DefaultPricingPolicy(lot.ID)) {
+		s.t.Errorf("GetPricingPolicy returned %+v; expected the default policy", policy)
+	}
+
+	for version := 1; version <= 2; version++ {
+		policy, appErr = s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{HourlyRate: 20, FirstHourRate: 50})
+		if appErr != nil {
+			s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+		}
+
+		if policy.Version != version || policy.ParkingLotID != lot.ID || policy.Currency != "USD" {
+			s.t.Errorf("SetPricingPolicy returned %+v; expected version %d in USD", policy, version)
+		}
+	}
+
+	s.park(lot.ID, "ABC-1")
+	s.clock.Advance(90 * time.Minute)
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.Fee != 70 || v.PricingPolicyVersion != 2 {
+		s.t.Errorf("UnparkVehicle returned fee %d (policy v%d); expected 70 (policy v2)", v.Fee, v.PricingPolicyVersion)
+	}
+
+	_, appErr = s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{HourlyRate: -1})
+	s.expectCode("SetPricingPolicy with a negative rate", appErr, http.StatusBadRequest)
+
+	_, appErr = s.lots.GetPricingPolicy(s.ctx, uuid.New())
+	s.expectCode("GetPricingPolicy of an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testSlotMaintenance(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+	slotID := lot.Slots[0].ID
+	s.park(lot.ID, "ABC-1")
+
+	enter := domain.MaintenanceChange{InMaintenance: true, PerformedBy: "ops", Reason: "broken sensor"}
+	exit := domain.MaintenanceChange{InMaintenance: false, PerformedBy: "ops"}
+
+	_, appErr := s.lots.SetSlotMaintenance(s.ctx, lot.ID, slotID, enter)
+	s.expectCode("SetSlotMaintenance of an occupied slot", appErr, http.StatusConflict)
+
+	_, appErr = s.lots.SetSlotMaintenance(s.ctx, lot.ID, slotID, exit)
+	s.expectCode("SetSlotMaintenance exit of a slot not under maintenance", appErr, http.StatusConflict)
+
+	_, appErr = s.lots.SetSlotMaintenance(s.ctx, other.ID, slotID, enter)
+	s.expectCode("SetSlotMaintenance of another lot's slot", appErr, http.StatusNotFound)
+
+	enter.Force = true
+	slot, appErr := s.lots.SetSlotMaintenance(s.ctx, lot.ID, slotID, enter)
+	if appErr != nil {
+		s.t.Fatalf("SetSlotMaintenance with force returned error %v", appErr)
+	}
+
+	if !slot.IsMaintenance || slot.IsAvailable || slot.SlotNumber != 1 {
+		s.t.Errorf("SetSlotMaintenance returned %+v; expected occupied slot 1 under maintenance", slot)
+	}
+
+	_, appErr = s.lots.SetSlotMaintenance(s.ctx, lot.ID, slotID, enter)
+	s.expectCode("SetSlotMaintenance of a slot already under maintenance", appErr, http.StatusConflict)
+
+	status, appErr := s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil || len(status.Maintenance) != 1 || !status.Maintenance[0].Forced || status.Maintenance[0].EndedAt != nil {
+		s.t.Fatalf("GetParkingLotStatus returned maintenance %+v, %v; expected the ongoing forced window", status, appErr)
+	}
+
+	s.clock.Advance(time.Hour)
+	if _, appErr = s.lots.SetSlotMaintenance(s.ctx, lot.ID, slotID, exit); appErr != nil {
+		s.t.Fatalf("SetSlotMaintenance exit returned error %v", appErr)
+	}
+
+	// forcing a free slot overrides nothing, the window isn't recorded as forced.
+	s.clock.Advance(time.Hour)
+	if _, appErr = s.lots.SetSlotMaintenance(s.ctx, lot.ID, lot.Slots[1].ID, enter); appErr != nil {
+		s.t.Fatalf("SetSlotMaintenance of a free slot returned error %v", appErr)
+	}
+
+	// the status only lists ongoing windows, ended ones are in the history.
+	status, appErr = s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil || len(status.Maintenance) != 1 || status.Maintenance[0].SlotID != lot.Slots[1].ID {
+		s.t.Fatalf("GetParkingLotStatus returned maintenance %+v, %v; expected only the ongoing window of slot 2", status, appErr)
+	}
+
+	page, appErr := s.lots.ListMaintenanceWindows(s.ctx, lot.ID, domain.MaintenanceQuery{})
+	if appErr != nil || len(page.Windows) != 2 || page.NextCursor != "" || page.TimeZone != "UTC" {
+		s.t.Fatalf("ListMaintenanceWindows returned %+v, %v; expected both windows on a single page", page, appErr)
+	}
+
+	ongoing, ended := page.Windows[0], page.Windows[1]
+	if ongoing.SlotID != lot.Slots[1].ID || ongoing.Forced || ongoing.EndedAt != nil {
+		s.t.Errorf("ListMaintenanceWindows returned newest window %+v; expected slot 2 ongoing and not forced", ongoing)
+	}
+
+	if ended.SlotID != slotID || ended.Reason != "broken sensor" || ended.StartedBy != "ops" || !ended.Forced ||
+		ended.EndedAt == nil || ended.EndedAt.Sub(ended.StartedAt) != time.Hour {
+		s.t.Errorf("ListMaintenanceWindows returned oldest window %+v; expected a closed forced 1 hour window", ended)
+	}
+
+	_, appErr = s.lots.ListMaintenanceWindows(s.ctx, uuid.New(), domain.MaintenanceQuery{})
+	s.expectCode("ListMaintenanceWindows of an unknown lot", appErr, http.StatusNotFound)
+
+	_, appErr = s.lots.ListMaintenanceWindows(s.ctx, lot.ID, domain.MaintenanceQuery{Cursor: "not a cursor"})
+	s.expectCode("ListMaintenanceWindows with an invalid cursor", appErr, http.StatusBadRequest)
+}
+
+// testMaintenancePagination tests the maintenance history pages newest first without gaps or repeats, and other lots' windows
+// are left out.
+func testMaintenancePagination(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+	other := s.createLot("Parking Lot 2", 1)
+	enter := domain.MaintenanceChange{InMaintenance: true, PerformedBy: "ops"}
+	exit := domain.MaintenanceChange{InMaintenance: false, PerformedBy: "ops"}
+
+	var started []time.Time
+	for range 5 {
+		started = append(started, s.clock.Now())
+		for _, l := range []*domain.ParkingLot{lot, other} {
+			if _, appErr := s.lots.SetSlotMaintenance(s.ctx, l.ID, l.Slots[0].ID, enter); appErr != nil {
+				s.t.Fatalf("SetSlotMaintenance returned error %v", appErr)
+			}
+		}
+
+		s.clock.Advance(time.Hour)
+		if _, appErr := s.lots.SetSlotMaintenance(s.ctx, lot.ID, lot.Slots[0].ID, exit); appErr != nil {
+			s.t.Fatalf("SetSlotMaintenance exit returned error %v", appErr)
+		}
+
+		if _, appErr := s.lots.SetSlotMaintenance(s.ctx, other.ID, other.Slots[0].ID, exit); appErr != nil {
+			s.t.Fatalf("SetSlotMaintenance exit returned error %v", appErr)
+		}
+	}
+
+	var got []time.Time
+	q := domain.MaintenanceQuery{Limit: 2}
+	for pages := 1; ; pages++ {
+		page, appErr := s.lots.ListMaintenanceWindows(s.ctx, lot.ID, q)
+		if appErr != nil {
+			s.t.Fatalf("ListMaintenanceWindows returned error %v", appErr)
+		}
+
+		for _, w := range page.Windows {
+			got = append(got, w.StartedAt)
+		}
+
+		if page.NextCursor == "" {
+			if pages != 3 {
+				s.t.Errorf("ListMaintenanceWindows returned %d pages; expected 3 pages of 2", pages)
+			}
+
+			break
+		}
+
+		q.Cursor = page.NextCursor
+	}
+
+	slices.Reverse(started)
+	if !slices.EqualFunc(got, started, time.Time.Equal) {
+		s.t.Errorf("ListMaintenanceWindows paged windows started at %v; expected %v", got, started)
+	}
+}
+
+func testOccupancy(s *suite) {
+	lot := s.createLot("Parking Lot B", 3)
+	other := s.createLot("Parking Lot A", 1)
+	s.park(lot.ID, "ABC-1")
+
+	enter := domain.MaintenanceChange{InMaintenance: true, Force: true, PerformedBy: "ops"}
+	for _, slot := range lot.Slots[:2] {
+		if _, appErr := s.lots.SetSlotMaintenance(s.ctx, lot.ID, slot.ID, enter); appErr != nil {
+			s.t.Fatalf("SetSlotMaintenance returned error %v", appErr)
+		}
+	}
+
+	occupancy, appErr := s.lots.GetOccupancy(s.ctx)
+	if appErr != nil {
+		s.t.Fatalf("GetOccupancy returned error %v", appErr)
+	}
+
+	expected := []domain.LotOccupancy{
+		{ParkingLotID: other.ID, Name: other.Name, Available: 1},
+		{ParkingLotID: lot.ID, Name: lot.Name, Occupied: 1, Available: 1, Maintenance: 2},
+	}
+
+	if len(occupancy) != len(expected) {
+		s.t.Fatalf("GetOccupancy returned %+v; expected %+v", occupancy, expected)
+	}
+
+	for i := range expected {
+		if occupancy[i] != expected[i] {
+			s.t.Errorf("GetOccupancy returned %+v; expected %+v", occupancy[i], expected[i])
+		}
+	}
+}
+
+func testCreateParkingLotSlotTypes(s *suite) {
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{
+		Name:      "Parking Lot 1",
+		SlotTypes: map[domain.VehicleType]int{domain.VehicleTypeEV: 1, domain.VehicleTypeCar: 2, domain.VehicleTypeMotorcycle: 1},
+	})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	expected := []domain.VehicleType{domain.VehicleTypeMotorcycle, domain.VehicleTypeCar, domain.VehicleTypeCar, domain.VehicleTypeEV}
+	if lot.DesiredSlots != len(expected) || len(lot.Slots) != len(expected) {
+		s.t.Fatalf("CreateParkingLot returned %+v; expected %d slots", lot, len(expected))
+	}
+
+	for i, slot := range lot.Slots {
+		if slot.SlotNumber != i+1 || slot.Type != expected[i] {
+			s.t.Errorf("CreateParkingLot returned slot %d of type %s; expected slot %d of type %s", slot.SlotNumber, slot.Type, i+1, expected[i])
+		}
+	}
+
+	status, appErr := s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetParkingLotStatus returned error %v", appErr)
+	}
+
+	if status.Slots[3].SlotType != domain.VehicleTypeEV {
+		s.t.Errorf("GetParkingLotStatus returned slot type %s; expected %s", status.Slots[3].SlotType, domain.VehicleTypeEV)
+	}
+
+	_, appErr = s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 2", SlotTypes: map[domain.VehicleType]int{"bus": 1}})
+	s.expectCode("CreateParkingLot with an unknown slot type", appErr, http.StatusBadRequest)
+
+	_, appErr = s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 2", SlotTypes: map[domain.VehicleType]int{domain.VehicleTypeCar: -1}})
+	s.expectCode("CreateParkingLot with a negative slot count", appErr, http.StatusBadRequest)
+}
+
+func testParkBestFittingSlot(s *suite) {
+	// slots 1: motorcycle, 2-3: car, 4: van, 5: ev.
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{
+		Name: "Parking Lot 1",
+		SlotTypes: map[domain.VehicleType]int{
+			domain.VehicleTypeMotorcycle: 1, domain.VehicleTypeCar: 2, domain.VehicleTypeVan: 1, domain.VehicleTypeEV: 1,
+		},
+	})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	steps := []struct {
+		regNum   string
+		vType    domain.VehicleType
+		expected int // slot number, 0 if no slot fits.
+	}{
+		{"CAR-1", domain.VehicleTypeCar, 2},
+		{"MOTO-1", domain.VehicleTypeMotorcycle, 1},
+		{"MOTO-2", domain.VehicleTypeMotorcycle, 3}, // motorcycle slots are full, falls back to a car slot.
+		{"MOTO-3", domain.VehicleTypeMotorcycle, 0}, // never takes a van slot.
+		{"CAR-2", domain.VehicleTypeCar, 4},         // car slots are full, falls back to the van slot.
+		{"CAR-3", domain.VehicleTypeCar, 0},         // cars never take EV slots.
+		{"TRUCK-1", domain.VehicleTypeTruck, 0},
+		{"EV-1", domain.VehicleTypeEV, 5},
+	}
+
+	for _, step := range steps {
+		v, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, step.regNum, domain.ParkOptions{VehicleType: step.vType})
+		if step.expected == 0 {
+			s.expectCode("ParkVehicle("+step.regNum+") without a fitting slot", appErr, http.StatusConflict)
+			continue
+		}
+
+		if appErr != nil {
+			s.t.Fatalf("ParkVehicle(%s) returned error %v", step.regNum, appErr)
+		}
+
+		if v.SlotID != lot.Slots[step.expected-1].ID || v.VehicleType != step.vType {
+			s.t.Errorf("ParkVehicle(%s) returned %+v; expected a %s in slot number %d", step.regNum, v, step.vType, step.expected)
+		}
+	}
+}
+
+func testUnparkVehicleTypeFee(s *suite) {
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{
+		Name:      "Parking Lot 1",
+		SlotTypes: map[domain.VehicleType]int{domain.VehicleTypeMotorcycle: 1, domain.VehicleTypeCar: 1},
+	})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	policy := &domain.PricingPolicy{HourlyRate: 10, VehicleTypeMultipliers: map[domain.VehicleType]float64{domain.VehicleTypeMotorcycle: 0.5}}
+	if _, appErr = s.lots.SetPricingPolicy(s.ctx, lot.ID, policy); appErr != nil {
+		s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+	}
+
+	stored, appErr := s.lots.GetPricingPolicy(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetPricingPolicy returned error %v", appErr)
+	}
+
+	if stored.VehicleTypeMultipliers[domain.VehicleTypeMotorcycle] != 0.5 {
+		s.t.Errorf("GetPricingPolicy returned multipliers %v; expected motorcycle 0.5", stored.VehicleTypeMultipliers)
+	}
+
+	s.parkType(lot.ID, "MOTO-1", domain.VehicleTypeMotorcycle)
+	s.park(lot.ID, "CAR-1")
+	s.clock.Advance(150 * time.Minute)
+
+	if v := s.unpark(lot.ID, "MOTO-1"); v.Fee != 15 || v.VehicleType != domain.VehicleTypeMotorcycle {
+		s.t.Errorf("UnparkVehicle returned a %s with fee %d; expected a motorcycle with fee 15", v.VehicleType, v.Fee)
+	}
+
+	if v := s.unpark(lot.ID, "CAR-1"); v.Fee != 30 {
+		s.t.Errorf("UnparkVehicle returned fee %d; expected 30 for a car without a multiplier", v.Fee)
+	}
+
+	_, appErr = s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{
+		HourlyRate: 10, VehicleTypeMultipliers: map[domain.VehicleType]float64{domain.VehicleTypeTruck: 0},
+	})
+	s.expectCode("SetPricingPolicy with a zero multiplier", appErr, http.StatusBadRequest)
+}
+
+// layoutLot creates a lot with level G (entrance distance 0) zones A and B, and level B1 (distance 1) zone A,
+// two car slots per zone. Level B1 is listed first so slot numbers don't follow entrance distance.
+func (s *suite) layoutLot() *domain.ParkingLot {
+	s.t.Helper()
+
+	cars := map[domain.VehicleType]int{domain.VehicleTypeCar: 2}
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{
+		Name: "Parking Lot 1",
+		Layout: &domain.Layout{Levels: []domain.LevelLayout{
+			{Name: "B1", EntranceDistance: 1, Zones: []domain.ZoneLayout{{Name: "A", SlotTypes: cars}}},
+			{Name: "G", EntranceDistance: 0, Zones: []domain.ZoneLayout{{Name: "A", SlotTypes: cars}, {Name: "B", SlotTypes: cars}}},
+		}},
+	})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	return lot
+}
+
+func testCreateParkingLotLayout(s *suite) {
+	lot := s.layoutLot()
+
+	labels := []string{"B1-A-1", "B1-A-2", "G-A-1", "G-A-2", "G-B-1", "G-B-2"}
+	if lot.DesiredSlots != len(labels) || len(lot.Slots) != len(labels) {
+		s.t.Fatalf("CreateParkingLot returned %+v; expected %d slots", lot, len(labels))
+	}
+
+	for i, slot := range lot.Slots {
+		if slot.SlotNumber != i+1 || slot.Label != labels[i] {
+			s.t.Errorf("CreateParkingLot returned slot %d labelled %s; expected slot %d labelled %s", slot.SlotNumber, slot.Label, i+1, labels[i])
+		}
+	}
+
+	s.park(lot.ID, "ABC-1")
+
+	status, appErr := s.lots.GetParkingLotStatus(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetParkingLotStatus returned error %v", appErr)
+	}
+
+	if len(status.Levels) != 2 || status.Levels[0].Name != "G" || status.Levels[1].Name != "B1" {
+		s.t.Fatalf("GetParkingLotStatus returned levels %+v; expected G then B1", status.Levels)
+	}
+
+	ground := status.Levels[0]
+	if len(ground.Zones) != 2 || ground.Zones[0].Name != "A" || ground.Zones[1].Name != "B" || len(ground.Zones[1].Slots) != 2 {
+		s.t.Fatalf("GetParkingLotStatus returned level G zones %+v; expected zones A and B of 2 slots", ground.Zones)
+	}
+
+	parked := ground.Zones[0].Slots[0]
+	if parked.SlotLabel != "G-A-1" || parked.RegistrationNum == nil || *parked.RegistrationNum != "ABC1" {
+		s.t.Errorf("GetParkingLotStatus returned slot %+v; expected ABC1 in G-A-1", parked)
+	}
+
+	flat := s.createLot("Parking Lot 2", 2)
+	status, appErr = s.lots.GetParkingLotStatus(s.ctx, flat.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetParkingLotStatus returned error %v", appErr)
+	}
+
+	if len(status.Levels) != 1 || len(status.Levels[0].Zones) != 1 || len(status.Levels[0].Zones[0].Slots) != 2 || flat.Slots[1].Label != "2" {
+		s.t.Errorf("GetParkingLotStatus returned levels %+v; expected a single unnamed level and zone of 2 slots", status.Levels)
+	}
+
+	invalid := []*domain.Layout{
+		{},
+		{Levels: []domain.LevelLayout{{Name: "G"}}},
+		{Levels: []domain.LevelLayout{{Name: "G-1", Zones: []domain.ZoneLayout{{Name: "A"}}}}},
+		{Levels: []domain.LevelLayout{{Name: "G", Zones: []domain.ZoneLayout{{Name: "A"}, {Name: "A"}}}}},
+		{Levels: []domain.LevelLayout{{Name: "G", Zones: []domain.ZoneLayout{{Name: "A", SlotTypes: map[domain.VehicleType]int{"bus": 1}}}}}},
+	}
+
+	for _, layout := range invalid {
+		_, appErr = s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 3", Layout: layout})
+		s.expectCode("CreateParkingLot with an invalid layout", appErr, http.StatusBadRequest)
+	}
+}
+
+func testParkLayoutPreferences(s *suite) {
+	lot := s.layoutLot()
+
+	steps := []struct {
+		regNum   string
+		opts     domain.ParkOptions
+		expected string
+	}{
+		{"ABC-1", domain.ParkOptions{}, "G-A-1"}, // closest level to the entrance, not the lowest slot number.
+		{"ABC-2", domain.ParkOptions{PreferredZone: "B"}, "G-B-1"},
+		{"ABC-3", domain.ParkOptions{PreferredLevel: "B1"}, "B1-A-1"},
+		{"ABC-4", domain.ParkOptions{PreferredLevel: "G", PreferredZone: "C"}, "G-A-2"}, // unknown zone, still on level G.
+		{"ABC-5", domain.ParkOptions{PreferredLevel: "B1", PreferredZone: "B"}, "B1-A-2"},
+		{"ABC-6", domain.ParkOptions{PreferredLevel: "B1"}, "G-B-2"}, // level B1 is full.
+	}
+
+	for _, step := range steps {
+		step.opts.VehicleType = domain.VehicleTypeCar
+		if v := s.parkWith(lot.ID, step.regNum, step.opts); v.SlotLabel != step.expected {
+			s.t.Errorf("ParkVehicle(%s, %+v) chose slot %s; expected %s", step.regNum, step.opts, v.SlotLabel, step.expected)
+		}
+	}
+
+	if v := s.unpark(lot.ID, "ABC-3"); v.SlotLabel != "B1-A-1" {
+		s.t.Errorf("UnparkVehicle returned slot label %s; expected B1-A-1", v.SlotLabel)
+	}
+}
+
+// setStrategy changes the allocation strategy of a parking lot, failing the test on error.
+func (s *suite) setStrategy(plUUID uuid.UUID, strategy domain.AllocationStrategy) {
+	s.t.Helper()
+
+	if appErr := s.lots.SetAllocationStrategy(s.ctx, plUUID, strategy); appErr != nil {
+		s.t.Fatalf("SetAllocationStrategy(%s) returned error %v", strategy, appErr)
+	}
+}
+
+// expectSlots parks a car per label in order, failing the test unless each gets the slot with that label.
+func (s *suite) expectSlots(plUUID uuid.UUID, regPrefix string, labels ...string) {
+	s.t.Helper()
+
+	for i, label := range labels {
+		regNum := fmt.Sprintf("%s-%d", regPrefix, i+1)
+		if v := s.park(plUUID, regNum); v.SlotLabel != label {
+			s.t.Errorf("ParkVehicle(%s) chose slot %s; expected %s", regNum, v.SlotLabel, label)
+		}
+	}
+}
+
+func testAllocationStrategy(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	if lot.AllocationStrategy != domain.DefaultAllocationStrategy {
+		s.t.Errorf("CreateParkingLot returned strategy %q; expected %q", lot.AllocationStrategy, domain.DefaultAllocationStrategy)
+	}
+
+	if v := s.park(lot.ID, "ABC-1"); v.AllocationStrategy != domain.AllocationNearest {
+		s.t.Errorf("ParkVehicle returned strategy %q; expected %q", v.AllocationStrategy, domain.AllocationNearest)
+	}
+
+	s.setStrategy(lot.ID, domain.AllocationFillByZone)
+	strategy, appErr := s.lots.GetAllocationStrategy(s.ctx, lot.ID)
+	if appErr != nil || strategy != domain.AllocationFillByZone {
+		s.t.Errorf("GetAllocationStrategy returned %q, %v; expected %q", strategy, appErr, domain.AllocationFillByZone)
+	}
+
+	if v := s.park(lot.ID, "ABC-2"); v.AllocationStrategy != domain.AllocationFillByZone {
+		s.t.Errorf("ParkVehicle returned strategy %q; expected %q", v.AllocationStrategy, domain.AllocationFillByZone)
+	}
+
+	s.expectCode("SetAllocationStrategy with an unknown strategy", s.lots.SetAllocationStrategy(s.ctx, lot.ID, "cheapest"), http.StatusBadRequest)
+	s.expectCode("SetAllocationStrategy of an unknown lot", s.lots.SetAllocationStrategy(s.ctx, uuid.New(), domain.AllocationRandom), http.StatusNotFound)
+
+	_, appErr = s.lots.GetAllocationStrategy(s.ctx, uuid.New())
+	s.expectCode("GetAllocationStrategy of an unknown lot", appErr, http.StatusNotFound)
+
+	created, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 2", DesiredSlots: 1, AllocationStrategy: "Round-Robin"})
+	if appErr != nil || created.AllocationStrategy != domain.AllocationRoundRobin {
+		s.t.Errorf("CreateParkingLot returned %+v, %v; expected strategy %q", created, appErr, domain.AllocationRoundRobin)
+	}
+
+	_, appErr = s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 3", DesiredSlots: 1, AllocationStrategy: "cheapest"})
+	s.expectCode("CreateParkingLot with an unknown strategy", appErr, http.StatusBadRequest)
+}
+
+func testAllocationRoundRobin(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+	s.setStrategy(lot.ID, domain.AllocationRoundRobin)
+
+	// freed slots are skipped until allocation wraps around.
+	s.expectSlots(lot.ID, "ABC", "1")
+	s.unpark(lot.ID, "ABC-1")
+	s.expectSlots(lot.ID, "DEF", "2", "3", "1")
+	s.unpark(lot.ID, "DEF-1")
+	s.expectSlots(lot.ID, "GHI", "2")
+}
+
+func testAllocationLeastRecentlyUsed(s *suite) {
+	lot := s.createLot("Parking Lot 1", 4)
+	s.setStrategy(lot.ID, domain.AllocationLeastRecentlyUsed)
+
+	s.expectSlots(lot.ID, "ABC", "1", "2")
+	s.clock.Advance(time.Hour)
+	s.unpark(lot.ID, "ABC-2")
+	s.clock.Advance(time.Hour)
+	s.unpark(lot.ID, "ABC-1")
+
+	// never used slots first, then slot 2 released before slot 1.
+	s.expectSlots(lot.ID, "DEF", "3", "4", "2", "1")
+}
+
+func testAllocationRandom(s *suite) {
+	lot := s.createLot("Parking Lot 1", 5)
+	s.setStrategy(lot.ID, domain.AllocationRandom)
+
+	chosen := make(map[uuid.UUID]bool)
+	for i := range 5 {
+		v := s.park(lot.ID, fmt.Sprintf("ABC-%d", i))
+		if chosen[v.SlotID] || v.AllocationStrategy != domain.AllocationRandom {
+			s.t.Errorf("ParkVehicle returned %+v; expected a free slot chosen by %s", v, domain.AllocationRandom)
+		}
+
+		chosen[v.SlotID] = true
+	}
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-5", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle in a full lot", appErr, http.StatusConflict)
+}
+
+func testAllocationFillByZone(s *suite) {
+	lot := s.layoutLot()
+	s.setStrategy(lot.ID, domain.AllocationFillByZone)
+
+	s.parkWith(lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar, PreferredLevel: "B1"})
+
+	// zone B1-A is filled first though level G is nearer, then the empty zones of G in order.
+	s.expectSlots(lot.ID, "DEF", "B1-A-2", "G-A-1", "G-A-2", "G-B-1")
+}
+
+// reserve reserves a slot for a car between start and end from now, failing the test on error.
+func (s *suite) reserve(plUUID uuid.UUID, regNum string, start, end time.Duration) *domain.Reservation {
+	s.t.Helper()
+
+	res, appErr := s.reservations.CreateReservation(s.ctx, plUUID, s.reservationRequest(regNum, start, end))
+	if appErr != nil {
+		s.t.Fatalf("CreateReservation(%s) returned error %v", regNum, appErr)
+	}
+
+	return res
+}
+
+// reservationRequest returns a request reserving a slot for a car between start and end from now.
+func (s *suite) reservationRequest(regNum string, start, end time.Duration) domain.ReservationRequest {
+	now := s.clock.Now()
+	return domain.ReservationRequest{
+		RegistrationNumber: regNum,
+		StartsAt:           now.Add(start),
+		EndsAt:             now.Add(end),
+		Options:            domain.ParkOptions{VehicleType: domain.VehicleTypeCar},
+	}
+}
+
+// expectStatus fails the test unless the reservation has the expected status.
+func (s *suite) expectStatus(plUUID uuid.UUID, res *domain.Reservation, expected domain.ReservationStatus) {
+	s.t.Helper()
+
+	got, appErr := s.reservations.GetReservation(s.ctx, plUUID, res.ID)
+	if appErr != nil {
+		s.t.Fatalf("GetReservation returned error %v", appErr)
+	}
+
+	if got.Status != expected {
+		s.t.Errorf("GetReservation returned status %s; expected %s", got.Status, expected)
+	}
+}
+
+func testReservationParks(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+
+	// held from 30 minutes before it starts, a vehicle without a reservation can't take it.
+	res := s.reserve(lot.ID, "ABC-1", 20*time.Minute, 2*time.Hour)
+	expected := domain.Reservation{
+		ID:                    res.ID,
+		ParkingLotID:          lot.ID,
+		SlotID:                lot.Slots[0].ID,
+		SlotLabel:             "1",
+		RegistrationNumber:    "ABC1",
+		RawRegistrationNumber: "ABC-1",
+		VehicleType:           domain.VehicleTypeCar,
+		StartsAt:              s.clock.Now().Add(20 * time.Minute),
+		EndsAt:                s.clock.Now().Add(2 * time.Hour),
+		HoldUntil:             s.clock.Now().Add(20*time.Minute + domain.DefaultReservationHold),
+		Status:                domain.ReservationActive,
+		CreatedAt:             s.clock.Now(),
+	}
+	if !reflect.DeepEqual(*res, expected) {
+		s.t.Errorf("CreateReservation returned %+v; expected %+v", *res, expected)
+	}
+
+	s.expectSlots(lot.ID, "DEF", "2")
+
+	s.clock.Advance(20 * time.Minute)
+	v := s.park(lot.ID, "ABC-1")
+	if v.SlotID != res.SlotID || v.ReservationID == nil || *v.ReservationID != res.ID || v.AllocationStrategy != "" {
+		s.t.Errorf("ParkVehicle returned %+v; expected reserved slot 1 of reservation %s", v, res.ID)
+	}
+
+	s.expectStatus(lot.ID, res, domain.ReservationFulfilled)
+
+	// every slot is occupied, a later hold may reserve slot 2 whose vehicle is still parked when the driver arrives.
+	s.expectSlots(lot.ID, "JKL", "3")
+	res = s.reserve(lot.ID, "GHI-1", 40*time.Minute, 2*time.Hour)
+	if res.SlotLabel != "2" {
+		s.t.Errorf("CreateReservation chose slot %s; expected occupied slot 2", res.SlotLabel)
+	}
+
+	s.clock.Advance(40 * time.Minute)
+	s.unpark(lot.ID, "ABC-1")
+
+	// the driver gets another slot, the strategy reports the fallback and the reservation moves to the slot.
+	if v = s.park(lot.ID, "GHI-1"); v.SlotLabel != "1" || v.ReservationID == nil || *v.ReservationID != res.ID ||
+		v.AllocationStrategy != domain.AllocationNearest {
+		s.t.Errorf("ParkVehicle returned %+v; expected slot 1 chosen by %s for reservation %s", v, domain.AllocationNearest, res.ID)
+	}
+
+	got, appErr := s.reservations.GetReservation(s.ctx, lot.ID, res.ID)
+	if appErr != nil || got.Status != domain.ReservationFulfilled || got.SlotID != v.SlotID {
+		s.t.Errorf("GetReservation returned %+v, %v; expected fulfilled in slot %s", got, appErr, v.SlotLabel)
+	}
+}
+
+func testReservationHoldsSlot(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	// starting within the expected stay of a vehicle without a reservation, the slot is kept from it before the hold begins.
+	res := s.reserve(lot.ID, "ABC-1", 2*time.Hour, 3*time.Hour)
+	s.expectSlots(lot.ID, "DEF", "2")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "GHI-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle with the last slot reserved within the expected stay", appErr, http.StatusConflict)
+
+	// a reservation for another vehicle type doesn't hold the slot for the vehicle, and stays active.
+	s.clock.Advance(2 * time.Hour)
+	_, appErr = s.vehicles.ParkVehicle(s.ctx, lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeMotorcycle})
+	s.expectCode("ParkVehicle of another vehicle type", appErr, http.StatusConflict)
+	s.expectStatus(lot.ID, res, domain.ReservationActive)
+
+	if v := s.park(lot.ID, "ABC-1"); v.SlotID != res.SlotID || v.ReservationID == nil {
+		s.t.Errorf("ParkVehicle returned %+v; expected reserved slot %s", v, res.SlotLabel)
+	}
+
+	// every slot is occupied now, a reservation for next week still gets one.
+	if res = s.reserve(lot.ID, "JKL-1", 7*24*time.Hour, 7*24*time.Hour+time.Hour); res.SlotLabel != "1" {
+		s.t.Errorf("CreateReservation chose slot %s; expected 1", res.SlotLabel)
+	}
+
+	_, appErr = s.reservations.CreateReservation(s.ctx, lot.ID, s.reservationRequest("JKL-2", 10*time.Minute, time.Hour))
+	s.expectCode("CreateReservation held now without a free slot", appErr, http.StatusConflict)
+
+	// slots free now are reserved first.
+	s.unpark(lot.ID, "DEF-1")
+	if res = s.reserve(lot.ID, "JKL-3", 2*24*time.Hour, 2*24*time.Hour+time.Hour); res.SlotLabel != "2" {
+		s.t.Errorf("CreateReservation chose slot %s; expected 2", res.SlotLabel)
+	}
+}
+
+func testReservationConflicts(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	first := s.reserve(lot.ID, "ABC-1", time.Hour, 3*time.Hour)
+	second := s.reserve(lot.ID, "ABC-2", 2*time.Hour, 4*time.Hour)
+	if first.SlotLabel != "1" || second.SlotLabel != "2" {
+		s.t.Errorf("CreateReservation chose slots %s and %s; expected 1 and 2", first.SlotLabel, second.SlotLabel)
+	}
+
+	_, appErr := s.reservations.CreateReservation(s.ctx, lot.ID, s.reservationRequest("ABC-3", 150*time.Minute, 3*time.Hour))
+	s.expectCode("CreateReservation without a free slot", appErr, http.StatusConflict)
+
+	_, appErr = s.reservations.CreateReservation(s.ctx, lot.ID, s.reservationRequest("ABC-1", 2*time.Hour, 5*time.Hour))
+	s.expectCode("CreateReservation overlapping the vehicle's reservation", appErr, http.StatusConflict)
+
+	// back to back with the first reservation.
+	if res := s.reserve(lot.ID, "ABC-3", 3*time.Hour, 4*time.Hour); res.SlotLabel != "1" {
+		s.t.Errorf("CreateReservation chose slot %s; expected 1", res.SlotLabel)
+	}
+
+	invalid := []domain.ReservationRequest{
+		s.reservationRequest("", time.Hour, 2*time.Hour),
+		s.reservationRequest("ABC-5", 2*time.Hour, time.Hour),
+		s.reservationRequest("ABC-5", -time.Hour, time.Hour),
+	}
+
+	for _, req := range invalid {
+		_, appErr = s.reservations.CreateReservation(s.ctx, lot.ID, req)
+		s.expectCode("CreateReservation with an invalid window", appErr, http.StatusBadRequest)
+	}
+
+	_, appErr = s.reservations.CreateReservation(s.ctx, uuid.New(), s.reservationRequest("ABC-5", time.Hour, 2*time.Hour))
+	s.expectCode("CreateReservation in an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testReservationHoldExpiry(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	res := s.reserve(lot.ID, "ABC-1", 10*time.Minute, 2*time.Hour)
+	s.expectSlots(lot.ID, "DEF", "2")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "GHI-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle with the last slot held", appErr, http.StatusConflict)
+
+	// the driver didn't show up within the hold, the slot is released.
+	s.clock.Advance(10*time.Minute + domain.DefaultReservationHold + time.Minute)
+	s.expectStatus(lot.ID, res, domain.ReservationExpired)
+	s.expectSlots(lot.ID, "GHI", "1")
+
+	_, appErr = s.reservations.CancelReservation(s.ctx, lot.ID, res.ID)
+	s.expectCode("CancelReservation of an expired reservation", appErr, http.StatusConflict)
+}
+
+func testReservationCancel(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+
+	res := s.reserve(lot.ID, "ABC-1", 10*time.Minute, time.Hour)
+
+	cancelled, appErr := s.reservations.CancelReservation(s.ctx, lot.ID, res.ID)
+	if appErr != nil || cancelled.Status != domain.ReservationCancelled {
+		s.t.Fatalf("CancelReservation returned %+v, %v; expected a cancelled reservation", cancelled, appErr)
+	}
+
+	s.expectStatus(lot.ID, res, domain.ReservationCancelled)
+	s.expectSlots(lot.ID, "DEF", "1")
+
+	_, appErr = s.reservations.CancelReservation(s.ctx, lot.ID, res.ID)
+	s.expectCode("CancelReservation twice", appErr, http.StatusConflict)
+
+	_, appErr = s.reservations.GetReservation(s.ctx, lot.ID, uuid.New())
+	s.expectCode("GetReservation of an unknown reservation", appErr, http.StatusNotFound)
+
+	other := s.createLot("Parking Lot 2", 1)
+	_, appErr = s.reservations.GetReservation(s.ctx, other.ID, res.ID)
+	s.expectCode("GetReservation of another lot", appErr, http.StatusNotFound)
+}
+
+// issuePermit issues a permit valid from now for a month, failing the test on error.
+func (s *suite) issuePermit(plUUID uuid.UUID, holder string, slotID *uuid.UUID, plates ...string) *domain.Permit {
+	s.t.Helper()
+
+	permit, appErr := s.permits.CreatePermit(s.ctx, plUUID, domain.PermitRequest{Holder: holder, Plates: plates, SlotID: slotID})
+	if appErr != nil {
+		s.t.Fatalf("CreatePermit(%s) returned error %v", holder, appErr)
+	}
+
+	return permit
+}
+
+func testPermitParks(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+
+	assigned := s.issuePermit(lot.ID, "Acme", &lot.Slots[1].ID, "PER-1", "PER-2")
+	expected := domain.Permit{
+		ID:           assigned.ID,
+		ParkingLotID: lot.ID,
+		Holder:       "Acme",
+		Plates:       []string{"PER1", "PER2"},
+		RawPlates:    []string{"PER-1", "PER-2"},
+		SlotID:       &lot.Slots[1].ID,
+		SlotLabel:    "2",
+		ValidFrom:    s.clock.Now(),
+		ValidUntil:   s.clock.Now().AddDate(0, 1, 0),
+		CreatedAt:    s.clock.Now(),
+	}
+	if !reflect.DeepEqual(*assigned, expected) {
+		s.t.Errorf("CreatePermit returned %+v; expected %+v", *assigned, expected)
+	}
+
+	floating := s.issuePermit(lot.ID, "Jane Doe", nil, "FLT-1")
+
+	// the assigned slot is kept for the permit's plates.
+	s.expectSlots(lot.ID, "DEF", "1", "3")
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "GHI-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle with only the permit slot free", appErr, http.StatusConflict)
+
+	v := s.park(lot.ID, "PER-2")
+	if v.SlotLabel != "2" || v.PermitID == nil || *v.PermitID != assigned.ID || v.AllocationStrategy != "" {
+		s.t.Errorf("ParkVehicle returned %+v; expected permit slot 2 of permit %s", v, assigned.ID)
+	}
+
+	// floating permits park like any other vehicle.
+	s.unpark(lot.ID, "DEF-1")
+	if v = s.park(lot.ID, "FLT-1"); v.SlotLabel != "1" || v.PermitID == nil || *v.PermitID != floating.ID ||
+		v.AllocationStrategy != domain.AllocationNearest {
+		s.t.Errorf("ParkVehicle returned %+v; expected slot 1 chosen by %s under permit %s", v, domain.AllocationNearest, floating.ID)
+	}
+
+	s.clock.Advance(3 * time.Hour)
+	for _, regNum := range []string{"PER-2", "FLT-1"} {
+		if v = s.unpark(lot.ID, regNum); v.Fee != 0 || v.PermitID == nil {
+			s.t.Errorf("UnparkVehicle(%s) returned fee %d under permit %v; expected a free permit stay", regNum, v.Fee, v.PermitID)
+		}
+	}
+
+	if v = s.unpark(lot.ID, "DEF-2"); v.Fee != 30 || v.PermitID != nil {
+		s.t.Errorf("UnparkVehicle(DEF-2) returned fee %d under permit %v; expected 30 without permit", v.Fee, v.PermitID)
+	}
+}
+
+func testPermitEndsDuringStay(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+
+	revoked := s.issuePermit(lot.ID, "Acme", nil, "ABC-1")
+	expiring, appErr := s.permits.CreatePermit(s.ctx, lot.ID, domain.PermitRequest{
+		Holder: "Globex", Plates: []string{"DEF-1"}, ValidUntil: s.clock.Now().Add(2 * time.Hour),
+	})
+	if appErr != nil {
+		s.t.Fatalf("CreatePermit returned error %v", appErr)
+	}
+
+	s.park(lot.ID, "ABC-1")
+	s.park(lot.ID, "DEF-1")
+
+	s.clock.Advance(time.Hour)
+	if _, appErr = s.permits.RevokePermit(s.ctx, lot.ID, revoked.ID); appErr != nil {
+		s.t.Fatalf("RevokePermit returned error %v", appErr)
+	}
+
+	// the hours after the permit ended are charged, quotes and estimates included.
+	s.clock.Advance(2 * time.Hour)
+	quote, appErr := s.vehicles.QuoteFee(s.ctx, lot.ID, "DEF-1", time.Time{})
+	if appErr != nil || quote.Total != 10 || quote.PermitEndsAt == nil || !quote.PermitEndsAt.Equal(expiring.ValidUntil) {
+		s.t.Errorf("QuoteFee returned %+v, %v; expected 10 for the hour after the permit expired at %s", quote, appErr, expiring.ValidUntil)
+	}
+
+	if pv, appErr := s.vehicles.FindParkedVehicle(s.ctx, "ABC-1"); appErr != nil || pv.EstimatedFee != 20 {
+		s.t.Errorf("FindParkedVehicle returned %+v, %v; expected an estimated fee of 20 after the revocation", pv, appErr)
+	}
+
+	for regNum, expected := range map[string]int{"ABC-1": 20, "DEF-1": 10} {
+		if v := s.unpark(lot.ID, regNum); v.Fee != expected || v.PermitID == nil {
+			s.t.Errorf("UnparkVehicle(%s) returned fee %d under permit %v; expected %d after the permit ended", regNum, v.Fee, v.PermitID, expected)
+		}
+	}
+}
+
+func testPermitConflicts(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+
+	first := s.issuePermit(lot.ID, "Acme", &lot.Slots[0].ID, "ABC-1")
+
+	conflicts := []domain.PermitRequest{
+		{Holder: "Jane Doe", Plates: []string{"ABC-2", "ABC-1"}},
+		{Holder: "Jane Doe", Plates: []string{"ABC-2"}, SlotID: &lot.Slots[0].ID},
+	}
+
+	for _, req := range conflicts {
+		_, appErr := s.permits.CreatePermit(s.ctx, lot.ID, req)
+		s.expectCode("CreatePermit overlapping a permit", appErr, http.StatusConflict)
+	}
+
+	// back to back with the first permit, and the plate may hold a permit of another lot.
+	_, appErr := s.permits.CreatePermit(s.ctx, lot.ID, domain.PermitRequest{
+		Holder: "Acme", Plates: []string{"ABC-1"}, SlotID: &lot.Slots[0].ID, ValidFrom: first.ValidUntil,
+	})
+	if appErr != nil {
+		s.t.Errorf("CreatePermit after the first permit returned error %v", appErr)
+	}
+
+	s.issuePermit(other.ID, "Acme", nil, "ABC-1")
+
+	// the assigned slot is never reserved, and a reserved slot is never assigned.
+	res := s.reserve(lot.ID, "DEF-1", time.Hour, 2*time.Hour)
+	if res.SlotLabel != "2" {
+		s.t.Errorf("CreateReservation chose slot %s; expected 2", res.SlotLabel)
+	}
+
+	_, appErr = s.permits.CreatePermit(s.ctx, lot.ID, domain.PermitRequest{Holder: "Jane Doe", Plates: []string{"ABC-2"}, SlotID: &res.SlotID})
+	s.expectCode("CreatePermit of a reserved slot", appErr, http.StatusConflict)
+
+	_, appErr = s.permits.CreatePermit(s.ctx, lot.ID, domain.PermitRequest{Holder: "Jane Doe", Plates: []string{"ABC-2"}, SlotID: &other.Slots[0].ID})
+	s.expectCode("CreatePermit of another lot's slot", appErr, http.StatusNotFound)
+
+	_, appErr = s.permits.CreatePermit(s.ctx, uuid.New(), domain.PermitRequest{Holder: "Jane Doe", Plates: []string{"ABC-2"}})
+	s.expectCode("CreatePermit in an unknown lot", appErr, http.StatusNotFound)
+
+	invalid := []domain.PermitRequest{
+		{Plates: []string{"ABC-2"}},
+		{Holder: "Jane Doe"},
+		{Holder: "Jane Doe", Plates: []string{"ABC-2", ""}},
+		{Holder: "Jane Doe", Plates: []string{"ABC-2", "ABC-2"}},
+		{Holder: "Jane Doe", Plates: []string{"ABC-2"}, ValidFrom: s.clock.Now(), ValidUntil: s.clock.Now().Add(-time.Hour)},
+	}
+
+	for _, req := range invalid {
+		_, appErr = s.permits.CreatePermit(s.ctx, lot.ID, req)
+		s.expectCode("CreatePermit with an invalid request", appErr, http.StatusBadRequest)
+	}
+}
+
+func testPermitRevoke(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+
+	permit := s.issuePermit(lot.ID, "Acme", &lot.Slots[0].ID, "ABC-1")
+	floating := s.issuePermit(lot.ID, "Jane Doe", nil, "ABC-2")
+
+	_, appErr := s.vehicles.ParkVehicle(s.ctx, lot.ID, "DEF-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	s.expectCode("ParkVehicle with only the permit slot", appErr, http.StatusConflict)
+
+	s.clock.Advance(time.Hour)
+	revoked, appErr := s.permits.RevokePermit(s.ctx, lot.ID, permit.ID)
+	if appErr != nil || revoked.RevokedAt == nil || !revoked.RevokedAt.Equal(s.clock.Now()) {
+		s.t.Fatalf("RevokePermit returned %+v, %v; expected a permit revoked at %s", revoked, appErr, s.clock.Now())
+	}
+
+	_, appErr = s.permits.RevokePermit(s.ctx, lot.ID, permit.ID)
+	s.expectCode("RevokePermit twice", appErr, http.StatusConflict)
+
+	_, appErr = s.permits.RevokePermit(s.ctx, lot.ID, uuid.New())
+	s.expectCode("RevokePermit of an unknown permit", appErr, http.StatusNotFound)
+
+	// the slot is released, and the plate parks as a transient vehicle.
+	if v := s.park(lot.ID, "ABC-1"); v.SlotLabel != "1" || v.PermitID != nil {
+		s.t.Errorf("ParkVehicle returned %+v; expected slot 1 without permit", v)
+	}
+
+	permits, appErr := s.permits.ListPermits(s.ctx, lot.ID)
+	if appErr != nil {
+		s.t.Fatalf("ListPermits returned error %v", appErr)
+	}
+
+	if expected := []domain.Permit{*revoked, *floating}; !reflect.DeepEqual(permits, expected) {
+		s.t.Errorf("ListPermits returned %+v; expected %+v", permits, expected)
+	}
+
+	_, appErr = s.permits.ListPermits(s.ctx, uuid.New())
+	s.expectCode("ListPermits of an unknown lot", appErr, http.StatusNotFound)
+}
+
+func testPermitDailyReport(s *suite) {
+	lot := s.createLot("Parking Lot 1", 3)
+	day := s.clock.Now().Truncate(24 * time.Hour)
+
+	s.issuePermit(lot.ID, "Acme", nil, "PER-1")
+
+	// 10:00 -> 11:30, 2 started hours each, only the transient vehicle pays.
+	s.park(lot.ID, "PER-1")
+	s.park(lot.ID, "ABC-1")
+	s.clock.Advance(90 * time.Minute)
+	s.unpark(lot.ID, "PER-1")
+	s.unpark(lot.ID, "ABC-1")
+	s.park(lot.ID, "ABC-2")
+
+	report, appErr := s.lots.GetDailyReport(s.ctx, lot.ID, day)
+	if appErr != nil {
+		s.t.Fatalf("GetDailyReport returned error %v", appErr)
+	}
+
+	expected := domain.DailyReport{
+		TotalVehiclesParked: 3,
+		TotalParkingHours:   4,
+		TotalFeeCollected:   20,
+		Permit:              domain.UsageReport{VehiclesParked: 1, ParkingHours: 2},
+		Transient:           domain.UsageReport{VehiclesParked: 2, ParkingHours: 2, FeeCollected: 20},
+	}
+	if *report != expected {
+		s.t.Errorf("GetDailyReport returned %+v; expected %+v", *report, expected)
+	}
+}
+
+func testFindParkedVehicle(s *suite) {
+	lot := s.layoutLot()
+	if _, appErr := s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{HourlyRate: 20}); appErr != nil {
+		s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+	}
+
+	parked := s.parkWith(lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar, PreferredLevel: "B1"})
+	s.clock.Advance(150 * time.Minute)
+
+	got, appErr := s.vehicles.FindParkedVehicle(s.ctx, "ABC-1")
+	if appErr != nil {
+		s.t.Fatalf("FindParkedVehicle returned error %v", appErr)
+	}
+
+	// the estimate is the fee unparking would charge now, 3 started hours.
+	expected := domain.ParkedVehicle{
+		ID:                   parked.ID,
+		RegistrationNumber:   "ABC1",
+		VehicleType:          domain.VehicleTypeCar,
+		ParkingLotID:         lot.ID,
+		ParkingLotName:       lot.Name,
+		TimeZone:             "UTC",
+		SlotID:               parked.SlotID,
+		SlotNumber:           1,
+		SlotLabel:            "B1-A-1",
+		Level:                "B1",
+		Zone:                 "A",
+		ParkedAt:             parked.ParkedAt,
+		EstimatedAt:          s.clock.Now(),
+		EstimatedFee:         60,
+		Currency:             "USD",
+		PricingPolicyVersion: 1,
+	}
+	if !reflect.DeepEqual(*got, expected) {
+		s.t.Errorf("FindParkedVehicle returned %+v; expected %+v", *got, expected)
+	}
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.Fee != got.EstimatedFee {
+		s.t.Errorf("UnparkVehicle charged %d; expected the estimated %d", v.Fee, got.EstimatedFee)
+	}
+
+	_, appErr = s.vehicles.FindParkedVehicle(s.ctx, "ABC-1")
+	s.expectCode("FindParkedVehicle of an unparked vehicle", appErr, http.StatusNotFound)
+
+	_, appErr = s.vehicles.FindParkedVehicle(s.ctx, "XYZ-1")
+	s.expectCode("FindParkedVehicle of an unknown vehicle", appErr, http.StatusNotFound)
+}
+
+func testQuoteFee(s *suite) {
+	lot := s.createLot("Parking Lot 1", 1)
+	other := s.createLot("Parking Lot 2", 1)
+	if _, appErr := s.lots.SetPricingPolicy(s.ctx, lot.ID, &domain.PricingPolicy{HourlyRate: 10, FirstHourRate: 25}); appErr != nil {
+		s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+	}
+
+	parked := s.park(lot.ID, "ABC-1")
+	s.clock.Advance(150 * time.Minute)
+
+	// a quote in the future doesn't move the clock, the vehicle stays parked.
+	later, appErr := s.vehicles.QuoteFee(s.ctx, lot.ID, "ABC-1", s.clock.Now().Add(2*time.Hour))
+	if appErr != nil || later.Total != 65 || later.BillableHours != 5 {
+		s.t.Errorf("QuoteFee in 2 hours returned %+v, %v; expected 65 for 5 started hours", later, appErr)
+	}
+
+	quote, appErr := s.vehicles.QuoteFee(s.ctx, lot.ID, "ABC-1", time.Time{})
+	if appErr != nil {
+		s.t.Fatalf("QuoteFee returned error %v", appErr)
+	}
+
+	expected := domain.FeeQuote{
+		ParkingLotID:       lot.ID,
+		RegistrationNumber: "ABC1",
+		VehicleType:        domain.VehicleTypeCar,
+		SlotID:             parked.SlotID,
+		SlotLabel:          "1",
+		ParkedAt:           parked.ParkedAt,
+		At:                 s.clock.Now(),
+		FeeBreakdown: domain.FeeBreakdown{
+			BillableHours: 3,
+			LineItems: []domain.FeeLineItem{
+				{Kind: domain.FeeLineFirstHour, Hours: 1, Rate: 25, Amount: 25},
+				{Kind: domain.FeeLineHourly, Hours: 2, Rate: 10, Amount: 20},
+			},
+			Total: 45,
+		},
+		Currency:             "USD",
+		PricingPolicyVersion: 1,
+	}
+	if !reflect.DeepEqual(*quote, expected) {
+		s.t.Errorf("QuoteFee returned %+v; expected %+v", *quote, expected)
+	}
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.Fee != quote.Total {
+		s.t.Errorf("UnparkVehicle charged %d; expected the quoted %d", v.Fee, quote.Total)
+	}
+
+	s.park(lot.ID, "ABC-2")
+	_, appErr = s.vehicles.QuoteFee(s.ctx, lot.ID, "ABC-2", s.clock.Now().Add(-time.Minute))
+	s.expectCode("QuoteFee before the vehicle parked", appErr, http.StatusBadRequest)
+
+	_, appErr = s.vehicles.QuoteFee(s.ctx, other.ID, "ABC-2", time.Time{})
+	s.expectCode("QuoteFee in another lot", appErr, http.StatusNotFound)
+
+	_, appErr = s.vehicles.QuoteFee(s.ctx, lot.ID, "ABC-1", time.Time{})
+	s.expectCode("QuoteFee of an unparked vehicle", appErr, http.StatusNotFound)
+
+	_, appErr = s.vehicles.QuoteFee(s.ctx, uuid.New(), "ABC-2", time.Time{})
+	s.expectCode("QuoteFee in an unknown lot", appErr, http.StatusNotFound)
+}
+
+// testLotLocalPricing tests weekend and night rates apply at the parking lot's local hours. A stay in a UTC+6 lot from
+// Friday 23:00 local (17:00 UTC) crosses local midnight into Saturday, its UTC hours are all on a Friday afternoon.
+func testLotLocalPricing(s *suite) {
+	lot, appErr := s.lots.CreateParkingLot(s.ctx, &domain.ParkingLot{Name: "Parking Lot 1", DesiredSlots: 1, TimeZone: "Asia/Dhaka"})
+	if appErr != nil {
+		s.t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	policy := &domain.PricingPolicy{HourlyRate: 10, WeekendHourlyRate: 30, NightFlatRate: 5, NightStartHour: 1, NightEndHour: 6}
+	if _, appErr = s.lots.SetPricingPolicy(s.ctx, lot.ID, policy); appErr != nil {
+		s.t.Fatalf("SetPricingPolicy returned error %v", appErr)
+	}
+
+	s.clock.Advance(time.Date(2024, time.March, 15, 17, 0, 0, 0, time.UTC).Sub(s.clock.Now()))
+	s.park(lot.ID, "ABC-1")
+	s.clock.Advance(150 * time.Minute)
+
+	// Friday 23:00 hourly, Saturday 00:00 weekend hourly, Saturday 01:00 in the night window.
+	expected := domain.FeeBreakdown{
+		BillableHours: 3,
+		LineItems: []domain.FeeLineItem{
+			{Kind: domain.FeeLineHourly, Hours: 1, Rate: 10, Amount: 10},
+			{Kind: domain.FeeLineWeekendHourly, Hours: 1, Rate: 30, Amount: 30},
+			{Kind: domain.FeeLineNightFlat, Hours: 1, Rate: 5, Amount: 5},
+		},
+		Total: 45,
+	}
+
+	quote, appErr := s.vehicles.QuoteFee(s.ctx, lot.ID, "ABC-1", time.Time{})
+	if appErr != nil || !reflect.DeepEqual(quote.FeeBreakdown, expected) {
+		s.t.Errorf("QuoteFee returned %+v, %v; expected %+v", quote, appErr, expected)
+	}
+
+	pv, appErr := s.vehicles.FindParkedVehicle(s.ctx, "ABC-1")
+	if appErr != nil || pv.EstimatedFee != expected.Total || pv.TimeZone != "Asia/Dhaka" {
+		s.t.Errorf("FindParkedVehicle returned %+v, %v; expected an estimated fee of %d in Asia/Dhaka", pv, appErr, expected.Total)
+	}
+
+	if v := s.unpark(lot.ID, "ABC-1"); v.Fee != expected.Total {
+		s.t.Errorf("UnparkVehicle charged %d; expected %d priced at local hours", v.Fee, expected.Total)
+	}
+}
+
+func testVehicleSessions(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+	start := s.clock.Now()
+
+	// 10:00 -> 11:30 in lot 1, 12:30 -> still parked in lot 2.
+	first := s.park(lot.ID, "ABC-1")
+	s.park(lot.ID, "DEF-1")
+	s.clock.Advance(90 * time.Minute)
+	unparked := s.unpark(lot.ID, "ABC-1")
+	s.clock.Advance(time.Hour)
+	second := s.park(other.ID, "ABC-1")
+	s.clock.Advance(15 * time.Minute)
+
+	page, appErr := s.sessions.ListVehicleSessions(s.ctx, "ABC-1", domain.SessionQuery{})
+	if appErr != nil {
+		s.t.Fatalf("ListVehicleSessions returned error %v", appErr)
+	}
+
+	fee := 20
+	expected := domain.SessionPage{Sessions: []domain.Session{
+		{
+			ID:                    second.ID,
+			ParkingLotID:          other.ID,
+			TimeZone:              "UTC",
+			RegistrationNumber:    "ABC1",
+			RawRegistrationNumber: "ABC-1",
+			VehicleType:           domain.VehicleTypeCar,
+			SlotID:                other.Slots[0].ID,
+			SlotNumber:            1,
+			SlotLabel:             "1",
+			ParkedAt:              second.ParkedAt,
+			DurationSeconds:       15 * 60,
+		},
+		{
+			ID:                    first.ID,
+			ParkingLotID:          lot.ID,
+			TimeZone:              "UTC",
+			RegistrationNumber:    "ABC1",
+			RawRegistrationNumber: "ABC-1",
+			VehicleType:           domain.VehicleTypeCar,
+			SlotID:                lot.Slots[0].ID,
+			SlotNumber:            1,
+			SlotLabel:             "1",
+			ParkedAt:              first.ParkedAt,
+			UnparkedAt:            unparked.UnparkedAt,
+			DurationSeconds:       90 * 60,
+			Fee:                   &fee,
+			Currency:              "USD",
+		},
+	}}
+	if !reflect.DeepEqual(*page, expected) {
+		s.t.Errorf("ListVehicleSessions returned %+v; expected %+v", *page, expected)
+	}
+
+	// parked in [from, to), oldest first.
+	page, appErr = s.sessions.ListVehicleSessions(s.ctx, "ABC-1", domain.SessionQuery{
+		From:  start.Add(time.Minute),
+		To:    s.clock.Now(),
+		Order: domain.SessionOrderAsc,
+	})
+	if appErr != nil || len(page.Sessions) != 1 || page.Sessions[0].ID != second.ID {
+		s.t.Errorf("ListVehicleSessions in a time range returned %+v, %v; expected session %s", page, appErr, second.ID)
+	}
+
+	if page, appErr = s.sessions.ListVehicleSessions(s.ctx, "XYZ-1", domain.SessionQuery{}); appErr != nil || len(page.Sessions) != 0 {
+		s.t.Errorf("ListVehicleSessions of an unknown vehicle returned %+v, %v; expected no sessions", page, appErr)
+	}
+
+	invalid := []domain.SessionQuery{
+		{Limit: domain.MaxSessionLimit + 1},
+		{Order: "sideways"},
+		{From: start, To: start},
+		{Cursor: "not-a-cursor"},
+	}
+
+	for _, q := range invalid {
+		_, appErr = s.sessions.ListVehicleSessions(s.ctx, "ABC-1", q)
+		s.expectCode("ListVehicleSessions with an invalid query", appErr, http.StatusBadRequest)
+	}
+}
+
+func testLotSessionsPagination(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+	s.park(other.ID, "OTHER-1")
+
+	// 5 sessions, two of them parked at the same time to exercise the ID tie break.
+	var parked []uuid.UUID
+	for i := 1; i <= 5; i++ {
+		regNum := fmt.Sprintf("ABC-%d", i)
+		parked = append(parked, s.park(lot.ID, regNum).ID)
+		if i%2 == 0 {
+			s.clock.Advance(time.Minute)
+		}
+
+		if i > 1 {
+			s.unpark(lot.ID, fmt.Sprintf("ABC-%d", i-1))
+		}
+	}
+
+	for _, order := range []domain.SessionOrder{domain.SessionOrderAsc, domain.SessionOrderDesc} {
+		var got []uuid.UUID
+		q := domain.SessionQuery{Limit: 2, Order: order}
+		for pages := 0; ; pages++ {
+			page, appErr := s.sessions.ListLotSessions(s.ctx, lot.ID, q)
+			if appErr != nil {
+				s.t.Fatalf("ListLotSessions returned error %v", appErr)
+			}
+
+			if pages > 3 || len(page.Sessions) > 2 {
+				s.t.Fatalf("ListLotSessions returned %d sessions on page %d; expected 3 pages of up to 2", len(page.Sessions), pages+1)
+			}
+
+			for _, session := range page.Sessions {
+				got = append(got, session.ID)
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+
+			q.Cursor = page.NextCursor
+		}
+
+		expected := sortedSessionIDs(parked, order)
+		if !reflect.DeepEqual(got, expected) {
+			s.t.Errorf("ListLotSessions in %s order returned %v; expected %v", order, got, expected)
+		}
+	}
+
+	_, appErr := s.sessions.ListLotSessions(s.ctx, uuid.New(), domain.SessionQuery{})
+	s.expectCode("ListLotSessions of an unknown lot", appErr, http.StatusNotFound)
+}
+
+// sortedSessionIDs sorts the IDs of sessions parked in pairs, a minute apart, in the pagination order:
+// park time, then ID.
+func sortedSessionIDs(ids []uuid.UUID, order domain.SessionOrder) []uuid.UUID {
+	sorted := slices.Clone(ids)
+	slices.SortStableFunc(sorted, func(a, b uuid.UUID) int {
+		ia, ib := slices.Index(ids, a), slices.Index(ids, b)
+		if c := cmp.Compare(ia/2, ib/2); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.String(), b.String())
+	})
+
+	if order == domain.SessionOrderDesc {
+		slices.Reverse(sorted)
+	}
+
+	return sorted
+}
+
+func testStreamSessions(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+	s.park(other.ID, "ABC-1")
+
+	// ABC-1 parked in both lots over time, more sessions than the default limit in this lot.
+	s.unpark(other.ID, "ABC-1")
+	var parked []uuid.UUID
+	for i := 1; i <= domain.DefaultSessionLimit+5; i++ {
+		s.clock.Advance(time.Minute)
+		regNum := fmt.Sprintf("ABC-%d", i)
+		parked = append(parked, s.park(lot.ID, regNum).ID)
+		s.unpark(lot.ID, regNum)
+	}
+
+	var got []uuid.UUID
+	appErr := s.sessions.StreamLotSessions(s.ctx, lot.ID, domain.SessionQuery{Limit: 1, Order: domain.SessionOrderAsc}, func(session *domain.Session) error {
+		got = append(got, session.ID)
+		return nil
+	})
+	if appErr != nil || !reflect.DeepEqual(got, parked) {
+		s.t.Errorf("StreamLotSessions returned %v, %v; expected every session in order %v", got, appErr, parked)
+	}
+
+	// sessions after the cursor of the first page, parked from the second minute on.
+	page, appErr := s.sessions.ListLotSessions(s.ctx, lot.ID, domain.SessionQuery{Limit: 2, Order: domain.SessionOrderAsc})
+	if appErr != nil {
+		s.t.Fatalf("ListLotSessions returned error %v", appErr)
+	}
+
+	streamed := 0
+	q := domain.SessionQuery{Cursor: page.NextCursor, Order: domain.SessionOrderAsc, To: s.clock.Now()}
+	appErr = s.sessions.StreamLotSessions(s.ctx, lot.ID, q, func(*domain.Session) error {
+		streamed++
+		return nil
+	})
+	if appErr != nil || streamed != len(parked)-3 {
+		s.t.Errorf("StreamLotSessions after a cursor streamed %d sessions, %v; expected %d", streamed, appErr, len(parked)-3)
+	}
+
+	var vehicleSessions []uuid.UUID
+	appErr = s.sessions.StreamVehicleSessions(s.ctx, "abc 1", domain.SessionQuery{}, func(session *domain.Session) error {
+		vehicleSessions = append(vehicleSessions, session.ParkingLotID)
+		return nil
+	})
+	if appErr != nil || !reflect.DeepEqual(vehicleSessions, []uuid.UUID{lot.ID, other.ID}) {
+		s.t.Errorf("StreamVehicleSessions returned sessions in lots %v, %v; expected the newest in %s first", vehicleSessions, appErr, lot.Name)
+	}
+
+	calls := 0
+	appErr = s.sessions.StreamLotSessions(s.ctx, lot.ID, domain.SessionQuery{}, func(*domain.Session) error {
+		calls++
+		return errors.New("client went away")
+	})
+	s.expectCode("StreamLotSessions failing to write", appErr, http.StatusInternalServerError)
+	if calls != 1 {
+		s.t.Errorf("StreamLotSessions called fn %d times after it failed; expected 1", calls)
+	}
+
+	appErr = s.sessions.StreamLotSessions(s.ctx, uuid.New(), domain.SessionQuery{}, func(*domain.Session) error { return nil })
+	s.expectCode("StreamLotSessions of an unknown lot", appErr, http.StatusNotFound)
+
+	appErr = s.sessions.StreamLotSessions(s.ctx, lot.ID, domain.SessionQuery{Order: "up"}, func(*domain.Session) error { return nil })
+	s.expectCode("StreamLotSessions in an unknown order", appErr, http.StatusBadRequest)
+}
+
+func testOccupancyAnalytics(s *suite) {
+	lot := s.createLot("Parking Lot 1", 2)
+	other := s.createLot("Parking Lot 2", 1)
+	at := func(hour, minute int) time.Time { return time.Date(2024, time.March, 13, hour, minute, 0, 0, time.UTC) }
+
+	// parked at 08:00 in slot 1, before the period, it counts towards occupancy only.
+	s.clock.Advance(-2 * time.Hour)
+	s.park(lot.ID, "PRE-1")
+	s.clock.Advance(2 * time.Hour)
+
+	// A-1 in slot 2 10:00 -> 11:30, B-1 in slot 1 11:00 -> 12:00, C-1 in slot 1 from 12:00, still parked at 12:30.
+	s.park(lot.ID, "A-1")
+	s.park(other.ID, "OTHER-1")
+	s.clock.Advance(30 * time.Minute)
+	s.unpark(lot.ID, "PRE-1")
+	s.clock.Advance(30 * time.Minute)
+	s.park(lot.ID, "B-1")
+	s.clock.Advance(30 * time.Minute)
+	s.unpark(lot.ID, "A-1")
+	s.clock.Advance(30 * time.Minute)
+	s.unpark(lot.ID, "B-1")
+	s.park(lot.ID, "C-1")
+	s.clock.Advance(30 * time.Minute)
+
+	analytics, appErr := s.analytics.GetOccupancyAnalytics(s.ctx, lot.ID, domain.AnalyticsQuery{From: at(9, 0), To: at(13, 30)})
+	if appErr != nil {
+		s.t.Fatalf("GetOccupancyAnalytics returned error %v", appErr)
+	}
+
+	// measured up to 12:30, the 13:00 hour hasn't started yet.
+	peakAt := at(10, 0)
+	expected := domain.OccupancyAnalytics{
+		ParkingLotID:       lot.ID,
+		TimeZone:           "UTC",
+		From:               at(9, 0),
+		To:                 at(14, 0),
+		Capacity:           2,
+		UtilizationPercent: 64.29,
+		AverageOccupancy:   1.29,
+		PeakOccupancy:      2,
+		PeakAt:             &peakAt,
+		PeakHour:           &domain.HourOfDayOccupancy{Hour: 10, AverageOccupancy: 1.5},
+		VehiclesParked:     3,
+		AverageTurnover:    1.5,
+		Dwell:              domain.DwellDistribution{Stays: 2, AverageSeconds: 4500, P50Seconds: 3600, P90Seconds: 5400, P99Seconds: 5400},
+		Hourly: []domain.OccupancyBucket{
+			{Start: at(9, 0), End: at(10, 0), AverageOccupancy: 1, PeakOccupancy: 1, UtilizationPercent: 50},
+			{Start: at(10, 0), End: at(11, 0), AverageOccupancy: 1.5, PeakOccupancy: 2, UtilizationPercent: 75},
+			{Start: at(11, 0), End: at(12, 0), AverageOccupancy: 1.5, PeakOccupancy: 2, UtilizationPercent: 75},
+			{Start: at(12, 0), End: at(13, 0), AverageOccupancy: 1, PeakOccupancy: 1, UtilizationPercent: 50},
+			{Start: at(13, 0), End: at(14, 0)},
+		},
+		HourOfDay: make([]domain.HourOfDayOccupancy, 24),
+		Slots: []domain.SlotUtilization{
+			{SlotID: lot.Slots[0].ID, SlotNumber: 1, Label: lot.Slots[0].Label, OccupiedSeconds: 3 * 3600, UtilizationPercent: 85.71, Turnover: 2},
+			{SlotID: lot.Slots[1].ID, SlotNumber: 2, Label: lot.Slots[1].Label, OccupiedSeconds: 5400, UtilizationPercent: 42.86, Turnover: 1},
+		},
+	}
+	for hour := range expected.HourOfDay {
+		expected.HourOfDay[hour].Hour = hour
+	}
+
+	expected.HourOfDay[9].AverageOccupancy, expected.HourOfDay[10].AverageOccupancy = 1, 1.5
+	expected.HourOfDay[11].AverageOccupancy, expected.HourOfDay[12].AverageOccupancy = 1.5, 1
+	if !reflect.DeepEqual(*analytics, expected) {
+		s.t.Errorf("GetOccupancyAnalytics returned %+v; expected %+v", *analytics, expected)
+	}
+
+	// a period without stays has no peak.
+	analytics, appErr = s.analytics.GetOccupancyAnalytics(s.ctx, lot.ID, domain.AnalyticsQuery{From: at(6, 0), To: at(7, 0)})
+	if appErr != nil || analytics.PeakAt != nil || analytics.PeakHour != nil || analytics.UtilizationPercent != 0 || analytics.Dwell.Stays != 0 {
+		s.t.Errorf("GetOccupancyAnalytics of an empty period returned %+v, %v; expected no usage", analytics, appErr)
+	}
+
+	_, appErr = s.analytics.GetOccupancyAnalytics(s.ctx, uuid.New(), domain.AnalyticsQuery{From: at(9, 0), To: at(13, 0)})
+	s.expectCode("GetOccupancyAnalytics of an unknown lot", appErr, http.StatusNotFound)
+
+	_, appErr = s.analytics.GetOccupancyAnalytics(s.ctx, lot.ID, domain.AnalyticsQuery{From: at(13, 0), To: at(9, 0)})
+	s.expectCode("GetOccupancyAnalytics ending before it starts", appErr, http.StatusBadRequest)
+
+	_, appErr = s.analytics.GetOccupancyAnalytics(s.ctx, lot.ID, domain.AnalyticsQuery{From: at(0, 0), To: at(0, 0).AddDate(0, 3, 0)})
+	s.expectCode("GetOccupancyAnalytics of a period too long", appErr, http.StatusBadRequest)
+}
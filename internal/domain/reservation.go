@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+// ReservationStatus is the state of a reservation, only active reservations hold their slot.
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationFulfilled ReservationStatus = "fulfilled" // the vehicle parked during the hold
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationExpired   ReservationStatus = "expired" // the vehicle didn't show up before the hold ended
+)
+
+const (
+	// DefaultReservationHold is how long after its start a reservation holds the slot for a driver who hasn't shown up.
+	DefaultReservationHold = 15 * time.Minute
+
+	// ReservationLeadTime is how long before its start a reservation holds the slot, so a vehicle parking without
+	// a reservation doesn't take it just before the driver arrives. Drivers arriving early get their reserved slot too.
+	ReservationLeadTime = 30 * time.Minute
+
+	// WalkInStay is how long a vehicle parking without a reservation is expected to stay. It isn't given a slot reserved
+	// to start within WalkInStay, so the slot is still free when the driver with the reservation arrives.
+	WalkInStay = 4 * time.Hour
+)
+
+// Reservation books a slot of a parking lot for a vehicle of its type between StartsAt and EndsAt.
+// The slot is held from ReservationLeadTime before StartsAt until HoldUntil, when an unfulfilled reservation expires
+// and the slot is released for the rest of the window. Parking the vehicle during the hold fulfils the reservation.
+// Expiry isn't stored, an active reservation whose hold ended is reported expired when it's read.
+type Reservation struct {
+	ID                    uuid.UUID         `json:"id"`
+	ParkingLotID          uuid.UUID         `json:"parkingLotId"`
+	SlotID                uuid.UUID         `json:"slotId"`
+	SlotLabel             string            `json:"slotLabel"`
+	RegistrationNumber    string            `json:"registrationNumber"`              // canonical, see plate.Canonicalize
+	RawRegistrationNumber string            `json:"rawRegistrationNumber,omitempty"` // as entered, canonical for reservations made before it was stored
+	VehicleType           VehicleType       `json:"vehicleType"`
+	StartsAt              time.Time         `json:"startsAt"`
+	EndsAt                time.Time         `json:"endsAt"`
+	HoldUntil             time.Time         `json:"holdUntil"`
+	Status                ReservationStatus `json:"status"`
+	CreatedAt             time.Time         `json:"createdAt"`
+}
+
+// ReservationRequest describes a reservation to make, Hold defaults to DefaultReservationHold.
+// The slot is chosen like a park with the same options: best type fit, preferred level and zone, then nearest.
+type ReservationRequest struct {
+	RegistrationNumber string
+	StartsAt           time.Time
+	EndsAt             time.Time
+	Hold               time.Duration
+	Options            ParkOptions
+
+	rawRegistrationNumber string // as entered, set by normalize
+}
+
+// normalize canonicalizes the registration number, keeping it as entered, checks the reservation window at now,
+// and returns when its hold ends.
+func (r *ReservationRequest) normalize(now time.Time) (time.Time, common.AppError) {
+	r.rawRegistrationNumber = strings.TrimSpace(r.RegistrationNumber)
+	r.RegistrationNumber = plate.Canonicalize(r.RegistrationNumber)
+
+	switch {
+	case r.RegistrationNumber == "":
+		return time.Time{}, common.NewBadRequestError(plate.ErrEmpty.Error())
+	case !r.EndsAt.After(r.StartsAt):
+		return time.Time{}, common.NewBadRequestError("reservation must end after it starts")
+	case r.StartsAt.Before(now):
+		return time.Time{}, common.NewBadRequestError("reservation can't start in the past")
+	case r.Hold < 0:
+		return time.Time{}, common.NewBadRequestError("reservation hold can't be negative")
+	}
+
+	hold := r.Hold
+	if hold == 0 {
+		hold = DefaultReservationHold
+	}
+
+	holdUntil := r.StartsAt.Add(hold)
+	if holdUntil.After(r.EndsAt) {
+		holdUntil = r.EndsAt
+	}
+
+	return holdUntil, nil
+}
+
+// expire marks an active reservation whose hold ended before now as expired, callers apply it to the copy they return.
+func (r *Reservation) expire(now time.Time) {
+	if r.Status == ReservationActive && now.After(r.HoldUntil) {
+		r.Status = ReservationExpired
+	}
+}
+
+// heldAt reports whether the reservation holds its slot for a vehicle of type vType at t.
+func (r *Reservation) heldAt(t time.Time, vType VehicleType) bool {
+	return r.Status == ReservationActive && r.VehicleType == vType && !r.StartsAt.After(t.Add(ReservationLeadTime)) && !r.HoldUntil.Before(t)
+}
+
+// keepsWalkInsAt reports whether the reservation keeps vehicles parking without it off its slot at t,
+// it's active and starts within WalkInStay.
+func (r *Reservation) keepsWalkInsAt(t time.Time) bool {
+	return r.Status == ReservationActive && !r.StartsAt.After(t.Add(WalkInStay)) && !r.HoldUntil.Before(t)
+}
+
+// blocks reports whether the reservation keeps its slot or vehicle from another reservation between start and end at now.
+// Fulfilled reservations block for their whole window, the vehicle is expected to stay until EndsAt.
+func (r *Reservation) blocks(start, end, now time.Time) bool {
+	live := r.Status == ReservationFulfilled || (r.Status == ReservationActive && !r.HoldUntil.Before(now))
+	return live && r.StartsAt.Before(end) && r.EndsAt.After(start)
+}
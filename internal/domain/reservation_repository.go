@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// ReservationRepository defines the interface for booking slots ahead of time,
+// implemented for the postgresql database by ReservationRepoDB and in memory by ReservationRepoMemory.
+// VehicleRepository.ParkVehicle fulfils the reservations.
+type ReservationRepository interface {
+	CreateReservation(ctx context.Context, plUUID uuid.UUID, req ReservationRequest) (*Reservation, common.AppError)
+	GetReservation(ctx context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError)
+	CancelReservation(ctx context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError)
+}
+
+var _ ReservationRepository = (*ReservationRepoDB)(nil)
+
+type ReservationRepoDB struct {
+	db  *sql.DB
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewReservationRepoDB(db *sql.DB, l *slog.Logger) *ReservationRepoDB {
+	return &ReservationRepoDB{
+		db:  db,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used to validate windows and expire holds, lets tests control time.
+func (r *ReservationRepoDB) WithClock(now func() time.Time) *ReservationRepoDB {
+	r.now = now
+	return r
+}
+
+// CreateReservation performs the following within a serializable transaction to prevent double booking:
+// 1. Returns a 409 Conflict error if the vehicle already has a live reservation overlapping the window, active reservations
+// whose hold ended are expired and don't count.
+// 2. Locates and locks the best fitting slot for the vehicle type that is not under maintenance, free now if the hold begins by now,
+// and without a live reservation or a permit assigning it overlapping the window, ranked like a park: type fit, preferred level
+// and zone, free now, entrance distance, slot number.
+// 3. Returns a 409 Conflict error if there is no such slot, 400 Bad Request for an invalid window.
+func (r *ReservationRepoDB) CreateReservation(ctx context.Context, plUUID uuid.UUID, req ReservationRequest) (*Reservation, common.AppError) {
+	now := r.now().UTC()
+	holdUntil, appErr := req.normalize(now)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var res *Reservation
+	appErr = withSerializableTx(ctx, r.db, r.l, "CreateReservation", func(tx *sql.Tx) common.AppError {
+		var overlaps bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT EXISTS(SELECT 1 FROM reservations
+                          WHERE registration_number = $1 AND starts_at < $4 AND ends_at > $5
+                            AND (status = $3 OR (status = $2 AND hold_until >= $6)))`,
+			req.RegistrationNumber, ReservationActive, ReservationFulfilled, req.EndsAt, req.StartsAt, now).Scan(&overlaps)
+		if err != nil {
+			r.l.Error("error checking overlapping reservations", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		} else if overlaps {
+			return common.NewConflictError(common.ErrReservationOverlaps)
+		}
+
+		var slotID int
+		var slotLabel string
+		query := fmt.Sprintf(`
+            SELECT s.id, s.label
+            FROM slots s
+            LEFT JOIN parking_levels lv ON s.level_id = lv.id
+            WHERE s.parking_lot_id = $1 AND (s.is_available = true OR NOT $10::boolean) AND s.is_maintenance = false
+              AND s.slot_type = ANY($2::text[])
+              AND NOT EXISTS (SELECT 1 FROM reservations r
+                              WHERE r.slot_id = s.id AND r.starts_at < $7 AND r.ends_at > $8
+                                AND (r.status = $6 OR (r.status = $5 AND r.hold_until >= $9)))
+              AND NOT EXISTS (SELECT 1 FROM permits p
+                              WHERE p.slot_id = s.id AND p.revoked_at IS NULL AND p.valid_from < $7 AND p.valid_until > $8)
+            ORDER BY %s,
+                     s.is_available DESC, COALESCE(lv.entrance_distance, 0), s.slot_number
+            LIMIT 1
+            FOR UPDATE OF s`, slotRankOrderBy) //nolint:gosec // the ordering never contains user input
+
+		// a slot occupied now may be free by the time a later hold begins.
+		heldNow := !req.StartsAt.After(now.Add(ReservationLeadTime))
+		err = tx.QueryRowContext(ctx, query, plID, req.Options.VehicleType.compatibleSlotTypeNames(), req.Options.PreferredLevel,
+			req.Options.PreferredZone, ReservationActive, ReservationFulfilled, req.EndsAt, req.StartsAt, now, heldNow).Scan(&slotID, &slotLabel)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.l.Error("no slot available for reservation", "parking_lot_id", plID, "starts_at", req.StartsAt, "ends_at", req.EndsAt)
+			return common.NewConflictError(common.ErrNoSlotForReservation)
+		} else if err != nil {
+			r.l.Error("error finding slot to reserve", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		var resUUID uuid.UUID
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO reservations (parking_lot_id, slot_id, registration_number, registration_number_raw, vehicle_type, starts_at, ends_at,
+                                      hold_until, status, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            RETURNING uuid`, plID, slotID, req.RegistrationNumber, req.rawRegistrationNumber, req.Options.VehicleType, req.StartsAt, req.EndsAt,
+			holdUntil, ReservationActive, now).Scan(&resUUID)
+		if err != nil {
+			r.l.Error("error creating reservation", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		r.l.Info("slot reserved", "slot", slotLabel, "vehicle", req.RegistrationNumber, "starts_at", req.StartsAt, "hold_until", holdUntil)
+
+		var getErr common.AppError
+		res, getErr = r.getReservation(ctx, tx, plID, plUUID, resUUID, now)
+		return getErr
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return res, nil
+}
+
+// GetReservation returns a reservation of a parking lot, expired if its hold ended unfulfilled.
+func (r *ReservationRepoDB) GetReservation(ctx context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return r.getReservation(ctx, r.db, plID, plUUID, resUUID, r.now().UTC())
+}
+
+// CancelReservation cancels an active reservation, releasing its slot.
+// Returns a 409 Conflict error if the reservation is already fulfilled, cancelled or expired.
+func (r *ReservationRepoDB) CancelReservation(ctx context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	now := r.now().UTC()
+	var res *Reservation
+	appErr = withSerializableTx(ctx, r.db, r.l, "CancelReservation", func(tx *sql.Tx) common.AppError {
+		var current Reservation
+		err := tx.QueryRowContext(ctx, `
+            SELECT status, hold_until FROM reservations WHERE uuid = $1 AND parking_lot_id = $2 FOR UPDATE`, resUUID, plID).Scan(
+			&current.Status, &current.HoldUntil)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NewNotFoundError("reservation not found in this parking lot")
+		} else if err != nil {
+			r.l.Error("error fetching reservation", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		current.expire(now)
+		if current.Status != ReservationActive {
+			return common.NewConflictError("reservation is " + string(current.Status))
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE reservations SET status = $1 WHERE uuid = $2", ReservationCancelled, resUUID); err != nil {
+			r.l.Error("error cancelling reservation", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		var getErr common.AppError
+		res, getErr = r.getReservation(ctx, tx, plID, plUUID, resUUID, now)
+		return getErr
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return res, nil
+}
+
+// getReservation loads a reservation of a parking lot, expired if its hold ended unfulfilled before now.
+// Returns a 404 Not Found error if it belongs to another parking lot.
+func (r *ReservationRepoDB) getReservation(ctx context.Context, q queryer, plID int, plUUID, resUUID uuid.UUID,
+	now time.Time) (*Reservation, common.AppError) {
+	res := Reservation{ParkingLotID: plUUID}
+	err := q.QueryRowContext(ctx, `
+        SELECT r.uuid, s.uuid, s.label, r.registration_number, COALESCE(r.registration_number_raw, r.registration_number), r.vehicle_type,
+               r.starts_at, r.ends_at, r.hold_until, r.status, r.created_at
+        FROM reservations r
+        JOIN slots s ON r.slot_id = s.id
+        WHERE r.uuid = $1 AND r.parking_lot_id = $2`, resUUID, plID).Scan(
+		&res.ID, &res.SlotID, &res.SlotLabel, &res.RegistrationNumber, &res.RawRegistrationNumber, &res.VehicleType, &res.StartsAt, &res.EndsAt, &res.HoldUntil,
+		&res.Status, &res.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NewNotFoundError("reservation not found in this parking lot")
+	} else if err != nil {
+		r.l.Error("error fetching reservation", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	res.StartsAt, res.EndsAt, res.HoldUntil, res.CreatedAt = res.StartsAt.UTC(), res.EndsAt.UTC(), res.HoldUntil.UTC(), res.CreatedAt.UTC()
+	res.expire(now)
+	return &res, nil
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+var _ ReservationRepository = (*ReservationRepoMemory)(nil)
+
+// ReservationRepoMemory implements ReservationRepository in memory, with the same semantics as ReservationRepoDB.
+type ReservationRepoMemory struct {
+	s   *MemoryStore
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewReservationRepoMemory(s *MemoryStore, l *slog.Logger) *ReservationRepoMemory {
+	return &ReservationRepoMemory{
+		s:   s,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used to validate windows and expire holds, lets tests control time.
+func (r *ReservationRepoMemory) WithClock(now func() time.Time) *ReservationRepoMemory {
+	r.now = now
+	return r
+}
+
+// CreateReservation reserves the best fitting slot without a conflicting reservation or permit, free now if the hold begins by now,
+// ranked like ReservationRepoDB.
+// Returns a 400 Bad Request error for an invalid window, 404 Not Found for unknown parking lots,
+// 409 Conflict if the vehicle already has a reservation overlapping the window or no slot is free for it.
+func (r *ReservationRepoMemory) CreateReservation(_ context.Context, plUUID uuid.UUID, req ReservationRequest) (*Reservation, common.AppError) {
+	now := r.now().UTC()
+	holdUntil, appErr := req.normalize(now)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	lot, ok := r.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	for _, res := range r.s.reservations {
+		if res.RegistrationNumber == req.RegistrationNumber && res.blocks(req.StartsAt, req.EndsAt, now) {
+			return nil, common.NewConflictError(common.ErrReservationOverlaps)
+		}
+	}
+
+	distances := make(map[string]int, len(lot.levels))
+	for _, level := range lot.levels {
+		distances[level.Name] = level.EntranceDistance
+	}
+
+	rank := func(s *memSlot) []int {
+		occupied := 0
+		if !s.IsAvailable {
+			occupied = 1
+		}
+
+		return []int{slotFit(req.Options.VehicleType, s.Type), req.Options.preferenceRank(s.Level, s.Zone), occupied, distances[s.Level]}
+	}
+
+	heldNow := !req.StartsAt.After(now.Add(ReservationLeadTime))
+
+	var slot *memSlot
+	for _, s := range lot.slots {
+		if (heldNow && !s.IsAvailable) || s.IsMaintenance || slotFit(req.Options.VehicleType, s.Type) < 0 ||
+			r.s.slotReserved(s.ID, req.StartsAt, req.EndsAt, now) || r.s.slotAssigned(s.ID, req.StartsAt, req.EndsAt) {
+			continue
+		}
+
+		// slots are ordered by slot number, so only a strictly better rank replaces the chosen slot.
+		if slot == nil || lessRank(rank(s), rank(slot)) {
+			slot = s
+		}
+	}
+
+	if slot == nil {
+		r.l.Error("no slot available for reservation", "parking_lot_id", plUUID, "starts_at", req.StartsAt, "ends_at", req.EndsAt)
+		return nil, common.NewConflictError(common.ErrNoSlotForReservation)
+	}
+
+	res := &Reservation{
+		ID:                    uuid.New(),
+		ParkingLotID:          plUUID,
+		SlotID:                slot.ID,
+		SlotLabel:             slot.Label,
+		RegistrationNumber:    req.RegistrationNumber,
+		RawRegistrationNumber: req.rawRegistrationNumber,
+		VehicleType:           req.Options.VehicleType,
+		StartsAt:              req.StartsAt.UTC(),
+		EndsAt:                req.EndsAt.UTC(),
+		HoldUntil:             holdUntil.UTC(),
+		Status:                ReservationActive,
+		CreatedAt:             now,
+	}
+	r.s.reservations = append(r.s.reservations, res)
+
+	r.l.Info("slot reserved", "slot", slot.Label, "vehicle", req.RegistrationNumber, "starts_at", res.StartsAt, "hold_until", res.HoldUntil)
+	result := *res
+	return &result, nil
+}
+
+// GetReservation returns a reservation of a parking lot, expired if its hold ended unfulfilled.
+func (r *ReservationRepoMemory) GetReservation(_ context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res, appErr := r.reservation(plUUID, resUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	result := *res
+	result.expire(r.now().UTC())
+	return &result, nil
+}
+
+// CancelReservation cancels an active reservation, releasing its slot.
+// Returns a 409 Conflict error if the reservation is already fulfilled, cancelled or expired.
+func (r *ReservationRepoMemory) CancelReservation(_ context.Context, plUUID, resUUID uuid.UUID) (*Reservation, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res, appErr := r.reservation(plUUID, resUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	current := *res
+	current.expire(r.now().UTC())
+	if current.Status != ReservationActive {
+		return nil, common.NewConflictError("reservation is " + string(current.Status))
+	}
+
+	res.Status = ReservationCancelled
+	result := *res
+	return &result, nil
+}
+
+// reservation finds a reservation of a parking lot. Callers must hold mu.
+func (r *ReservationRepoMemory) reservation(plUUID, resUUID uuid.UUID) (*Reservation, common.AppError) {
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	for _, res := range r.s.reservations {
+		if res.ID == resUUID && res.ParkingLotID == plUUID {
+			return res, nil
+		}
+	}
+
+	return nil, common.NewNotFoundError("reservation not found in this parking lot")
+}
@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/google/uuid"
+)
+
+// Session is a single stay of a vehicle in a parking lot, from park to unpark.
+// Duration runs until now while the vehicle is still parked, Fee is set once it's unparked. TimeZone is the parking lot's.
+type Session struct {
+	ID                    uuid.UUID   `json:"id"`
+	ParkingLotID          uuid.UUID   `json:"parkingLotId"`
+	TimeZone              string      `json:"timeZone"`
+	RegistrationNumber    string      `json:"registrationNumber"`
+	RawRegistrationNumber string      `json:"rawRegistrationNumber"`
+	VehicleType           VehicleType `json:"vehicleType"`
+	SlotID                uuid.UUID   `json:"slotId"`
+	SlotNumber            int         `json:"slotNumber"`
+	SlotLabel             string      `json:"slotLabel"`
+	ParkedAt              time.Time   `json:"parkedAt"`
+	UnparkedAt            *time.Time  `json:"unparkedAt,omitempty"`
+	DurationSeconds       int64       `json:"durationSeconds"`
+	Fee                   *int        `json:"fee,omitempty"`
+	Currency              string      `json:"currency,omitempty"`
+	PermitID              *uuid.UUID  `json:"permitId,omitempty"`
+}
+
+// SessionOrder sorts sessions by park time, newest first by default.
+type SessionOrder string
+
+const (
+	SessionOrderDesc SessionOrder = "desc"
+	SessionOrderAsc  SessionOrder = "asc"
+)
+
+const (
+	DefaultSessionLimit = 50
+	MaxSessionLimit     = 200
+)
+
+// SessionQuery filters and pages sessions. Sessions parked in [From, To) are returned, a zero bound is open.
+// Cursor is the NextCursor of the previous page, empty for the first page. Streams ignore Limit.
+type SessionQuery struct {
+	From   time.Time
+	To     time.Time
+	Cursor string
+	Limit  int
+	Order  SessionOrder
+}
+
+// SessionPage is a page of sessions, NextCursor is empty on the last page.
+type SessionPage struct {
+	Sessions   []Session `json:"sessions"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// InTimeZone renders the park and unpark times of every session in its parking lot's time zone instead of UTC.
+// Cursors are unaffected, they hold the instant of the last session.
+func (p *SessionPage) InTimeZone() {
+	for i := range p.Sessions {
+		p.Sessions[i].InTimeZone()
+	}
+}
+
+// InTimeZone renders the park and unpark times of the session in its parking lot's time zone instead of UTC.
+func (s *Session) InTimeZone() {
+	loc := lotLocation(s.TimeZone)
+	timeIn(&s.ParkedAt, loc)
+	timeIn(s.UnparkedAt, loc)
+}
+
+// sessionCursor is the position of the last session of a page, sessions are ordered by park time then ID.
+type sessionCursor struct {
+	parkedAt time.Time
+	id       uuid.UUID
+}
+
+// normalize applies the default limit and order, and decodes the cursor, nil for the first page.
+func (q *SessionQuery) normalize() (*sessionCursor, common.AppError) {
+	if q.Limit == 0 {
+		q.Limit = DefaultSessionLimit
+	}
+
+	if q.Order == "" {
+		q.Order = SessionOrderDesc
+	}
+
+	switch {
+	case q.Limit < 0 || q.Limit > MaxSessionLimit:
+		return nil, common.NewBadRequestError("limit must be between 1 and 200")
+	case q.Order != SessionOrderDesc && q.Order != SessionOrderAsc:
+		return nil, common.NewBadRequestError("order must be asc or desc")
+	case !q.From.IsZero() && !q.To.IsZero() && !q.To.After(q.From):
+		return nil, common.NewBadRequestError("to must be after from")
+	case q.Cursor == "":
+		return nil, nil
+	}
+
+	cursor, err := decodeSessionCursor(q.Cursor)
+	if err != nil {
+		return nil, common.NewBadRequestError("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// after reports whether a session parked at parkedAt with id comes after the cursor in the query order.
+func (c *sessionCursor) after(parkedAt time.Time, id uuid.UUID, order SessionOrder) bool {
+	cmp := parkedAt.Compare(c.parkedAt)
+	if cmp == 0 {
+		cmp = strings.Compare(id.String(), c.id.String())
+	}
+
+	if order == SessionOrderAsc {
+		return cmp > 0
+	}
+
+	return cmp < 0
+}
+
+// encode returns the opaque form of the cursor handed to clients.
+func (c *sessionCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.parkedAt.UTC().Format(time.RFC3339Nano) + "|" + c.id.String()))
+}
+
+func decodeSessionCursor(s string) (*sessionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	parkedAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("missing cursor separator")
+	}
+
+	var c sessionCursor
+	if c.parkedAt, err = time.Parse(time.RFC3339Nano, parkedAt); err != nil {
+		return nil, err
+	}
+
+	if c.id, err = uuid.Parse(id); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// newSessionPage returns the first limit sessions, and the cursor of the last one if more sessions follow.
+// sessions holds up to limit+1 sessions, the extra one tells whether there's a next page.
+func newSessionPage(sessions []Session, limit int) *SessionPage {
+	if len(sessions) <= limit {
+		return &SessionPage{Sessions: sessions}
+	}
+
+	last := sessions[limit-1]
+	return &SessionPage{
+		Sessions:   sessions[:limit],
+		NextCursor: (&sessionCursor{parkedAt: last.ParkedAt, id: last.ID}).encode(),
+	}
+}
+
+// sessionDuration returns the seconds between park and unpark, or now while still parked.
+func sessionDuration(parkedAt time.Time, unparkedAt *time.Time, now time.Time) int64 {
+	if unparkedAt != nil {
+		now = *unparkedAt
+	}
+
+	return int64(now.Sub(parkedAt) / time.Second)
+}
@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+// SessionRepository defines the interface for looking up the parking history of vehicles and parking lots,
+// implemented for the postgresql database by SessionRepoDB and in memory by SessionRepoMemory.
+type SessionRepository interface {
+	ListVehicleSessions(ctx context.Context, regNum string, q SessionQuery) (*SessionPage, common.AppError)
+	ListLotSessions(ctx context.Context, plUUID uuid.UUID, q SessionQuery) (*SessionPage, common.AppError)
+	StreamVehicleSessions(ctx context.Context, regNum string, q SessionQuery, fn func(*Session) error) common.AppError
+	StreamLotSessions(ctx context.Context, plUUID uuid.UUID, q SessionQuery, fn func(*Session) error) common.AppError
+}
+
+var _ SessionRepository = (*SessionRepoDB)(nil)
+
+type SessionRepoDB struct {
+	db  *sql.DB
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewSessionRepoDB(db *sql.DB, l *slog.Logger) *SessionRepoDB {
+	return &SessionRepoDB{
+		db:  db,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used for durations of ongoing sessions, lets tests control time.
+func (r *SessionRepoDB) WithClock(now func() time.Time) *SessionRepoDB {
+	r.now = now
+	return r
+}
+
+// ListVehicleSessions returns a page of the sessions of a vehicle across every parking lot, looked up by its canonical
+// registration number and served by the index on vehicles (registration_number, parked_at). An unknown vehicle has no sessions.
+func (r *SessionRepoDB) ListVehicleSessions(ctx context.Context, regNum string, q SessionQuery) (*SessionPage, common.AppError) {
+	return r.listSessions(ctx, "v.registration_number = $1", plate.Canonicalize(regNum), q)
+}
+
+// ListLotSessions returns a page of the sessions of a parking lot, 404 Not Found for unknown parking lots.
+func (r *SessionRepoDB) ListLotSessions(ctx context.Context, plUUID uuid.UUID, q SessionQuery) (*SessionPage, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return r.listSessions(ctx, "s.parking_lot_id = $1", plID, q)
+}
+
+// StreamVehicleSessions calls fn with every session of a vehicle across every parking lot after the cursor, in the query order,
+// reading them one row at a time. Stops at the first error of fn, returned as a 500 Internal Server Error.
+func (r *SessionRepoDB) StreamVehicleSessions(ctx context.Context, regNum string, q SessionQuery, fn func(*Session) error) common.AppError {
+	q.Limit = 0
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return appErr
+	}
+
+	return r.querySessions(ctx, "v.registration_number = $1", plate.Canonicalize(regNum), q, cursor, nil, fn)
+}
+
+// StreamLotSessions calls fn with every session of a parking lot after the cursor, in the query order, reading them one row
+// at a time. Returns a 404 Not Found error for unknown parking lots before calling fn.
+func (r *SessionRepoDB) StreamLotSessions(ctx context.Context, plUUID uuid.UUID, q SessionQuery, fn func(*Session) error) common.AppError {
+	plID, appErr := getIDByUUID(ctx, r.db, r.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return appErr
+	}
+
+	q.Limit = 0
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return appErr
+	}
+
+	return r.querySessions(ctx, "s.parking_lot_id = $1", plID, q, cursor, nil, fn)
+}
+
+// listSessions pages through the sessions matching filter, with keyset pagination on (parked_at, uuid):
+// each page continues strictly after the cursor's session, so pages stay stable while vehicles park.
+// filter is a constant condition on $1.
+func (r *SessionRepoDB) listSessions(ctx context.Context, filter string, arg any, q SessionQuery) (*SessionPage, common.AppError) {
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	limit := q.Limit + 1
+	sessions := make([]Session, 0, limit)
+	appErr = r.querySessions(ctx, filter, arg, q, cursor, &limit, func(s *Session) error {
+		sessions = append(sessions, *s)
+		return nil
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return newSessionPage(sessions, q.Limit), nil
+}
+
+// querySessions calls fn with the sessions matching filter after the cursor in the query order, up to limit sessions
+// or all of them when limit is nil. filter is a constant condition on $1.
+func (r *SessionRepoDB) querySessions(ctx context.Context, filter string, arg any, q SessionQuery, cursor *sessionCursor, limit *int,
+	fn func(*Session) error) common.AppError {
+	var from, to, cursorParkedAt *time.Time
+	var cursorID *uuid.UUID
+	if !q.From.IsZero() {
+		from = &q.From
+	}
+
+	if !q.To.IsZero() {
+		to = &q.To
+	}
+
+	if cursor != nil {
+		cursorParkedAt, cursorID = &cursor.parkedAt, &cursor.id
+	}
+
+	cmp, direction := "<", "DESC"
+	if q.Order == SessionOrderAsc {
+		cmp, direction = ">", "ASC"
+	}
+
+	// LIMIT NULL returns every row.
+	query := fmt.Sprintf(`
+        SELECT v.uuid, pl.uuid, pl.time_zone, v.registration_number, COALESCE(v.registration_number_raw, v.registration_number), v.vehicle_type, s.uuid, s.slot_number, s.label, v.parked_at, v.unparked_at,
+               v.fee, COALESCE(v.currency, ''), p.uuid
+        FROM vehicles v
+        JOIN slots s ON v.slot_id = s.id
+        JOIN parking_lots pl ON s.parking_lot_id = pl.id
+        LEFT JOIN permits p ON v.permit_id = p.id
+        WHERE %s
+          AND ($2::timestamptz IS NULL OR v.parked_at >= $2::timestamptz)
+          AND ($3::timestamptz IS NULL OR v.parked_at < $3::timestamptz)
+          AND ($4::timestamptz IS NULL OR (v.parked_at, v.uuid) %s ($4::timestamptz, $5::uuid))
+        ORDER BY v.parked_at %s, v.uuid %s
+        LIMIT $6`, filter, cmp, direction, direction) //nolint:gosec // the filter and ordering never contain user input
+
+	rows, err := r.db.QueryContext(ctx, query, arg, from, to, cursorParkedAt, cursorID, limit)
+	if err != nil {
+		r.l.Error("error fetching sessions", "err", err)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	now := r.now()
+	for rows.Next() {
+		var s Session
+		var fee sql.NullInt64
+		if err = rows.Scan(&s.ID, &s.ParkingLotID, &s.TimeZone, &s.RegistrationNumber, &s.RawRegistrationNumber, &s.VehicleType, &s.SlotID,
+			&s.SlotNumber, &s.SlotLabel, &s.ParkedAt, &s.UnparkedAt, &fee, &s.Currency, &s.PermitID); err != nil {
+			r.l.Error("error scanning session", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		if fee.Valid {
+			f := int(fee.Int64)
+			s.Fee = &f
+		}
+
+		s.DurationSeconds = sessionDuration(s.ParkedAt, s.UnparkedAt, now)
+		if err = fn(&s); err != nil {
+			return common.NewInternalServerError("unable to stream sessions", err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		r.l.Error("error iterating sessions", "err", err)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
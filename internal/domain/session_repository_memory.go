@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+var _ SessionRepository = (*SessionRepoMemory)(nil)
+
+// SessionRepoMemory implements SessionRepository in memory, with the same semantics as SessionRepoDB.
+type SessionRepoMemory struct {
+	s   *MemoryStore
+	l   *slog.Logger
+	now func() time.Time
+}
+
+func NewSessionRepoMemory(s *MemoryStore, l *slog.Logger) *SessionRepoMemory {
+	return &SessionRepoMemory{
+		s:   s,
+		l:   l,
+		now: time.Now,
+	}
+}
+
+// WithClock replaces the clock used for durations of ongoing sessions, lets tests control time.
+func (r *SessionRepoMemory) WithClock(now func() time.Time) *SessionRepoMemory {
+	r.now = now
+	return r
+}
+
+// ListVehicleSessions returns a page of the sessions of a vehicle across every parking lot, looked up by its canonical
+// registration number.
+func (r *SessionRepoMemory) ListVehicleSessions(_ context.Context, regNum string, q SessionQuery) (*SessionPage, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	regNum = plate.Canonicalize(regNum)
+	return r.listSessions(func(v *Vehicle) bool { return v.RegistrationNumber == regNum }, q)
+}
+
+// ListLotSessions returns a page of the sessions of a parking lot, 404 Not Found for unknown parking lots.
+func (r *SessionRepoMemory) ListLotSessions(_ context.Context, plUUID uuid.UUID, q SessionQuery) (*SessionPage, common.AppError) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	return r.listSessions(func(v *Vehicle) bool { return r.s.slots[v.SlotID].lotID == plUUID }, q)
+}
+
+// StreamVehicleSessions calls fn with every session of a vehicle across every parking lot after the cursor, in the query order.
+// Stops at the first error of fn, returned as a 500 Internal Server Error.
+func (r *SessionRepoMemory) StreamVehicleSessions(_ context.Context, regNum string, q SessionQuery, fn func(*Session) error) common.AppError {
+	regNum = plate.Canonicalize(regNum)
+	return r.streamSessions(func(v *Vehicle) bool { return v.RegistrationNumber == regNum }, q, fn)
+}
+
+// StreamLotSessions calls fn with every session of a parking lot after the cursor, in the query order.
+// Returns a 404 Not Found error for unknown parking lots before calling fn.
+func (r *SessionRepoMemory) StreamLotSessions(_ context.Context, plUUID uuid.UUID, q SessionQuery, fn func(*Session) error) common.AppError {
+	r.s.mu.Lock()
+	_, ok := r.s.lots[plUUID]
+	r.s.mu.Unlock()
+
+	if !ok {
+		return common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	return r.streamSessions(func(v *Vehicle) bool { return r.s.slots[v.SlotID].lotID == plUUID }, q, fn)
+}
+
+// streamSessions calls fn with the sessions of the vehicles matching keep. The sessions are copied under mu,
+// fn is called without holding it so slow readers don't block parking.
+func (r *SessionRepoMemory) streamSessions(keep func(v *Vehicle) bool, q SessionQuery, fn func(*Session) error) common.AppError {
+	q.Limit = 0
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return appErr
+	}
+
+	r.s.mu.Lock()
+	sessions := r.sessions(keep, q, cursor)
+	r.s.mu.Unlock()
+
+	for i := range sessions {
+		if err := fn(&sessions[i]); err != nil {
+			return common.NewInternalServerError("unable to stream sessions", err)
+		}
+	}
+
+	return nil
+}
+
+// listSessions pages through the sessions of the vehicles matching keep, ordered like SessionRepoDB. Callers must hold mu.
+func (r *SessionRepoMemory) listSessions(keep func(v *Vehicle) bool, q SessionQuery) (*SessionPage, common.AppError) {
+	cursor, appErr := q.normalize()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	sessions := r.sessions(keep, q, cursor)
+	if len(sessions) > q.Limit+1 {
+		sessions = sessions[:q.Limit+1]
+	}
+
+	return newSessionPage(sessions, q.Limit), nil
+}
+
+// sessions returns the sessions of the vehicles matching keep after the cursor, in the query order. Callers must hold mu.
+func (r *SessionRepoMemory) sessions(keep func(v *Vehicle) bool, q SessionQuery, cursor *sessionCursor) []Session {
+	now := r.now()
+	sessions := make([]Session, 0)
+	for _, v := range r.s.vehicles {
+		if !keep(v) || (!q.From.IsZero() && v.ParkedAt.Before(q.From)) || (!q.To.IsZero() && !v.ParkedAt.Before(q.To)) ||
+			(cursor != nil && !cursor.after(v.ParkedAt, v.ID, q.Order)) {
+			continue
+		}
+
+		slot := r.s.slots[v.SlotID]
+		session := Session{
+			ID:                    v.ID,
+			ParkingLotID:          slot.lotID,
+			TimeZone:              r.s.lots[slot.lotID].timeZone,
+			RegistrationNumber:    v.RegistrationNumber,
+			RawRegistrationNumber: v.RawRegistrationNumber,
+			VehicleType:           v.VehicleType,
+			SlotID:                slot.ID,
+			SlotNumber:            slot.SlotNumber,
+			SlotLabel:             slot.Label,
+			ParkedAt:              v.ParkedAt,
+			UnparkedAt:            copyTime(v.UnparkedAt),
+			DurationSeconds:       sessionDuration(v.ParkedAt, v.UnparkedAt, now),
+			Currency:              v.Currency,
+			PermitID:              copyUUID(v.PermitID),
+		}
+
+		if v.UnparkedAt != nil {
+			fee := v.Fee
+			session.Fee = &fee
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		cmp := a.ParkedAt.Compare(b.ParkedAt)
+		if cmp == 0 {
+			cmp = strings.Compare(a.ID.String(), b.ID.String())
+		}
+
+		if q.Order == SessionOrderAsc {
+			return cmp < 0
+		}
+
+		return cmp > 0
+	})
+
+	return sessions
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+)
+
+// DefaultTimeZone is the time zone of parking lots created without one.
+const DefaultTimeZone = "UTC"
+
+// LoadTimeZone returns the location of an IANA time zone, eg: "Asia/Dhaka", UTC for an empty name.
+// "Local" is rejected, it depends on the server the app runs on.
+func LoadTimeZone(name string) (*time.Location, common.AppError) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, common.NewBadRequestError("time zone must be an IANA time zone, eg: Asia/Dhaka, got " + name)
+	}
+
+	return loc, nil
+}
+
+// parseLotTimeZone validates the time zone of a parking lot to create, defaulting it when empty.
+func (lot *ParkingLot) parseLotTimeZone() common.AppError {
+	if lot.TimeZone == "" {
+		lot.TimeZone = DefaultTimeZone
+	}
+
+	_, appErr := LoadTimeZone(lot.TimeZone)
+	return appErr
+}
+
+// lotLocation returns the location of a stored time zone, UTC if it doesn't load anymore.
+func lotLocation(name string) *time.Location {
+	loc, appErr := LoadTimeZone(name)
+	if appErr != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// timeIn renders t in loc, t may be nil.
+func timeIn(t *time.Time, loc *time.Location) {
+	if t != nil {
+		*t = t.In(loc)
+	}
+}
+
+// localDay returns the bounds of the calendar day of date, by its year, month and day, in loc.
+func localDay(date time.Time, loc *time.Location) (time.Time, time.Time) {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	txMaxAttempts = 5
+	txBaseBackoff = 10 * time.Millisecond
+	txMaxBackoff  = 250 * time.Millisecond
+
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgUniqueViolation      = "23505"
+)
+
+// withSerializableTx runs fn within a serializable transaction, committing if fn returns no error and rolling back otherwise.
+// Serialization failures and deadlocks are expected under concurrent park/unpark, so the whole transaction is retried
+// up to txMaxAttempts times with jittered exponential backoff, logging every retry. src names the caller in logs.
+// fn may run more than once, it must not have side effects outside the transaction.
+func withSerializableTx(ctx context.Context, db *sql.DB, l *slog.Logger, src string, fn func(tx *sql.Tx) common.AppError) common.AppError {
+	var appErr common.AppError
+
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		appErr = runTx(ctx, db, l, src, fn)
+		if appErr == nil {
+			if attempt > 1 {
+				l.Info("transaction succeeded after retries", "src", src, "retries", attempt-1)
+			}
+
+			return nil
+		}
+
+		if !isRetryable(appErr) {
+			return appErr
+		}
+
+		if attempt == txMaxAttempts {
+			break
+		}
+
+		l.Warn("retrying transaction", "src", src, "attempt", attempt, "err", errors.Unwrap(appErr))
+
+		select {
+		case <-ctx.Done():
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, ctx.Err())
+		case <-time.After(txBackoff(attempt)):
+		}
+	}
+
+	l.Error("transaction retries exhausted", "src", src, "retries", txMaxAttempts-1, "err", errors.Unwrap(appErr))
+	return appErr
+}
+
+// runTx runs a single attempt of fn, handling begin, rollback and commit.
+func runTx(ctx context.Context, db *sql.DB, l *slog.Logger, src string, fn func(tx *sql.Tx) common.AppError) common.AppError {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		l.Error(common.ErrTXBegin, "err", err, "src", src)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			l.Error(common.ErrTXRollback, "err", rbErr, "src", src)
+		}
+	}()
+
+	if appErr := fn(tx); appErr != nil {
+		return appErr
+	}
+
+	if err = tx.Commit(); err != nil {
+		l.Error(common.ErrTxCommit, "err", err, "src", src)
+		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// isRetryable reports whether the error wrapped by appErr is a postgres serialization failure or deadlock.
+func isRetryable(appErr common.AppError) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(appErr, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+}
+
+// isUniqueViolation reports whether err is a postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// txBackoff returns a random delay up to an exponentially growing, capped bound (full jitter),
+// so transactions conflicting with each other don't retry in lockstep.
+func txBackoff(attempt int) time.Duration {
+	bound := txBaseBackoff << (attempt - 1)
+	if bound > txMaxBackoff {
+		bound = txMaxBackoff
+	}
+
+	return rand.N(bound) + time.Millisecond //nolint:gosec // jitter doesn't need a secure source
+}
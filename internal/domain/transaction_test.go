@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TestIsRetryable tests only serialization failures and deadlocks wrapped by an AppError are retried.
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		appErr   common.AppError
+		expected bool
+	}{
+		{"serialization failure", common.NewInternalServerError(common.ErrUnexpectedDatabase, &pgconn.PgError{Code: "40001"}), true},
+		{"deadlock", common.NewInternalServerError(common.ErrUnexpectedDatabase, fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40P01"})), true},
+		{"unique violation", common.NewInternalServerError(common.ErrUnexpectedDatabase, &pgconn.PgError{Code: "23505"}), false},
+		{"other error", common.NewInternalServerError(common.ErrUnexpectedDatabase, errors.New("connection reset")), false},
+		{"conflict", common.NewConflictError("parking lot is full"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isRetryable(tt.appErr); result != tt.expected {
+				t.Errorf("isRetryable() returned %t; expected %t", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTxBackoff tests retry delays stay positive and capped.
+func TestTxBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		if d := txBackoff(attempt); d <= 0 || d > txMaxBackoff+txBaseBackoff {
+			t.Errorf("txBackoff(%d) returned %s; expected within (0, %s]", attempt, d, txMaxBackoff+txBaseBackoff)
+		}
+	}
+}
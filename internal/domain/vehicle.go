@@ -3,14 +3,144 @@ package domain
 import (
 	"time"
 
+	"github.com/ashtishad/gopark/internal/common"
 	"github.com/google/uuid"
 )
 
 type Vehicle struct {
-	ID                 uuid.UUID  `json:"id"`
-	RegistrationNumber string     `json:"registrationNumber"`
-	SlotID             uuid.UUID  `json:"slotId"`
-	ParkedAt           time.Time  `json:"parkedAt"` // park time would be always recorded
-	UnparkedAt         *time.Time `json:"unparkedAt,omitempty"`
-	Fee                int        `json:"fee,omitempty"`
+	ID                    uuid.UUID   `json:"id"`
+	RegistrationNumber    string      `json:"registrationNumber"`              // canonical, see plate.Canonicalize
+	RawRegistrationNumber string      `json:"rawRegistrationNumber,omitempty"` // as entered on park, eg: "abc-123" for ABC123
+	VehicleType           VehicleType `json:"vehicleType"`
+	SlotID                uuid.UUID   `json:"slotId"`
+	SlotLabel             string      `json:"slotLabel,omitempty"`
+	ParkedAt              time.Time   `json:"parkedAt"` // park time would be always recorded
+	UnparkedAt            *time.Time  `json:"unparkedAt,omitempty"`
+	Fee                   int         `json:"fee,omitempty"`
+	Currency              string      `json:"currency,omitempty"`
+	PricingPolicyVersion  int         `json:"pricingPolicyVersion,omitempty"`
+	PermitID              *uuid.UUID  `json:"permitId,omitempty"` // the permit the vehicle parked under, its stay is free while it's valid
+
+	// AllocationStrategy is the strategy of the parking lot that chose the slot, ReservationID the reservation
+	// the park fulfilled. Both are set on park only, the strategy is empty when the reserved slot was assigned.
+	AllocationStrategy AllocationStrategy `json:"allocationStrategy,omitempty"`
+	ReservationID      *uuid.UUID         `json:"reservationId,omitempty"`
+}
+
+// PlatePolicy decides whether a registration number may be parked in several parking lots at the same time.
+// A plate is never parked twice in the same parking lot.
+type PlatePolicy string
+
+const (
+	PlatePolicySingleLot PlatePolicy = "single-lot" // default, a plate is parked in one parking lot at a time
+	PlatePolicyMultiLot  PlatePolicy = "multi-lot"  // a plate may be parked in every parking lot at once, eg: fleet plates
+)
+
+// Valid reports whether p is a known plate policy.
+func (p PlatePolicy) Valid() bool {
+	return p == PlatePolicySingleLot || p == PlatePolicyMultiLot
+}
+
+// ParkOptions describes the vehicle to park and where the driver would like to park it.
+// Slots of the preferred level and zone are chosen first when one fits the vehicle, an empty preference matches any,
+// eg: PreferredZone "C" without a level prefers zone C of every level. Remaining slots are ranked by level entrance distance.
+type ParkOptions struct {
+	VehicleType    VehicleType
+	PreferredLevel string
+	PreferredZone  string
+}
+
+// preferenceRank ranks a slot's level and zone against the preference, lower is better.
+func (o ParkOptions) preferenceRank(level, zone string) int {
+	switch {
+	case (o.PreferredLevel == "" || o.PreferredLevel == level) && (o.PreferredZone == "" || o.PreferredZone == zone):
+		return 0
+	case o.PreferredLevel != "" && o.PreferredLevel == level:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// ParkedVehicle is where a vehicle is parked right now, with the fee UnparkVehicle would charge at EstimatedAt.
+// TimeZone is the parking lot's, its night and weekend rates apply at local hours.
+type ParkedVehicle struct {
+	ID                   uuid.UUID   `json:"id"`
+	RegistrationNumber   string      `json:"registrationNumber"`
+	VehicleType          VehicleType `json:"vehicleType"`
+	ParkingLotID         uuid.UUID   `json:"parkingLotId"`
+	ParkingLotName       string      `json:"parkingLotName"`
+	TimeZone             string      `json:"timeZone"`
+	SlotID               uuid.UUID   `json:"slotId"`
+	SlotNumber           int         `json:"slotNumber"`
+	SlotLabel            string      `json:"slotLabel"`
+	Level                string      `json:"level,omitempty"`
+	Zone                 string      `json:"zone,omitempty"`
+	ParkedAt             time.Time   `json:"parkedAt"`
+	EstimatedAt          time.Time   `json:"estimatedAt"`
+	EstimatedFee         int         `json:"estimatedFee"`
+	Currency             string      `json:"currency"`
+	PricingPolicyVersion int         `json:"pricingPolicyVersion"`
+	PermitID             *uuid.UUID  `json:"permitId,omitempty"`
+	PermitEndsAt         *time.Time  `json:"permitEndsAt,omitempty"` // when the permit expires or was revoked, the stay is charged after
+}
+
+// FeeQuote is the itemized fee UnparkVehicle would charge a parked vehicle at At, computed without unparking it.
+type FeeQuote struct {
+	ParkingLotID       uuid.UUID   `json:"parkingLotId"`
+	RegistrationNumber string      `json:"registrationNumber"`
+	VehicleType        VehicleType `json:"vehicleType"`
+	SlotID             uuid.UUID   `json:"slotId"`
+	SlotLabel          string      `json:"slotLabel"`
+	ParkedAt           time.Time   `json:"parkedAt"`
+	At                 time.Time   `json:"at"`
+	FeeBreakdown
+	Currency             string     `json:"currency"`
+	PricingPolicyVersion int        `json:"pricingPolicyVersion"`
+	PermitID             *uuid.UUID `json:"permitId,omitempty"`
+	PermitEndsAt         *time.Time `json:"permitEndsAt,omitempty"`
+}
+
+// stayFee is the fee of a stay from parkedAt until under the policy, stays that started under a permit are free until permitEnd.
+func stayFee(policy *PricingPolicy, vType VehicleType, parkedAt, until time.Time, permitEnd *time.Time, timeZone string) int {
+	return quoteStay(policy, vType, parkedAt, until, permitEnd, timeZone).Total
+}
+
+// quoteStay itemizes the fee of a stay in a parking lot of the time zone, its hours are priced at the lot's local time,
+// so night and weekend rates follow the same days as its reports whatever zone the timestamps were stored in.
+func quoteStay(policy *PricingPolicy, vType VehicleType, parkedAt, until time.Time, permitEnd *time.Time, timeZone string) FeeBreakdown {
+	loc := lotLocation(timeZone)
+	return policy.QuoteFee(vType, parkedAt.In(loc), until.In(loc), permitEnd)
+}
+
+// notParkedError is the 404 Not Found error of a vehicle that isn't parked where it was looked up,
+// parkedElsewhere tells it's parked in another parking lot.
+func notParkedError(parkedElsewhere bool) common.AppError {
+	if parkedElsewhere {
+		return common.NewNotFoundError("vehicle is not parked in this parking lot")
+	}
+
+	return common.NewNotFoundError("vehicle is not parked")
+}
+
+// newFeeQuote quotes the stay of a parked vehicle at at, 400 Bad Request if at is before it parked.
+func newFeeQuote(pv *ParkedVehicle, policy *PricingPolicy, at time.Time) (*FeeQuote, common.AppError) {
+	if at.Before(pv.ParkedAt) {
+		return nil, common.NewBadRequestError("quote time can't be before the vehicle parked")
+	}
+
+	return &FeeQuote{
+		ParkingLotID:         pv.ParkingLotID,
+		RegistrationNumber:   pv.RegistrationNumber,
+		VehicleType:          pv.VehicleType,
+		SlotID:               pv.SlotID,
+		SlotLabel:            pv.SlotLabel,
+		ParkedAt:             pv.ParkedAt,
+		At:                   at,
+		FeeBreakdown:         quoteStay(policy, pv.VehicleType, pv.ParkedAt, at, pv.PermitEndsAt, pv.TimeZone),
+		Currency:             policy.Currency,
+		PricingPolicyVersion: policy.Version,
+		PermitID:             pv.PermitID,
+		PermitEndsAt:         pv.PermitEndsAt,
+	}, nil
 }
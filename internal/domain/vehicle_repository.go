@@ -4,209 +4,518 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
-	"math"
+	"strings"
 	"time"
 
 	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
 	"github.com/google/uuid"
 )
 
-// VehicleRepository defines the interface for interacting with vehicle data(park, unpark) in the postgresql database.
+// VehicleRepository defines the interface for interacting with vehicle data(park, unpark, find parked vehicle),
+// implemented for the postgresql database by VehicleRepositoryDB and in memory by VehicleRepositoryMemory.
+// Registration numbers are looked up by their canonical form, see plate.Canonicalize, eg: "abc 123" finds ABC-123.
 type VehicleRepository interface {
-	ParkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string) (*Vehicle, common.AppError)
-	UnparkVehicle(ctx context.Context, regNum string) (*Vehicle, common.AppError)
+	ParkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string, opts ParkOptions) (*Vehicle, common.AppError)
+	UnparkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string) (*Vehicle, common.AppError)
+	FindParkedVehicle(ctx context.Context, regNum string) (*ParkedVehicle, common.AppError)
+	QuoteFee(ctx context.Context, plUUID uuid.UUID, regNum string, at time.Time) (*FeeQuote, common.AppError)
 }
 
+var _ VehicleRepository = (*VehicleRepositoryDB)(nil)
+
+// slotRankOrderBy ranks slots s on level lv by type fit against the compatible slot types $2, then by the preferred
+// level $3 and zone $4, mirroring slotFit and ParkOptions.preferenceRank.
+const slotRankOrderBy = `array_position($2::text[], s.slot_type::text),
+                CASE
+                    WHEN ($3::text = '' OR lv.name = $3::text) AND ($4::text = '' OR s.zone = $4::text) THEN 0
+                    WHEN $3::text <> '' AND lv.name = $3::text THEN 1
+                    ELSE 2
+                END`
+
 type VehicleRepositoryDB struct {
-	db *sql.DB
-	l  *slog.Logger
+	db          *sql.DB
+	l           *slog.Logger
+	now         func() time.Time
+	platePolicy PlatePolicy
 }
 
 func NewVehicleRepoDB(db *sql.DB, l *slog.Logger) *VehicleRepositoryDB {
 	return &VehicleRepositoryDB{
-		db: db,
-		l:  l,
+		db:          db,
+		l:           l,
+		now:         time.Now,
+		platePolicy: PlatePolicySingleLot,
+	}
+}
+
+// WithClock replaces the clock used for park and unpark timestamps, lets tests control time.
+func (v *VehicleRepositoryDB) WithClock(now func() time.Time) *VehicleRepositoryDB {
+	v.now = now
+	return v
+}
+
+// WithPlatePolicy replaces the PlatePolicySingleLot default, deciding whether a plate may be parked in several parking lots at once.
+func (v *VehicleRepositoryDB) WithPlatePolicy(policy PlatePolicy) *VehicleRepositoryDB {
+	v.platePolicy = policy
+	return v
+}
+
+// plateScope is the plate_scope of a vehicle parked in the parking lot, the partial unique index on active vehicles
+// (registration_number, plate_scope) allows a plate once per scope: everywhere under PlatePolicySingleLot, per parking lot
+// under PlatePolicyMultiLot.
+func (v *VehicleRepositoryDB) plateScope(plID int) int {
+	if v.platePolicy == PlatePolicyMultiLot {
+		return plID
 	}
+
+	return 0
 }
 
 // ParkVehicle performs the following within a serializable transaction to guarantee atomicity:
-// 1. Locates the nearest available slot in the specified parking lot (using slot numbers) and locks the slot to prevent concurrent updates.
-// 2. Parking slots are numbered 1,2,3....n, then we still start from 1 and pick the available one, then Mark this slot as unavailable in the database.
-// 3. Creates a new vehicle record associated with the slot and the current UTC timestamp.
-// 4. Returns a 409 Conflict error if the parking lot is full.
-// 5. Returns a 500 Internal Server Error if unexpected database errors occur during the process.
-func (v *VehicleRepositoryDB) ParkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string) (*Vehicle, common.AppError) {
+// 0. Returns a 409 Conflict error if the vehicle is already parked in the parking lot, or in any parking lot under PlatePolicySingleLot.
+// 1. Assigns the reserved slot if a reservation of the vehicle type holds one for the vehicle in this parking lot and it's free.
+// Otherwise assigns the slot of the vehicle's valid permit if it has one and it's free, the stay is free of charge while the permit is valid.
+// 2. Otherwise locates the best fitting available slot for the vehicle type in the specified parking lot, honouring the preferred level
+// and zone and skipping slots kept by reservations or assigned to valid permits, and locks the slot to prevent concurrent updates.
+// A held reservation is fulfilled by the park, moving to the slot the vehicle got if its own was taken.
+// 3. Among equally fitting slots, picks one with the parking lot's SlotAllocator, then Mark this slot as unavailable in the database.
+// 4. Creates a new vehicle record associated with the slot and the current UTC timestamp, storing the registration number
+// as entered alongside its canonical form.
+// 5. Returns a 400 Bad Request error if the registration number has no letter or digit, 409 Conflict error if the parking lot has no available slot the vehicle fits in.
+// 6. Returns a 500 Internal Server Error if unexpected database errors occur during the process.
+func (v *VehicleRepositoryDB) ParkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string, opts ParkOptions) (*Vehicle, common.AppError) {
+	rawRegNum, regNum := strings.TrimSpace(regNum), plate.Canonicalize(regNum)
+	if regNum == "" {
+		return nil, common.NewBadRequestError(plate.ErrEmpty.Error())
+	}
+
 	plID, apiErr := getIDByUUID(ctx, v.db, v.l, tableParkingLots, plUUID)
 	if apiErr != nil {
 		return nil, apiErr
 	}
 
-	tx, err := v.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
-	if err != nil {
-		v.l.Error(common.ErrTXBegin, "err", err, "src", "ParkVehicle")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
-	}
+	var newVehicle Vehicle
+	appErr := withSerializableTx(ctx, v.db, v.l, "ParkVehicle", func(tx *sql.Tx) common.AppError {
+		if appErr := v.isVehicleAlreadyParked(ctx, tx, plID, regNum); appErr != nil {
+			return appErr
+		}
+
+		now := v.now().UTC()
+		newVehicle = Vehicle{
+			ID:                    uuid.New(),
+			RegistrationNumber:    regNum,
+			RawRegistrationNumber: rawRegNum,
+			VehicleType:           opts.VehicleType,
+			ParkedAt:              now,
+		}
 
-	defer func() {
+		held, appErr := v.findHeldReservation(ctx, tx, plID, regNum, opts.VehicleType, now)
+		if appErr != nil {
+			return appErr
+		}
+
+		permit, appErr := v.findValidPermit(ctx, tx, plID, regNum, now)
+		if appErr != nil {
+			return appErr
+		}
+
+		var slotID int
+		var slot Slot
+		switch {
+		case held != nil && held.slotFree:
+			slotID, slot = held.slotID, held.slot
+			v.l.Info("Assigned reserved slot", "slot number", slot.SlotNumber, "slot", slot.Label, "vehicle", regNum, "reservation", held.uuid)
+		case permit != nil && permit.slotFree:
+			slotID, slot = permit.slotID, permit.slot
+			v.l.Info("Assigned permit slot", "slot number", slot.SlotNumber, "slot", slot.Label, "vehicle", regNum, "permit", permit.uuid)
+		default:
+			allocator, appErr := v.getSlotAllocator(ctx, tx, plID)
+			if appErr != nil {
+				return appErr
+			}
+
+			if slotID, slot, appErr = v.findAvailableSlot(ctx, tx, plID, regNum, opts, allocator, now); appErr != nil {
+				return appErr
+			}
+
+			if allocator.Strategy() == AllocationRoundRobin {
+				if _, err := tx.ExecContext(ctx, "UPDATE parking_lots SET last_allocated_slot = $1 WHERE id = $2", slot.SlotNumber, plID); err != nil {
+					v.l.Error("error updating last allocated slot", "err", err)
+					return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+				}
+			}
+
+			newVehicle.AllocationStrategy = allocator.Strategy()
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE slots SET is_available = false WHERE id = $1", slotID)
 		if err != nil {
-			txErr := tx.Rollback()
-			if txErr != nil {
-				v.l.Error(common.ErrTXRollback, "err", txErr, "src", "ParkVehicle")
+			v.l.Error("error updating slot availability status", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		newVehicle.SlotID, newVehicle.SlotLabel = slot.ID, slot.Label
+
+		var permitID sql.NullInt64
+		if permit != nil {
+			permitID = sql.NullInt64{Int64: int64(permit.id), Valid: true}
+			newVehicle.PermitID = &permit.uuid
+		}
+
+		var vehicleID int
+		vehicleInsertQuery := `
+            INSERT INTO vehicles (uuid, registration_number, registration_number_raw, vehicle_type, slot_id, parked_at, permit_id, plate_scope)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING id`
+		err = tx.QueryRowContext(ctx, vehicleInsertQuery, newVehicle.ID, newVehicle.RegistrationNumber, newVehicle.RawRegistrationNumber,
+			newVehicle.VehicleType, slotID, newVehicle.ParkedAt, permitID, v.plateScope(plID)).Scan(&vehicleID)
+		if isUniqueViolation(err) {
+			return common.NewConflictError(common.ErrVehicleParked)
+		} else if err != nil {
+			v.l.Error("error creating vehicle record", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		if held != nil {
+			// the reserved slot is taken, the reservation moves to the slot the vehicle got.
+			if slotID != held.slotID {
+				v.l.Info("Moved reservation", "reservation", held.uuid, "from", held.slot.Label, "to", slot.Label)
 			}
+
+			if _, err = tx.ExecContext(ctx, "UPDATE reservations SET status = $1, vehicle_id = $2, slot_id = $3 WHERE id = $4",
+				ReservationFulfilled, vehicleID, slotID, held.id); err != nil {
+				v.l.Error("error fulfilling reservation", "err", err)
+				return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+			}
+
+			newVehicle.ReservationID = &held.uuid
 		}
-	}()
 
-	if appErr := v.isVehicleAlreadyParked(ctx, tx, regNum); appErr != nil {
+		return nil
+	})
+	if appErr != nil {
 		return nil, appErr
 	}
 
-	slotID, slotUUID, appErr := v.findNearestAvailableSlot(ctx, tx, plID, regNum)
-	if err != nil {
-		return nil, appErr
-	}
+	return &newVehicle, nil
+}
 
-	_, err = tx.ExecContext(ctx, "UPDATE slots SET is_available = false WHERE id = $1", slotID)
-	if err != nil {
-		v.l.Error("error updating slot availability status", "err", err)
+// heldReservation is a reservation holding a slot for a vehicle about to park.
+type heldReservation struct {
+	id       int
+	uuid     uuid.UUID
+	slotID   int
+	slot     Slot
+	slotFree bool // available and not under maintenance
+}
+
+// findHeldReservation locks the earliest reservation holding a slot of the parking lot for the vehicle at now, and its slot.
+// Returns nil if there is none, reservations made for another vehicle type are ignored.
+func (v *VehicleRepositoryDB) findHeldReservation(ctx context.Context, tx *sql.Tx, plID int, regNum string, vType VehicleType,
+	now time.Time) (*heldReservation, common.AppError) {
+	var held heldReservation
+	err := tx.QueryRowContext(ctx, `
+        SELECT r.id, r.uuid, s.id, s.uuid, s.slot_number, s.label, COALESCE(lv.name, ''), s.zone, s.slot_type,
+               s.is_available AND NOT s.is_maintenance
+        FROM reservations r
+        JOIN slots s ON r.slot_id = s.id
+        LEFT JOIN parking_levels lv ON s.level_id = lv.id
+        WHERE r.parking_lot_id = $1 AND r.registration_number = $2 AND r.vehicle_type = $6 AND r.status = $3
+          AND r.starts_at <= $4 AND r.hold_until >= $5
+        ORDER BY r.starts_at
+        LIMIT 1
+        FOR UPDATE OF r, s`, plID, regNum, ReservationActive, now.Add(ReservationLeadTime), now, vType).Scan(
+		&held.id, &held.uuid, &held.slotID, &held.slot.ID, &held.slot.SlotNumber, &held.slot.Label, &held.slot.Level, &held.slot.Zone,
+		&held.slot.Type, &held.slotFree)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		v.l.Error("error finding held reservation", "err", err)
 		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	default:
+		return &held, nil
 	}
+}
 
-	newVehicle := Vehicle{
-		ID:                 uuid.New(),
-		RegistrationNumber: regNum,
-		SlotID:             slotUUID,
-		ParkedAt:           time.Now().UTC(),
-	}
+// validPermit is a permit of the parking lot valid for a vehicle about to park.
+type validPermit struct {
+	id       int
+	uuid     uuid.UUID
+	slotID   int // 0 for floating permits
+	slot     Slot
+	slotFree bool // assigned, available and not under maintenance
+}
 
-	vehicleInsertQuery := `INSERT INTO vehicles (uuid, registration_number, slot_id, parked_at) VALUES ($1, $2, $3, $4)`
-	if _, err = tx.ExecContext(ctx, vehicleInsertQuery, newVehicle.ID, newVehicle.RegistrationNumber, slotID, newVehicle.ParkedAt); err != nil {
-		v.l.Error("error creating vehicle record", "err", err)
+// findValidPermit locks the permit of the parking lot covering the vehicle at now, and its assigned slot if it has one.
+// Returns nil if there is none.
+func (v *VehicleRepositoryDB) findValidPermit(ctx context.Context, tx *sql.Tx, plID int, regNum string, now time.Time) (*validPermit, common.AppError) {
+	var permit validPermit
+	var slotID sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+        SELECT p.id, p.uuid, p.slot_id
+        FROM permits p
+        JOIN permit_plates pp ON pp.permit_id = p.id
+        WHERE p.parking_lot_id = $1 AND pp.registration_number = $2 AND p.revoked_at IS NULL
+          AND p.valid_from <= $3 AND p.valid_until > $3
+        ORDER BY p.valid_from
+        LIMIT 1
+        FOR UPDATE OF p`, plID, regNum, now).Scan(&permit.id, &permit.uuid, &slotID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		v.l.Error("error finding valid permit", "err", err)
 		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	case !slotID.Valid:
+		return &permit, nil
 	}
 
-	if cmtErr := tx.Commit(); err != nil {
-		v.l.Error(common.ErrTxCommit, "err", cmtErr, "src", "ParkVehicle")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, cmtErr)
+	permit.slotID = int(slotID.Int64)
+	err = tx.QueryRowContext(ctx, `
+        SELECT s.uuid, s.slot_number, s.label, COALESCE(lv.name, ''), s.zone, s.slot_type, s.is_available AND NOT s.is_maintenance
+        FROM slots s
+        LEFT JOIN parking_levels lv ON s.level_id = lv.id
+        WHERE s.id = $1
+        FOR UPDATE OF s`, permit.slotID).Scan(
+		&permit.slot.ID, &permit.slot.SlotNumber, &permit.slot.Label, &permit.slot.Level, &permit.slot.Zone, &permit.slot.Type, &permit.slotFree)
+	if err != nil {
+		v.l.Error("error fetching permit slot", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
 	}
 
-	return &newVehicle, nil
+	return &permit, nil
 }
 
-// findNearestAvailableSlot performs the following steps to locate the nearest vacant slot while preserving data integrity:
-// 1. Existence Check for Vehicle with the Same Registration Number (potential optimization: add an index on registration_number column)
-// 2. Retrieves the slotID (int) for efficient querying to availability status update  and slotUUID for client response.
-// 3. Executes a query with 'FOR UPDATE'  to lock the nearest available slot, ensuring that concurrent transactions cannot claim the same slot.
-// 4. 409 Conflict error if the parking lot is full, 500 Internal Server Error if unexpected database errors occur during the process.
-func (v *VehicleRepositoryDB) findNearestAvailableSlot(ctx context.Context, tx *sql.Tx, plID int, regNum string) (int, uuid.UUID, common.AppError) {
-	var exists bool
-	err := tx.QueryRowContext(ctx, `
-       SELECT EXISTS(SELECT 1 FROM vehicles WHERE registration_number = $1 AND unparked_at IS NULL)
-    `, regNum).Scan(&exists)
-
-	if err != nil {
-		v.l.Error("error checking vehicle existence in the slot", "err", err)
-		return 0, uuid.Nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
-	} else if exists {
-		return 0, uuid.Nil, common.NewConflictError("vehicle with this registration number is already parked")
+// getSlotAllocator returns the slot allocator of the parking lot's allocation strategy.
+func (v *VehicleRepositoryDB) getSlotAllocator(ctx context.Context, tx *sql.Tx, plID int) (SlotAllocator, common.AppError) {
+	var strategy AllocationStrategy
+	if err := tx.QueryRowContext(ctx, "SELECT allocation_strategy FROM parking_lots WHERE id = $1", plID).Scan(&strategy); err != nil {
+		v.l.Error("error fetching allocation strategy", "err", err)
+		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
 	}
 
-	var slotID, slotNum int
-	var slotUUID uuid.UUID
+	return NewSlotAllocator(strategy), nil
+}
 
-	err = tx.QueryRowContext(ctx, `
-       SELECT id, uuid, slot_number FROM slots 
-       WHERE parking_lot_id = $1 AND is_available = true AND is_maintenance= false
-       ORDER BY slot_number
+// findAvailableSlot performs the following steps to locate a vacant slot while preserving data integrity:
+// 1. Retrieves the slotID (int) for efficient querying to availability status update  and the slot (uuid, label) for client response.
+// 2. Executes a query with 'FOR UPDATE'  to lock the chosen available slot, ensuring that concurrent transactions cannot claim the same slot.
+// Slots are ranked by how well their type fits the vehicle type (see VehicleType.CompatibleSlotTypes), then by the preferred
+// level and zone, and finally by the ordering of the allocator. Slots of active reservations starting within WalkInStay,
+// so the vehicle is expected to leave before they begin, and slots assigned to a permit valid at now are skipped.
+// 3. 409 Conflict error if the parking lot is full, 500 Internal Server Error if unexpected database errors occur during the process.
+func (v *VehicleRepositoryDB) findAvailableSlot(ctx context.Context, tx *sql.Tx, plID int, regNum string, opts ParkOptions,
+	allocator SlotAllocator, now time.Time) (int, Slot, common.AppError) {
+	var slotID int
+	var slot Slot
+
+	// the allocator's ordering is a constant of this package.
+	query := fmt.Sprintf(`
+       SELECT s.id, s.uuid, s.slot_number, s.label, COALESCE(lv.name, ''), s.zone, s.slot_type
+       FROM slots s
+       JOIN parking_lots pl ON s.parking_lot_id = pl.id
+       LEFT JOIN parking_levels lv ON s.level_id = lv.id
+       WHERE s.parking_lot_id = $1 AND s.is_available = true AND s.is_maintenance= false AND s.slot_type = ANY($2::text[])
+         AND NOT EXISTS (SELECT 1 FROM reservations r
+                         WHERE r.slot_id = s.id AND r.status = $5 AND r.starts_at <= $6 AND r.hold_until >= $7)
+         AND NOT EXISTS (SELECT 1 FROM permits p
+                         WHERE p.slot_id = s.id AND p.revoked_at IS NULL AND p.valid_from <= $7 AND p.valid_until > $7)
+       ORDER BY %s,
+                %s
        LIMIT 1 
-       FOR UPDATE`, plID).Scan(&slotID, &slotUUID, &slotNum)
+       FOR UPDATE OF s`, slotRankOrderBy, allocator.orderBy()) //nolint:gosec // the orderings never contain user input
+
+	err := tx.QueryRowContext(ctx, query, plID, opts.VehicleType.compatibleSlotTypeNames(), opts.PreferredLevel, opts.PreferredZone,
+		ReservationActive, now.Add(WalkInStay), now).Scan(
+		&slotID, &slot.ID, &slot.SlotNumber, &slot.Label, &slot.Level, &slot.Zone, &slot.Type)
 
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		v.l.Error("parking lot is full", "parking_lot_id", plID)
-		return 0, uuid.Nil, common.NewConflictError("parking lot is full")
+		return 0, Slot{}, common.NewConflictError(common.ErrParkingLotFull)
 	case err != nil:
 		v.l.Error("error finding available slot", "err", err)
-		return 0, uuid.Nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		return 0, Slot{}, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
 	default:
-		v.l.Info("Chosen nearest slot available", "slot number", slotNum, "vehicle", regNum)
-		return slotID, slotUUID, nil
+		v.l.Info("Chosen slot available", "strategy", allocator.Strategy(), "slot number", slot.SlotNumber, "slot", slot.Label,
+			"slot type", slot.Type, "vehicle", regNum, "vehicle type", opts.VehicleType)
+		return slotID, slot, nil
 	}
 }
 
 // UnparkVehicle performs the following within a serializable transaction to guarantee atomicity, and prevent race conditions:
-// 1. Finds the parked vehicle using the registration number, ensuring it hasn't already been unparked.
-// 2. Calculates the parking fee based on the vehicle's parking duration.
-// 3. Updates the vehicle record with the unparking timestamp and calculated fee.
-// 4. Marks the corresponding slot as available.
-// 5. Returns a Conflict error if the vehicle isn't found or has already been unparked.
+// 1. Finds the vehicle parked in the parking lot using the registration number, ensuring it hasn't already been unparked.
+// 2. Calculates the parking fee with the parking lot's pricing policy, based on the vehicle's parking duration and type.
+// Stays that started under a valid permit are free until the permit expired or was revoked, the rest of the stay is charged.
+// 3. Updates the vehicle record with the unparking timestamp, calculated fee, its currency and the pricing policy version,
+// so later pricing changes never alter historical fees.
+// 4. Marks the corresponding slot as available, recording when it was released for least-recently-used allocation.
+// 5. Returns a Not Found error for unknown parking lots, a Conflict error if the vehicle is parked in another parking lot,
+// isn't found or has already been unparked.
 // 6. Returns an Internal Server Error if any unexpected database errors occur.
-func (v *VehicleRepositoryDB) UnparkVehicle(ctx context.Context, regNum string) (*Vehicle, common.AppError) {
-	tx, err := v.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
-	if err != nil {
-		v.l.Error(common.ErrTXBegin, "err", err, "src", "UnparkVehicle")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+func (v *VehicleRepositoryDB) UnparkVehicle(ctx context.Context, plUUID uuid.UUID, regNum string) (*Vehicle, common.AppError) {
+	regNum = plate.Canonicalize(regNum)
+	plID, appErr := getIDByUUID(ctx, v.db, v.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	defer func() {
-		if err != nil {
-			txErr := tx.Rollback()
-			if txErr != nil {
-				v.l.Error(common.ErrTXRollback, "err", txErr, "src", "UnparkVehicle")
+	var vehicle Vehicle
+	appErr = withSerializableTx(ctx, v.db, v.l, "UnparkVehicle", func(tx *sql.Tx) common.AppError {
+		vehicle = Vehicle{RegistrationNumber: regNum}
+
+		var slotID int
+		var permitEnd *time.Time
+		var timeZone string
+		err := tx.QueryRowContext(ctx, `
+            SELECT v.uuid, COALESCE(v.registration_number_raw, v.registration_number), v.vehicle_type, v.slot_id, s.label,
+                   v.parked_at, v.unparked_at, p.uuid, LEAST(p.valid_until, p.revoked_at), pl.time_zone
+            FROM vehicles v
+            JOIN slots s ON v.slot_id = s.id
+            JOIN parking_lots pl ON s.parking_lot_id = pl.id
+            LEFT JOIN permits p ON v.permit_id = p.id
+            WHERE v.registration_number = $1 AND v.unparked_at IS NULL AND s.parking_lot_id = $2
+            FOR UPDATE OF v`, regNum, plID).Scan(
+			&vehicle.ID, &vehicle.RawRegistrationNumber, &vehicle.VehicleType, &slotID, &vehicle.SlotLabel, &vehicle.ParkedAt,
+			&vehicle.UnparkedAt, &vehicle.PermitID, &permitEnd, &timeZone)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			parked, appErr := isVehicleParked(ctx, tx, v.l, regNum)
+			if appErr != nil {
+				return appErr
+			}
+
+			if parked {
+				v.l.Error(common.ErrVehicleParkedInOther, "registration_number", regNum, "parking_lot_id", plUUID)
+				return common.NewConflictError(common.ErrVehicleParkedInOther)
 			}
+
+			v.l.Error("vehicle not found or already unparked", "registration_number", regNum)
+			return common.NewConflictError("vehicle not found or already unparked")
+		} else if err != nil {
+			v.l.Error("error finding vehicle", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
 		}
-	}()
 
-	var vehicle Vehicle
-	var slotID int
-	err = tx.QueryRowContext(ctx, `
-        SELECT uuid, slot_id, parked_at, unparked_at
-        FROM vehicles 
-        WHERE registration_number = $1 AND unparked_at IS NULL 
-        FOR UPDATE`, regNum).Scan(
-		&vehicle.ID, &slotID, &vehicle.ParkedAt, &vehicle.UnparkedAt)
+		slotUUID, appErr := getSlotUUIDByID(ctx, tx, v.l, slotID)
+		if appErr != nil {
+			return appErr
+		}
+		vehicle.SlotID = slotUUID
 
-	if errors.Is(err, sql.ErrNoRows) {
-		v.l.Error("vehicle not found or already unparked", "registration_number", regNum)
-		return nil, common.NewConflictError("vehicle not found or already unparked")
-	} else if err != nil {
-		v.l.Error("error finding vehicle", "err", err)
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		policy, appErr := getPricingPolicy(ctx, tx, v.l, plID, plUUID)
+		if appErr != nil {
+			return appErr
+		}
+
+		unparkedAt := v.now()
+		vehicle.Fee = stayFee(policy, vehicle.VehicleType, vehicle.ParkedAt, unparkedAt, permitEnd, timeZone)
+		vehicle.Currency = policy.Currency
+		vehicle.PricingPolicyVersion = policy.Version
+		vehicle.UnparkedAt = &unparkedAt
+
+		_, err = tx.ExecContext(ctx, `
+            UPDATE vehicles 
+            SET unparked_at = $1, fee = $2, currency = $3, pricing_policy_version = $4
+            WHERE uuid = $5`, unparkedAt, vehicle.Fee, vehicle.Currency, vehicle.PricingPolicyVersion, vehicle.ID)
+		if err != nil {
+			v.l.Error("error updating vehicle", "err", err)
+			return common.NewInternalServerError("error updating vehicle", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE slots SET is_available = true, last_released_at = $1 WHERE id = $2", unparkedAt, slotID); err != nil {
+			v.l.Error("error updating slot status", "err", err)
+			return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+		}
+
+		return nil
+	})
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	slotUUID, appErr := getSlotUUIDByID(ctx, tx, v.l, slotID)
+	return &vehicle, nil
+}
+
+// FindParkedVehicle locates the vehicle currently parked with the registration number, the most recently parked one when
+// PlatePolicyMultiLot lets it park in several parking lots, and estimates its fee as if it were unparked now with its parking
+// lot's current pricing policy. Returns a 404 Not Found error if the vehicle isn't parked.
+func (v *VehicleRepositoryDB) FindParkedVehicle(ctx context.Context, regNum string) (*ParkedVehicle, common.AppError) {
+	pv, policy, appErr := v.findParkedVehicle(ctx, 0, plate.Canonicalize(regNum))
 	if appErr != nil {
 		return nil, appErr
 	}
-	vehicle.SlotID = slotUUID
-	vehicle.RegistrationNumber = regNum
 
-	unparkedAt := time.Now()
-	parkingDuration := unparkedAt.Sub(vehicle.ParkedAt)
-	hours := int(math.Ceil(parkingDuration.Hours())) // Round up to the nearest hour
-	vehicle.Fee = hours * 10
-	vehicle.UnparkedAt = &unparkedAt
+	pv.EstimatedAt = v.now()
+	pv.EstimatedFee = stayFee(policy, pv.VehicleType, pv.ParkedAt, pv.EstimatedAt, pv.PermitEndsAt, pv.TimeZone)
+	return pv, nil
+}
 
-	_, err = tx.ExecContext(ctx, `
-        UPDATE vehicles 
-        SET unparked_at = $1
-        WHERE uuid = $2`, unparkedAt, vehicle.ID)
-	if err != nil {
-		v.l.Error("error updating vehicle", "err", err)
-		return nil, common.NewInternalServerError("error updating vehicle", err)
+// QuoteFee itemizes the fee UnparkVehicle would charge the vehicle at, now if at is zero, without unparking it.
+// Both price the stay with the parking lot's current pricing policy through PricingPolicy.QuoteFee, so the quote matches the charge.
+// Returns a 404 Not Found error if the vehicle isn't parked in the parking lot, 400 Bad Request if at is before it parked.
+func (v *VehicleRepositoryDB) QuoteFee(ctx context.Context, plUUID uuid.UUID, regNum string, at time.Time) (*FeeQuote, common.AppError) {
+	plID, appErr := getIDByUUID(ctx, v.db, v.l, tableParkingLots, plUUID)
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	if _, err = tx.ExecContext(ctx, "UPDATE slots SET is_available = true WHERE id = $1", slotID); err != nil {
-		v.l.Error("error updating slot status", "err", err)
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	pv, policy, appErr := v.findParkedVehicle(ctx, plID, plate.Canonicalize(regNum))
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	if cmtErr := tx.Commit(); cmtErr != nil {
-		v.l.Error(common.ErrTxCommit, "err", cmtErr, "src", "UnparkVehicle")
-		return nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, cmtErr)
+	if at.IsZero() {
+		at = v.now()
 	}
 
-	return &vehicle, nil
+	return newFeeQuote(pv, policy, at)
+}
+
+// findParkedVehicle locates the vehicle parked with the canonical registration number in the parking lot plID, the most recently parked
+// in any parking lot if plID is 0, and its parking lot's pricing policy.
+func (v *VehicleRepositoryDB) findParkedVehicle(ctx context.Context, plID int, regNum string) (*ParkedVehicle, *PricingPolicy, common.AppError) {
+	pv := ParkedVehicle{RegistrationNumber: regNum}
+
+	err := v.db.QueryRowContext(ctx, `
+        SELECT v.uuid, v.vehicle_type, pl.id, pl.uuid, pl.name, pl.time_zone, s.uuid, s.slot_number, s.label, COALESCE(lv.name, ''),
+               s.zone, v.parked_at, p.uuid, LEAST(p.valid_until, p.revoked_at)
+        FROM vehicles v
+        JOIN slots s ON v.slot_id = s.id
+        JOIN parking_lots pl ON s.parking_lot_id = pl.id
+        LEFT JOIN parking_levels lv ON s.level_id = lv.id
+        LEFT JOIN permits p ON v.permit_id = p.id
+        WHERE v.registration_number = $1 AND v.unparked_at IS NULL AND ($2::int = 0 OR pl.id = $2::int)
+        ORDER BY v.parked_at DESC, v.id DESC
+        LIMIT 1`, regNum, plID).Scan(
+		&pv.ID, &pv.VehicleType, &plID, &pv.ParkingLotID, &pv.ParkingLotName, &pv.TimeZone, &pv.SlotID, &pv.SlotNumber, &pv.SlotLabel,
+		&pv.Level, &pv.Zone, &pv.ParkedAt, &pv.PermitID, &pv.PermitEndsAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		parked, appErr := isVehicleParked(ctx, v.db, v.l, regNum)
+		if appErr != nil {
+			return nil, nil, appErr
+		}
+
+		return nil, nil, notParkedError(plID != 0 && parked)
+	} else if err != nil {
+		v.l.Error("error finding parked vehicle", "err", err)
+		return nil, nil, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	policy, appErr := getPricingPolicy(ctx, v.db, v.l, plID, pv.ParkingLotID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	pv.Currency, pv.PricingPolicyVersion = policy.Currency, policy.Version
+	return &pv, policy, nil
 }
 
 // getSlotUUIDByID retrieves the internal integer ID of a slot given its UUID.
@@ -223,22 +532,35 @@ func getSlotUUIDByID(ctx context.Context, tx *sql.Tx, l *slog.Logger, slotID int
 	return slotUUID, nil
 }
 
-func (v *VehicleRepositoryDB) isVehicleAlreadyParked(ctx context.Context, tx *sql.Tx, regNum string) common.AppError {
-	var existingVehicleID int
+// isVehicleAlreadyParked returns a 409 Conflict error if the vehicle is parked in the parking lot plID, or in any parking lot
+// under PlatePolicySingleLot.
+func (v *VehicleRepositoryDB) isVehicleAlreadyParked(ctx context.Context, tx *sql.Tx, plID int, regNum string) common.AppError {
+	var exists bool
 	err := tx.QueryRowContext(ctx, `
-        SELECT id FROM vehicles 
-        WHERE registration_number = $1 
-        AND unparked_at IS NULL
-    `, regNum).Scan(&existingVehicleID)
+        SELECT EXISTS(SELECT 1 FROM vehicles v
+                      JOIN slots s ON v.slot_id = s.id
+                      WHERE v.registration_number = $1 AND v.unparked_at IS NULL AND ($2::int = 0 OR s.parking_lot_id = $2::int))
+    `, regNum, v.plateScope(plID)).Scan(&exists)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil
-		}
-
 		v.l.Error("error checking existing vehicle parking status", "err", err)
 		return common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	} else if exists {
+		return common.NewConflictError(common.ErrVehicleParked)
 	}
 
 	return nil
 }
+
+// isVehicleParked reports whether a vehicle with the registration number is parked in any parking lot.
+func isVehicleParked(ctx context.Context, q queryer, l *slog.Logger, regNum string) (bool, common.AppError) {
+	var parked bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM vehicles WHERE registration_number = $1 AND unparked_at IS NULL)",
+		regNum).Scan(&parked)
+	if err != nil {
+		l.Error("error checking vehicle parking status", "err", err)
+		return false, common.NewInternalServerError(common.ErrUnexpectedDatabase, err)
+	}
+
+	return parked, nil
+}
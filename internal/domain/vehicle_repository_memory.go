@@ -0,0 +1,327 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+var _ VehicleRepository = (*VehicleRepositoryMemory)(nil)
+
+// VehicleRepositoryMemory implements VehicleRepository in memory, with the same semantics as VehicleRepositoryDB.
+type VehicleRepositoryMemory struct {
+	s           *MemoryStore
+	l           *slog.Logger
+	now         func() time.Time
+	platePolicy PlatePolicy
+}
+
+func NewVehicleRepoMemory(s *MemoryStore, l *slog.Logger) *VehicleRepositoryMemory {
+	return &VehicleRepositoryMemory{
+		s:           s,
+		l:           l,
+		now:         time.Now,
+		platePolicy: PlatePolicySingleLot,
+	}
+}
+
+// WithClock replaces the clock used for park and unpark timestamps, lets tests control time.
+func (v *VehicleRepositoryMemory) WithClock(now func() time.Time) *VehicleRepositoryMemory {
+	v.now = now
+	return v
+}
+
+// WithPlatePolicy replaces the PlatePolicySingleLot default, deciding whether a plate may be parked in several parking lots at once.
+func (v *VehicleRepositoryMemory) WithPlatePolicy(policy PlatePolicy) *VehicleRepositoryMemory {
+	v.platePolicy = policy
+	return v
+}
+
+// ParkVehicle parks the vehicle in the slot of the reservation holding one for it and its type, or else of its valid permit,
+// if that slot is free, otherwise in the best fitting available slot for its type that isn't under maintenance, kept by
+// a reservation or assigned to a valid permit, ranked like
+// VehicleRepositoryDB: by type fit, preferred level and zone, then by the parking lot's SlotAllocator.
+// A held reservation is fulfilled by the park, moving to the slot the vehicle got if its own was taken.
+// Returns a 400 Bad Request error for registration numbers without a letter or digit, 404 Not Found for unknown parking lots,
+// 409 Conflict if the lot is full or the vehicle is already parked
+// in this parking lot, or in any parking lot under PlatePolicySingleLot.
+func (v *VehicleRepositoryMemory) ParkVehicle(_ context.Context, plUUID uuid.UUID, regNum string, opts ParkOptions) (*Vehicle, common.AppError) {
+	rawRegNum, regNum := strings.TrimSpace(regNum), plate.Canonicalize(regNum)
+	if regNum == "" {
+		return nil, common.NewBadRequestError(plate.ErrEmpty.Error())
+	}
+
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+
+	lot, ok := v.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	if v.s.parkedVehicleIn(plUUID, regNum) != nil || (v.platePolicy == PlatePolicySingleLot && v.s.parkedVehicle(regNum) != nil) {
+		return nil, common.NewConflictError(common.ErrVehicleParked)
+	}
+
+	now := v.now().UTC()
+	var slot *memSlot
+	var strategy AllocationStrategy
+
+	reservation := v.s.heldReservation(plUUID, regNum, opts.VehicleType, now)
+	permit := v.s.validPermit(plUUID, regNum, now)
+	reserved, assigned := v.s.slots[reservationSlotID(reservation)], v.s.slots[permitSlotID(permit)]
+
+	switch {
+	case reserved != nil && reserved.IsAvailable && !reserved.IsMaintenance:
+		slot = reserved
+		v.l.Info("Assigned reserved slot", "slot number", slot.SlotNumber, "slot", slot.Label, "vehicle", regNum, "reservation", reservation.ID)
+	case assigned != nil && assigned.IsAvailable && !assigned.IsMaintenance:
+		slot = assigned
+		v.l.Info("Assigned permit slot", "slot number", slot.SlotNumber, "slot", slot.Label, "vehicle", regNum, "permit", permit.ID)
+	default:
+		allocator := NewSlotAllocator(lot.strategy)
+		var appErr common.AppError
+		if slot, appErr = v.allocateSlot(lot, allocator, regNum, opts, now); appErr != nil {
+			return nil, appErr
+		}
+
+		strategy = allocator.Strategy()
+	}
+
+	slot.IsAvailable = false
+
+	newVehicle := Vehicle{
+		ID:                    uuid.New(),
+		RegistrationNumber:    regNum,
+		RawRegistrationNumber: rawRegNum,
+		VehicleType:           opts.VehicleType,
+		SlotID:                slot.ID,
+		SlotLabel:             slot.Label,
+		ParkedAt:              now,
+	}
+
+	if permit != nil {
+		permitID := permit.ID
+		newVehicle.PermitID = &permitID
+	}
+
+	stored := newVehicle
+	stored.PermitID = copyUUID(newVehicle.PermitID)
+	v.s.vehicles = append(v.s.vehicles, &stored)
+
+	newVehicle.AllocationStrategy = strategy
+	if reservation != nil {
+		// the reserved slot is taken, the reservation moves to the slot the vehicle got.
+		if reservation.SlotID != slot.ID {
+			v.l.Info("Moved reservation", "reservation", reservation.ID, "from", reservation.SlotLabel, "to", slot.Label)
+			reservation.SlotID, reservation.SlotLabel = slot.ID, slot.Label
+		}
+
+		reservation.Status = ReservationFulfilled
+		reservationID := reservation.ID
+		newVehicle.ReservationID = &reservationID
+	}
+
+	return &newVehicle, nil
+}
+
+// reservationSlotID returns the slot of a reservation, uuid.Nil without one.
+func reservationSlotID(r *Reservation) uuid.UUID {
+	if r == nil {
+		return uuid.Nil
+	}
+
+	return r.SlotID
+}
+
+// permitSlotID returns the assigned slot of a permit, uuid.Nil without one.
+func permitSlotID(p *Permit) uuid.UUID {
+	if p == nil || p.SlotID == nil {
+		return uuid.Nil
+	}
+
+	return *p.SlotID
+}
+
+// allocateSlot chooses a free slot of the lot with the allocator, skipping slots kept by reservations starting within WalkInStay
+// or assigned to permits at now.
+// Callers must hold mu.
+func (v *VehicleRepositoryMemory) allocateSlot(lot *memLot, allocator SlotAllocator, regNum string, opts ParkOptions, now time.Time) (*memSlot, common.AppError) {
+	distances := make(map[string]int, len(lot.levels))
+	for _, level := range lot.levels {
+		distances[level.Name] = level.EntranceDistance
+	}
+
+	zoneOccupied := make(map[[2]string]int)
+	for _, s := range lot.slots {
+		if !s.IsAvailable {
+			zoneOccupied[[2]string{s.Level, s.Zone}]++
+		}
+	}
+
+	rank := func(s *memSlot) []int {
+		return []int{slotFit(opts.VehicleType, s.Type), opts.preferenceRank(s.Level, s.Zone)}
+	}
+
+	// candidates are the best ranked slots, in slot number order.
+	var candidates []slotCandidate
+	for _, s := range lot.slots {
+		if !s.IsAvailable || s.IsMaintenance || slotFit(opts.VehicleType, s.Type) < 0 || v.s.slotHeld(s.ID, now) || v.s.slotAssignedAt(s.ID, now) {
+			continue
+		}
+
+		if len(candidates) > 0 {
+			if best := rank(candidates[0].slot); lessRank(best, rank(s)) {
+				continue
+			} else if lessRank(rank(s), best) {
+				candidates = candidates[:0]
+			}
+		}
+
+		candidates = append(candidates, slotCandidate{
+			slot:             s,
+			entranceDistance: distances[s.Level],
+			zoneOccupied:     zoneOccupied[[2]string{s.Level, s.Zone}],
+			lastAllocated:    lot.lastAllocated,
+		})
+	}
+
+	if len(candidates) == 0 {
+		v.l.Error("parking lot is full", "parking_lot_id", lot.id)
+		return nil, common.NewConflictError(common.ErrParkingLotFull)
+	}
+
+	slot := candidates[allocator.choose(candidates)].slot
+	if allocator.Strategy() == AllocationRoundRobin {
+		lot.lastAllocated = slot.SlotNumber
+	}
+
+	v.l.Info("Chosen slot available", "strategy", allocator.Strategy(), "slot number", slot.SlotNumber, "slot", slot.Label,
+		"slot type", slot.Type, "vehicle", regNum, "vehicle type", opts.VehicleType)
+	return slot, nil
+}
+
+// UnparkVehicle unparks the vehicle from the parking lot, charging the fee of its pricing policy and freeing its slot.
+// Stays that started under a valid permit are free until the permit expired or was revoked, the rest of the stay is charged.
+// Returns a 404 Not Found error for unknown parking lots, 409 Conflict if the vehicle is parked in another parking lot,
+// isn't found or has already been unparked.
+func (v *VehicleRepositoryMemory) UnparkVehicle(_ context.Context, plUUID uuid.UUID, regNum string) (*Vehicle, common.AppError) {
+	regNum = plate.Canonicalize(regNum)
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+
+	lot, ok := v.s.lots[plUUID]
+	if !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	vehicle := v.s.parkedVehicleIn(plUUID, regNum)
+	switch {
+	case vehicle == nil && v.s.parkedVehicle(regNum) != nil:
+		v.l.Error(common.ErrVehicleParkedInOther, "registration_number", regNum, "parking_lot_id", plUUID)
+		return nil, common.NewConflictError(common.ErrVehicleParkedInOther)
+	case vehicle == nil:
+		v.l.Error("vehicle not found or already unparked", "registration_number", regNum)
+		return nil, common.NewConflictError("vehicle not found or already unparked")
+	}
+
+	slot := v.s.slots[vehicle.SlotID]
+	policy := v.s.pricingPolicy(lot)
+
+	unparkedAt := v.now()
+	vehicle.Fee = stayFee(policy, vehicle.VehicleType, vehicle.ParkedAt, unparkedAt, v.s.permitEnd(vehicle.PermitID), lot.timeZone)
+	vehicle.Currency = policy.Currency
+	vehicle.PricingPolicyVersion = policy.Version
+	vehicle.UnparkedAt = &unparkedAt
+	slot.IsAvailable = true
+	slot.lastReleasedAt = copyTime(&unparkedAt)
+
+	result := *vehicle
+	result.UnparkedAt = copyTime(vehicle.UnparkedAt)
+	result.PermitID = copyUUID(vehicle.PermitID)
+	return &result, nil
+}
+
+// FindParkedVehicle locates the vehicle most recently parked with the registration number that is still parked, and estimates
+// its fee like VehicleRepositoryDB.FindParkedVehicle. Returns a 404 Not Found error if the vehicle isn't parked.
+func (v *VehicleRepositoryMemory) FindParkedVehicle(_ context.Context, regNum string) (*ParkedVehicle, common.AppError) {
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+
+	pv, policy, appErr := v.findParkedVehicle(uuid.Nil, plate.Canonicalize(regNum))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	pv.EstimatedAt = v.now()
+	pv.EstimatedFee = stayFee(policy, pv.VehicleType, pv.ParkedAt, pv.EstimatedAt, pv.PermitEndsAt, pv.TimeZone)
+	return pv, nil
+}
+
+// QuoteFee itemizes the fee UnparkVehicle would charge the vehicle at, with the same rules as VehicleRepositoryDB.QuoteFee.
+func (v *VehicleRepositoryMemory) QuoteFee(_ context.Context, plUUID uuid.UUID, regNum string, at time.Time) (*FeeQuote, common.AppError) {
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+
+	if _, ok := v.s.lots[plUUID]; !ok {
+		return nil, common.NewNotFoundError(common.ErrUnexpectedDatabase)
+	}
+
+	pv, policy, appErr := v.findParkedVehicle(plUUID, plate.Canonicalize(regNum))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if at.IsZero() {
+		at = v.now()
+	}
+
+	return newFeeQuote(pv, policy, at)
+}
+
+// findParkedVehicle locates the vehicle parked with the canonical registration number in the parking lot, in any parking lot
+// if plUUID is uuid.Nil, and its parking lot's pricing policy. Callers must hold mu.
+func (v *VehicleRepositoryMemory) findParkedVehicle(plUUID uuid.UUID, regNum string) (*ParkedVehicle, *PricingPolicy, common.AppError) {
+	vehicle := v.s.parkedVehicle(regNum)
+	if plUUID != uuid.Nil {
+		vehicle = v.s.parkedVehicleIn(plUUID, regNum)
+	}
+
+	if vehicle == nil {
+		return nil, nil, notParkedError(plUUID != uuid.Nil && v.s.parkedVehicle(regNum) != nil)
+	}
+
+	slot := v.s.slots[vehicle.SlotID]
+	lot := v.s.lots[slot.lotID]
+	policy := v.s.pricingPolicy(lot)
+
+	return &ParkedVehicle{
+		ID:                   vehicle.ID,
+		RegistrationNumber:   vehicle.RegistrationNumber,
+		VehicleType:          vehicle.VehicleType,
+		ParkingLotID:         lot.id,
+		ParkingLotName:       lot.name,
+		TimeZone:             lot.timeZone,
+		SlotID:               slot.ID,
+		SlotNumber:           slot.SlotNumber,
+		SlotLabel:            slot.Label,
+		Level:                slot.Level,
+		Zone:                 slot.Zone,
+		ParkedAt:             vehicle.ParkedAt,
+		Currency:             policy.Currency,
+		PricingPolicyVersion: policy.Version,
+		PermitID:             copyUUID(vehicle.PermitID),
+		PermitEndsAt:         v.s.permitEnd(vehicle.PermitID),
+	}, policy, nil
+}
+
+// lessRank compares slot ranks lexicographically.
+func lessRank(a, b []int) bool {
+	return slices.Compare(a, b) < 0
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VehicleType is the category of a vehicle, slots have the same categories, a slot of a type is sized for that vehicle.
+// VehicleTypeEV slots have a charger.
+type VehicleType string
+
+const (
+	VehicleTypeMotorcycle VehicleType = "motorcycle"
+	VehicleTypeCar        VehicleType = "car"
+	VehicleTypeVan        VehicleType = "van"
+	VehicleTypeTruck      VehicleType = "truck"
+	VehicleTypeEV         VehicleType = "ev"
+
+	// DefaultVehicleType is used for vehicles and slots without a type, eg: slots created before types existed.
+	DefaultVehicleType = VehicleTypeCar
+)
+
+// VehicleTypes lists every vehicle type, slots of a lot created by counts per type are numbered in this order.
+var VehicleTypes = []VehicleType{VehicleTypeMotorcycle, VehicleTypeCar, VehicleTypeVan, VehicleTypeTruck, VehicleTypeEV}
+
+// compatibleSlotTypes lists the slot types each vehicle type fits in, best fit first.
+// A vehicle only takes a larger slot once every slot of a better fitting type is taken,
+// eg: a motorcycle uses a car slot only when motorcycle slots are full. Vehicles without a charger never take EV slots.
+var compatibleSlotTypes = map[VehicleType][]VehicleType{
+	VehicleTypeMotorcycle: {VehicleTypeMotorcycle, VehicleTypeCar},
+	VehicleTypeCar:        {VehicleTypeCar, VehicleTypeVan},
+	VehicleTypeVan:        {VehicleTypeVan, VehicleTypeTruck},
+	VehicleTypeTruck:      {VehicleTypeTruck},
+	VehicleTypeEV:         {VehicleTypeEV, VehicleTypeCar, VehicleTypeVan},
+}
+
+// ParseVehicleType parses a vehicle type case-insensitively, an empty string is the default type.
+func ParseVehicleType(s string) (VehicleType, error) {
+	if s == "" {
+		return DefaultVehicleType, nil
+	}
+
+	t := VehicleType(strings.ToLower(s))
+	if !t.Valid() {
+		return "", fmt.Errorf("vehicle type must be one of %v, got %q", VehicleTypes, s)
+	}
+
+	return t, nil
+}
+
+// Valid reports whether t is a known vehicle type.
+func (t VehicleType) Valid() bool {
+	_, ok := compatibleSlotTypes[t]
+	return ok
+}
+
+// CompatibleSlotTypes returns the slot types a vehicle of type t may park in, best fit first.
+func (t VehicleType) CompatibleSlotTypes() []VehicleType {
+	return compatibleSlotTypes[t]
+}
+
+// compatibleSlotTypeNames returns CompatibleSlotTypes as strings, to query them as a postgres text array.
+func (t VehicleType) compatibleSlotTypeNames() []string {
+	names := make([]string, 0, len(compatibleSlotTypes[t]))
+	for _, st := range compatibleSlotTypes[t] {
+		names = append(names, string(st))
+	}
+
+	return names
+}
+
+// slotFit ranks how well a slot type fits a vehicle type, lower is better, -1 if the vehicle doesn't fit.
+func slotFit(vehicle, slot VehicleType) int {
+	for i, t := range compatibleSlotTypes[vehicle] {
+		if t == slot {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,169 @@
+// Package export writes tables as CSV files or XLSX spreadsheets one row at a time, so large exports stream to clients
+// without being held in memory.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the representation of a response, picked by content negotiation.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeCSV  = "text/csv"
+	MediaTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// ContentType returns the Content-Type header of a response in the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return MediaTypeCSV + "; charset=utf-8"
+	case FormatXLSX:
+		return MediaTypeXLSX
+	default:
+		return MediaTypeJSON
+	}
+}
+
+// Negotiate picks the format of a response from the format query parameter, json, csv or xlsx, and otherwise from the
+// media types of the Accept header by preference. JSON is the default when neither names a supported format.
+func Negotiate(format string, accept string) (Format, error) {
+	switch f := Format(strings.ToLower(format)); f {
+	case "":
+	case FormatJSON, FormatCSV, FormatXLSX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("format must be json, csv or xlsx, got %s", format)
+	}
+
+	best, bestQ := FormatJSON, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var f Format
+		switch mediaType {
+		case MediaTypeJSON:
+			f = FormatJSON
+		case MediaTypeCSV:
+			f = FormatCSV
+		case MediaTypeXLSX:
+			f = FormatXLSX
+		default:
+			continue
+		}
+
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+
+	return best, nil
+}
+
+// Writer writes the rows of a table, the first one usually being the column names.
+type Writer interface {
+	// WriteRow writes a row of cells: strings, integers, floats, times, pointers to them and values printed with fmt.
+	// nil and nil pointers are empty cells.
+	WriteRow(cells ...any) error
+	// Close completes the file after the last row, the output is invalid until then.
+	Close() error
+}
+
+// NewWriter returns a Writer of an XLSX spreadsheet with a single sheet named sheet, or of a CSV file for any other format.
+func NewWriter(f Format, w io.Writer, sheet string) Writer {
+	if f == FormatXLSX {
+		return NewXLSXWriter(w, sheet)
+	}
+
+	return NewCSVWriter(w)
+}
+
+// CSVWriter writes RFC 4180 CSV files.
+type CSVWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteRow writes a row of cells. Text that spreadsheets would run as a formula, starting with =, +, -, @ or a control
+// character, is prefixed with a quote.
+func (c *CSVWriter) WriteRow(cells ...any) error {
+	c.row = c.row[:0]
+	for _, cell := range cells {
+		v, isText := format(cell)
+		if isText && v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			v = "'" + v
+		}
+
+		c.row = append(c.row, v)
+	}
+
+	return c.w.Write(c.row)
+}
+
+// Close flushes the rows written.
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// format returns the text of a cell, reporting whether it's text rather than a number.
+func format(cell any) (string, bool) {
+	switch v := cell.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), false
+	case int64:
+		return strconv.FormatInt(v, 10), false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), false
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	case *int:
+		if v == nil {
+			return "", false
+		}
+
+		return strconv.Itoa(*v), false
+	case *time.Time:
+		if v == nil {
+			return "", false
+		}
+
+		return v.Format(time.RFC3339), true
+	case fmt.Stringer:
+		return v.String(), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
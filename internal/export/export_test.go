@@ -0,0 +1,133 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		accept   string
+		expected Format
+		wantErr  bool
+	}{
+		{"default", "", "", FormatJSON, false},
+		{"any media type", "", "*/*", FormatJSON, false},
+		{"csv", "", "text/csv", FormatCSV, false},
+		{"xlsx", "", MediaTypeXLSX, FormatXLSX, false},
+		{"preferred by quality", "", "application/json;q=0.5, text/csv;q=0.9, " + MediaTypeXLSX + ";q=0.1", FormatCSV, false},
+		{"first on equal quality", "", "text/csv, application/json", FormatCSV, false},
+		{"unsupported media types", "", "text/html, application/xml", FormatJSON, false},
+		{"format overrides accept", "xlsx", "text/csv", FormatXLSX, false},
+		{"format case insensitive", "CSV", "", FormatCSV, false},
+		{"unknown format", "pdf", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Negotiate(tt.format, tt.accept)
+			if (err != nil) != tt.wantErr || got != tt.expected {
+				t.Errorf("Negotiate(%q, %q) = %q, %v; expected %q", tt.format, tt.accept, got, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+
+	fee := 30
+	parkedAt := time.Date(2024, time.March, 13, 10, 0, 0, 0, time.UTC)
+	rows := [][]any{
+		{"Registration Number", "Parked At", "Unparked At", "Fee", "Hours"},
+		{"ABC 123, GB", parkedAt, (*time.Time)(nil), &fee, 1.5},
+		{"=SUM(A1)", parkedAt, &parkedAt, (*int)(nil), -2},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatalf("WriteRow returned error %v", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+
+	expected := "Registration Number,Parked At,Unparked At,Fee,Hours\n" +
+		"\"ABC 123, GB\",2024-03-13T10:00:00Z,,30,1.5\n" +
+		"'=SUM(A1),2024-03-13T10:00:00Z,2024-03-13T10:00:00Z,,-2\n"
+	if buf.String() != expected {
+		t.Errorf("CSVWriter wrote %q; expected %q", buf.String(), expected)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXLSXWriter(&buf, "Sessions")
+	if err := w.WriteRow("Registration Number", "Fee"); err != nil {
+		t.Fatalf("WriteRow returned error %v", err)
+	}
+
+	if err := w.WriteRow("A<B & \"C\"", 30, nil, 1.5); err != nil {
+		t.Fatalf("WriteRow returned error %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading the spreadsheet: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+
+		parts[f.Name] = string(b)
+		if err = xml.Unmarshal(b, new(struct{})); err != nil {
+			t.Errorf("%s isn't well formed XML: %v", f.Name, err)
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("spreadsheet is missing %s", name)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="Sessions"`) {
+		t.Errorf("workbook %s doesn't name the sheet Sessions", parts["xl/workbook.xml"])
+	}
+
+	expected := `<row r="2"><c r="A2" t="inlineStr"><is><t xml:space="preserve">A&lt;B &amp; &#34;C&#34;</t></is></c>` +
+		`<c r="B2"><v>30</v></c><c r="D2"><v>1.5</v></c></row>`
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], expected) {
+		t.Errorf("sheet %s doesn't contain the row %s", parts["xl/worksheets/sheet1.xml"], expected)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != expected {
+			t.Errorf("columnName(%d) = %s; expected %s", i, got, expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// the parts of a workbook with a single sheet, written before the sheet so its rows can stream last.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSXWriter writes an Office Open XML spreadsheet with a single sheet. Text is written inline rather than shared,
+// so rows never need to be held in memory, and numbers are written as numbers.
+type XLSXWriter struct {
+	z       *zip.Writer
+	sheet   string
+	started bool
+	w       io.Writer // the part being written, the sheet once started
+	rows    int
+	err     error
+}
+
+// NewXLSXWriter returns a writer of a spreadsheet with a sheet named sheet, at most 31 characters.
+func NewXLSXWriter(w io.Writer, sheet string) *XLSXWriter {
+	if len(sheet) > 31 {
+		sheet = sheet[:31]
+	}
+
+	return &XLSXWriter{z: zip.NewWriter(w), sheet: sheet}
+}
+
+// WriteRow writes a row of cells.
+func (x *XLSXWriter) WriteRow(cells ...any) error {
+	if !x.started {
+		x.start()
+	}
+
+	x.rows++
+	x.write(`<row r="` + strconv.Itoa(x.rows) + `">`)
+	for i, cell := range cells {
+		v, isText := format(cell)
+		ref := columnName(i) + strconv.Itoa(x.rows)
+		switch {
+		case v == "":
+		case isText:
+			x.write(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			x.escape(v)
+			x.write(`</t></is></c>`)
+		default:
+			x.write(`<c r="` + ref + `"><v>` + v + `</v></c>`)
+		}
+	}
+
+	x.write(`</row>`)
+	return x.err
+}
+
+// Close completes the sheet and the spreadsheet.
+func (x *XLSXWriter) Close() error {
+	if !x.started {
+		x.start()
+	}
+
+	x.write(`</sheetData></worksheet>`)
+	if x.err != nil {
+		return x.err
+	}
+
+	return x.z.Close()
+}
+
+// start writes the workbook and opens its sheet.
+func (x *XLSXWriter) start() {
+	x.started = true
+	for _, part := range xlsxParts {
+		x.create(part.name)
+		x.write(part.content)
+	}
+
+	x.create("xl/workbook.xml")
+	x.write(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	x.escape(x.sheet)
+	x.write(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+
+	x.create("xl/worksheets/sheet1.xml")
+	x.write(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+}
+
+// create starts the part named name, unless a previous write failed.
+func (x *XLSXWriter) create(name string) {
+	if x.err == nil {
+		x.w, x.err = x.z.Create(name)
+	}
+}
+
+// write writes s to the current part, unless a previous write failed.
+func (x *XLSXWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = io.WriteString(x.w, s)
+	}
+}
+
+// escape writes s as XML text, replacing characters XML can't hold.
+func (x *XLSXWriter) escape(s string) {
+	if x.err == nil {
+		x.err = xml.EscapeText(x.w, []byte(s))
+	}
+}
+
+// columnName returns the letters of the i-th column, from 0, eg: A, Z, AA.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+
+	return name
+}
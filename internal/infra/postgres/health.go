@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PingCheck returns a readiness check pinging the database, reporting the round trip latency.
+func PingCheck(db *sql.DB) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		start := time.Now()
+		if err := db.PingContext(ctx); err != nil {
+			return nil, fmt.Errorf("pinging database: %w", err)
+		}
+
+		return map[string]string{"latency": time.Since(start).String()}, nil
+	}
+}
+
+// MigrationCheck returns a readiness check failing while the schema is behind the embedded migrations,
+// eg: while a starting instance applies them, or after `gopark migrate down` rolled back the schema of a running one.
+func MigrationCheck(m *Migrator) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		current, err := m.CurrentVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		details := map[string]int{"current": current, "latest": m.LatestVersion()}
+		if current < m.LatestVersion() {
+			return details, fmt.Errorf("schema version %d is behind latest migration %d", current, m.LatestVersion())
+		}
+
+		return details, nil
+	}
+}
+
+// PoolCheck returns a readiness check failing when every allowed connection is in use, new queries would have to wait.
+func PoolCheck(db *sql.DB) func(ctx context.Context) (any, error) {
+	return func(context.Context) (any, error) {
+		stats := db.Stats()
+		details := map[string]any{
+			"maxOpen":   stats.MaxOpenConnections,
+			"open":      stats.OpenConnections,
+			"inUse":     stats.InUse,
+			"idle":      stats.Idle,
+			"waitCount": stats.WaitCount,
+		}
+
+		if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+			return details, fmt.Errorf("connection pool saturated, %d of %d connections in use", stats.InUse, stats.MaxOpenConnections)
+		}
+
+		return details, nil
+	}
+}
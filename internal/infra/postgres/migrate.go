@@ -0,0 +1,302 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockKey is the pg advisory lock key held while migrating, so concurrent app instances migrate one at a time.
+const migrationLockKey int64 = 4_207_319_886
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single versioned schema change, built from a pair of <version>_<name>.up.sql and .down.sql files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied, AppliedAt is nil for pending migrations.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the embedded migrations, tracking applied versions in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	l          *slog.Logger
+	migrations []Migration
+}
+
+// NewMigrator loads the embedded migrations, returns an error if any of them is malformed.
+func NewMigrator(db *sql.DB, l *slog.Logger) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		l:          l,
+		migrations: migrations,
+	}, nil
+}
+
+// loadMigrations reads every migration of fsys, sorted by version.
+// Each version must have exactly one up and one down file, versions must be unique.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>.(up|down).sql", name)
+		}
+
+		versionStr, title, _ := strings.Cut(base, "_")
+		version, convErr := strconv.Atoi(versionStr)
+		if convErr != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has an invalid version", name)
+		}
+
+		content, readErr := fs.ReadFile(fsys, path.Join("migrations", name))
+		if readErr != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", name, readErr)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, title)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each one in its own transaction, returns the number applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			if _, ok := versions[mg.Version]; ok {
+				continue
+			}
+
+			if err = runInTx(ctx, conn, mg.Up,
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())", mg.Version, mg.Name); err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			m.l.Info("applied migration", "version", mg.Version, "name", mg.Name)
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration, it's a no-op when nothing is applied.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := versions[mg.Version]; !ok {
+				continue
+			}
+
+			if err = runInTx(ctx, conn, mg.Down, "DELETE FROM schema_migrations WHERE version = $1", mg.Version); err != nil {
+				return fmt.Errorf("rolling back migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			m.l.Info("rolled back migration", "version", mg.Version, "name", mg.Name)
+			return nil
+		}
+
+		m.l.Info("no migration to roll back")
+		return nil
+	})
+}
+
+// Status lists every known migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err = ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := MigrationStatus{Version: mg.Version, Name: mg.Name}
+		if appliedAt, ok := versions[mg.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
+
+// LatestVersion returns the version of the newest embedded migration.
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CurrentVersion returns the highest applied migration version, 0 if none is applied yet.
+func (m *Migrator) CurrentVersion(ctx context.Context) (int, error) {
+	var version int
+	err := m.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock,
+// advisory locks are session scoped so the lock, migrations and unlock must share the connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+
+	defer func() {
+		// use a fresh context, the lock must be released even if ctx was canceled.
+		if _, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); unlockErr != nil {
+			m.l.Error("error releasing migration lock", "err", unlockErr)
+		}
+	}()
+
+	if err = ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations
+        (
+            version    INTEGER PRIMARY KEY,
+            name       VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMPTZ  NOT NULL
+        )`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	return nil
+}
+
+// appliedVersions returns applied migration versions mapped to the time they were applied.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scanning applied migration: %w", err)
+		}
+
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// runInTx executes a migration script and its schema_migrations bookkeeping statement atomically.
+func runInTx(ctx context.Context, conn *sql.Conn, script string, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	if _, err = tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
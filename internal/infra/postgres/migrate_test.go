@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoadMigrations tests the embedded migrations are well-formed, every version has up and down scripts,
+// and versions are sequential starting from 1.
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations() returned error %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("loadMigrations() returned no migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %s has version %d; expected %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+// TestLoadMigrationsInvalid tests malformed migration sets are rejected.
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_init.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"invalid direction": {
+			"migrations/0001_init.sideways.sql": {Data: []byte("SELECT 1;")},
+		},
+		"invalid version": {
+			"migrations/init.up.sql":   {Data: []byte("SELECT 1;")},
+			"migrations/init.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"duplicate version": {
+			"migrations/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+			"migrations/0001_init.down.sql":  {Data: []byte("SELECT 1;")},
+			"migrations/0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+			"migrations/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Errorf("loadMigrations() returned no error for %s", name)
+			}
+		})
+	}
+}
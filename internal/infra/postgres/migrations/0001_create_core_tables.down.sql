@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS vehicles;
+DROP TABLE IF EXISTS slots;
+DROP TABLE IF EXISTS parking_lots;
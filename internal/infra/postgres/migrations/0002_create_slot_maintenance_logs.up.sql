@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS slot_maintenance_logs
+(
+    id          SERIAL PRIMARY KEY,
+    uuid        UUID DEFAULT uuid_generate_v4(),
+    slot_id     INTEGER      NOT NULL REFERENCES slots (id),
+    reason      TEXT         NOT NULL DEFAULT '',
+    started_by  VARCHAR(255) NOT NULL,
+    started_at  TIMESTAMPTZ  NOT NULL,
+    ended_by    VARCHAR(255),
+    ended_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slot_maintenance_logs_uuid ON slot_maintenance_logs (uuid);
+CREATE INDEX IF NOT EXISTS idx_slot_maintenance_logs_slot_id ON slot_maintenance_logs (slot_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS pricing_policies
+(
+    id                      SERIAL PRIMARY KEY,
+    parking_lot_id          INTEGER     NOT NULL REFERENCES parking_lots (id),
+    version                 INTEGER     NOT NULL DEFAULT 1,
+    hourly_rate             INTEGER     NOT NULL DEFAULT 10,
+    first_hour_rate         INTEGER     NOT NULL DEFAULT 0,
+    grace_period_minutes    INTEGER     NOT NULL DEFAULT 0,
+    daily_cap               INTEGER     NOT NULL DEFAULT 0,
+    night_flat_rate         INTEGER     NOT NULL DEFAULT 0,
+    night_start_hour        INTEGER     NOT NULL DEFAULT 0,
+    night_end_hour          INTEGER     NOT NULL DEFAULT 0,
+    weekend_hourly_rate     INTEGER     NOT NULL DEFAULT 0,
+    weekend_first_hour_rate INTEGER     NOT NULL DEFAULT 0,
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pricing_policies_parking_lot_id ON pricing_policies (parking_lot_id);
@@ -0,0 +1,6 @@
+ALTER TABLE vehicles
+    DROP COLUMN IF EXISTS pricing_policy_version,
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS fee;
+
+ALTER TABLE pricing_policies DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,13 @@
+ALTER TABLE pricing_policies ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE vehicles
+    ADD COLUMN IF NOT EXISTS fee                    INTEGER,
+    ADD COLUMN IF NOT EXISTS currency               CHAR(3),
+    ADD COLUMN IF NOT EXISTS pricing_policy_version INTEGER;
+
+-- Backfill vehicles unparked before fees were stored with the flat hourly rate they were charged.
+UPDATE vehicles
+SET fee                    = CEIL(EXTRACT(EPOCH FROM (unparked_at - parked_at)) / 3600)::int * 10,
+    currency               = 'USD',
+    pricing_policy_version = 0
+WHERE unparked_at IS NOT NULL AND fee IS NULL;
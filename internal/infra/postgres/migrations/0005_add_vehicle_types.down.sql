@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_slots_free_by_type;
+
+ALTER TABLE pricing_policies DROP COLUMN IF EXISTS vehicle_type_multipliers;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS vehicle_type;
+ALTER TABLE slots DROP COLUMN IF EXISTS slot_type;
@@ -0,0 +1,13 @@
+ALTER TABLE slots
+    ADD COLUMN IF NOT EXISTS slot_type VARCHAR(20) NOT NULL DEFAULT 'car'
+        CHECK (slot_type IN ('motorcycle', 'car', 'van', 'truck', 'ev'));
+
+ALTER TABLE vehicles
+    ADD COLUMN IF NOT EXISTS vehicle_type VARCHAR(20) NOT NULL DEFAULT 'car'
+        CHECK (vehicle_type IN ('motorcycle', 'car', 'van', 'truck', 'ev'));
+
+ALTER TABLE pricing_policies ADD COLUMN IF NOT EXISTS vehicle_type_multipliers JSONB NOT NULL DEFAULT '{}';
+
+-- Slot allocation scans the free slots of a lot by type and slot number.
+CREATE INDEX IF NOT EXISTS idx_slots_free_by_type ON slots (parking_lot_id, slot_type, slot_number)
+    WHERE is_available AND NOT is_maintenance;
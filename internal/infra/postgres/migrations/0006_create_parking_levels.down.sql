@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_slots_lot_label;
+
+ALTER TABLE slots
+    DROP COLUMN IF EXISTS label,
+    DROP COLUMN IF EXISTS zone,
+    DROP COLUMN IF EXISTS level_id;
+
+DROP TABLE IF EXISTS parking_levels;
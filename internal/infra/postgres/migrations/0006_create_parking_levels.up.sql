@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS parking_levels
+(
+    id                SERIAL PRIMARY KEY,
+    parking_lot_id    INTEGER      NOT NULL REFERENCES parking_lots (id),
+    name              VARCHAR(50)  NOT NULL,
+    entrance_distance INTEGER      NOT NULL DEFAULT 0 CHECK (entrance_distance >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_parking_levels_lot_name ON parking_levels (parking_lot_id, name);
+
+-- Slots of lots created without a layout have no level, an empty zone and their slot number as label.
+ALTER TABLE slots
+    ADD COLUMN IF NOT EXISTS level_id INTEGER REFERENCES parking_levels (id),
+    ADD COLUMN IF NOT EXISTS zone     VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS label    VARCHAR(120);
+
+UPDATE slots SET label = slot_number::text WHERE label IS NULL;
+
+ALTER TABLE slots ALTER COLUMN label SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slots_lot_label ON slots (parking_lot_id, label);
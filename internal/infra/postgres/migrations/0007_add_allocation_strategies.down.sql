@@ -0,0 +1,6 @@
+ALTER TABLE slots
+    DROP COLUMN IF EXISTS last_released_at;
+
+ALTER TABLE parking_lots
+    DROP COLUMN IF EXISTS last_allocated_slot,
+    DROP COLUMN IF EXISTS allocation_strategy;
@@ -0,0 +1,12 @@
+-- Slot allocation strategy of every parking lot, see domain.AllocationStrategy. last_allocated_slot tracks round-robin
+-- allocation and last_released_at least-recently-used allocation.
+ALTER TABLE parking_lots
+    ADD COLUMN IF NOT EXISTS allocation_strategy VARCHAR(30) NOT NULL DEFAULT 'nearest'
+        CHECK (allocation_strategy IN ('nearest', 'round-robin', 'least-recently-used', 'random', 'fill-by-zone')),
+    ADD COLUMN IF NOT EXISTS last_allocated_slot INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE slots
+    ADD COLUMN IF NOT EXISTS last_released_at TIMESTAMPTZ;
+
+UPDATE slots s
+SET last_released_at = (SELECT MAX(v.unparked_at) FROM vehicles v WHERE v.slot_id = s.id);
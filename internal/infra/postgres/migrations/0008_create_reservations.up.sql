@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS reservations
+(
+    id                  SERIAL PRIMARY KEY,
+    uuid                UUID         DEFAULT uuid_generate_v4(),
+    parking_lot_id      INTEGER      NOT NULL REFERENCES parking_lots (id),
+    slot_id             INTEGER      NOT NULL REFERENCES slots (id),
+    registration_number VARCHAR(255) NOT NULL,
+    vehicle_type        VARCHAR(20)  NOT NULL DEFAULT 'car'
+        CHECK (vehicle_type IN ('motorcycle', 'car', 'van', 'truck', 'ev')),
+    starts_at           TIMESTAMPTZ  NOT NULL,
+    ends_at             TIMESTAMPTZ  NOT NULL,
+    hold_until          TIMESTAMPTZ  NOT NULL,
+    status              VARCHAR(20)  NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'fulfilled', 'cancelled', 'expired')),
+    vehicle_id          INTEGER REFERENCES vehicles (id),
+    created_at          TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CHECK (ends_at > starts_at AND hold_until BETWEEN starts_at AND ends_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_uuid ON reservations (uuid);
+
+-- Conflict detection and held slot lookups only look at reservations still holding or occupying their slot.
+CREATE INDEX IF NOT EXISTS idx_reservations_live_slot ON reservations (slot_id, starts_at)
+    WHERE status IN ('active', 'fulfilled');
+CREATE INDEX IF NOT EXISTS idx_reservations_live_plate ON reservations (registration_number, starts_at)
+    WHERE status IN ('active', 'fulfilled');
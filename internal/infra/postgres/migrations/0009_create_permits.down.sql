@@ -0,0 +1,3 @@
+ALTER TABLE vehicles DROP COLUMN IF EXISTS permit_id;
+DROP TABLE IF EXISTS permit_plates;
+DROP TABLE IF EXISTS permits;
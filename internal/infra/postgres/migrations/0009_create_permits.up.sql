@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS permits
+(
+    id             SERIAL PRIMARY KEY,
+    uuid           UUID         DEFAULT uuid_generate_v4(),
+    parking_lot_id INTEGER      NOT NULL REFERENCES parking_lots (id),
+    holder         VARCHAR(255) NOT NULL,
+    slot_id        INTEGER REFERENCES slots (id), -- NULL for floating permits
+    valid_from     TIMESTAMPTZ  NOT NULL,
+    valid_until    TIMESTAMPTZ  NOT NULL,
+    revoked_at     TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CHECK (valid_until > valid_from)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permits_uuid ON permits (uuid);
+
+-- Assigned slot lookups only look at permits that haven't been revoked.
+CREATE INDEX IF NOT EXISTS idx_permits_assigned_slot ON permits (slot_id, valid_from)
+    WHERE slot_id IS NOT NULL AND revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS permit_plates
+(
+    permit_id           INTEGER      NOT NULL REFERENCES permits (id),
+    registration_number VARCHAR(255) NOT NULL,
+    position            INTEGER      NOT NULL,
+    PRIMARY KEY (permit_id, registration_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_permit_plates_registration_number ON permit_plates (registration_number);
+
+-- Stays that started under a valid permit are free, reports split them from transient parking.
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS permit_id INTEGER REFERENCES permits (id);
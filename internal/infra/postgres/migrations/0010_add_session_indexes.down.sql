@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_vehicles_slot_parked_at;
+DROP INDEX IF EXISTS idx_vehicles_registration_number_parked_at;
@@ -0,0 +1,3 @@
+-- Session history pages through a vehicle's or a lot's stays by (parked_at, uuid), see SessionRepoDB.
+CREATE INDEX IF NOT EXISTS idx_vehicles_registration_number_parked_at ON vehicles (registration_number, parked_at, uuid);
+CREATE INDEX IF NOT EXISTS idx_vehicles_slot_parked_at ON vehicles (slot_id, parked_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_vehicles_active_plate;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS plate_scope;
@@ -0,0 +1,4 @@
+-- plate_scope is 0 for vehicles parked under the single-lot plate policy and the parking lot id under the multi-lot one,
+-- so the index allows a registration number once among active vehicles, or once per parking lot. See domain.PlatePolicy.
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS plate_scope INTEGER NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_active_plate ON vehicles (registration_number, plate_scope) WHERE unparked_at IS NULL;
@@ -0,0 +1,3 @@
+-- Reservations and permit plates keep their canonical plates, 0015 stores the spelling they were entered with from then on.
+UPDATE vehicles SET registration_number = registration_number_raw WHERE registration_number_raw IS NOT NULL;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS registration_number_raw;
@@ -0,0 +1,12 @@
+-- registration_number holds the canonical plate every lookup uses, registration_number_raw the plate as entered on park.
+-- Existing plates are canonicalized like plate.Canonicalize without its Unicode mappings: upper case, letters and digits only.
+-- Two parked vehicles or two plates of a permit that only differ in spelling fail the unique indexes, unpark or fix them first.
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS registration_number_raw VARCHAR(255);
+
+UPDATE vehicles
+SET registration_number_raw = registration_number,
+    registration_number     = upper(regexp_replace(registration_number, '[^[:alnum:]]', '', 'g'))
+WHERE registration_number_raw IS NULL;
+
+UPDATE reservations SET registration_number = upper(regexp_replace(registration_number, '[^[:alnum:]]', '', 'g'));
+UPDATE permit_plates SET registration_number = upper(regexp_replace(registration_number, '[^[:alnum:]]', '', 'g'));
@@ -0,0 +1,2 @@
+-- Ranged and portfolio reports scan the stays of a period by parked_at across lots, see ParkingLotRepoDB.rangeUsage.
+CREATE INDEX IF NOT EXISTS idx_vehicles_parked_at ON vehicles (parked_at) INCLUDE (unparked_at, slot_id, fee);
@@ -0,0 +1,2 @@
+-- time_zone is the IANA time zone reports slice days in, see domain.LoadTimeZone. Existing parking lots keep reporting in UTC.
+ALTER TABLE parking_lots ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
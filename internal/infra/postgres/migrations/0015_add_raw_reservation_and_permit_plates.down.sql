@@ -0,0 +1,2 @@
+ALTER TABLE permit_plates DROP COLUMN IF EXISTS registration_number_raw;
+ALTER TABLE reservations DROP COLUMN IF EXISTS registration_number_raw;
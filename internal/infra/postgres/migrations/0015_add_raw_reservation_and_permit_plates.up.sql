@@ -0,0 +1,4 @@
+-- registration_number_raw is the plate as entered, like vehicles.registration_number_raw. Plates stored before this
+-- migration were canonicalized by 0012, their spelling is lost and they read back as their canonical form.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS registration_number_raw VARCHAR(255);
+ALTER TABLE permit_plates ADD COLUMN IF NOT EXISTS registration_number_raw VARCHAR(255);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_slot_maintenance_logs_started_at;
+ALTER TABLE slot_maintenance_logs DROP COLUMN IF EXISTS forced;
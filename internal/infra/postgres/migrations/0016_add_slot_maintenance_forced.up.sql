@@ -0,0 +1,6 @@
+-- forced records maintenance windows started on an occupied slot with force, so overrides can be audited.
+-- Windows started before this migration read as not forced, whether they were isn't known.
+ALTER TABLE slot_maintenance_logs ADD COLUMN IF NOT EXISTS forced BOOLEAN NOT NULL DEFAULT false;
+
+-- Maintenance history is paged by start time, see ParkingLotRepoDB.ListMaintenanceWindows.
+CREATE INDEX IF NOT EXISTS idx_slot_maintenance_logs_started_at ON slot_maintenance_logs (started_at DESC, uuid DESC);
@@ -0,0 +1,131 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Manager serves HTTP until SIGINT/SIGTERM, reporting the app as starting until MarkStarted is called,
+// eg: while migrations are applied, then shuts the app down in order:
+// 1. Reports shutting down, so readiness fails, and keeps serving for the readiness delay while load balancers notice.
+// 2. Stops accepting new connections and drains in-flight requests via http.Server.Shutdown, bounded by the shutdown timeout.
+// 3. Runs registered shutdown hooks in reverse registration order (eg: background workers, then the database pool),
+// sharing what's left of the same deadline.
+type Manager struct {
+	l               *slog.Logger
+	shutdownTimeout time.Duration
+	readinessDelay  time.Duration
+	hooks           []hook
+	started         atomic.Bool
+	shuttingDown    atomic.Bool
+	srv             *http.Server
+	ctx             context.Context
+	stop            context.CancelFunc
+	serveErr        chan error
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func NewManager(l *slog.Logger, shutdownTimeout, readinessDelay time.Duration) *Manager {
+	return &Manager{
+		l:               l,
+		shutdownTimeout: shutdownTimeout,
+		readinessDelay:  readinessDelay,
+	}
+}
+
+// OnShutdown registers fn to run after the server has drained, hooks run last registered first,
+// so dependencies should be registered before the components using them.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// MarkStarted records that startup work done while already serving, eg: migrations, has finished.
+func (m *Manager) MarkStarted() {
+	m.started.Store(true)
+}
+
+// Starting reports whether MarkStarted hasn't been called yet.
+func (m *Manager) Starting() bool {
+	return !m.started.Load()
+}
+
+// ShuttingDown reports whether shutdown has started.
+func (m *Manager) ShuttingDown() bool {
+	return m.shuttingDown.Load()
+}
+
+// Run serves srv on ln until ctx is canceled or SIGINT/SIGTERM is received, then shuts down gracefully.
+// Returns the serve error if the server fails on its own, otherwise every shutdown error joined.
+func (m *Manager) Run(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	m.Start(ctx, srv, ln)
+	return m.Wait()
+}
+
+// Start serves srv on ln in the background and starts listening for SIGINT/SIGTERM, so health checks answer
+// while the rest of startup runs. Wait must be called to shut down.
+func (m *Manager) Start(ctx context.Context, srv *http.Server, ln net.Listener) {
+	m.srv = srv
+	m.ctx, m.stop = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	m.serveErr = make(chan error, 1)
+
+	go func() {
+		m.l.Info("Server starting...", slog.String("address", ln.Addr().String()))
+		m.serveErr <- srv.Serve(ln)
+	}()
+}
+
+// Wait blocks until the context given to Start is canceled, a signal is received or the server fails, then shuts down gracefully.
+// Returns the serve error if the server fails on its own, otherwise every shutdown error joined.
+func (m *Manager) Wait() error {
+	defer m.stop()
+
+	var errs []error
+	select {
+	case err := <-m.serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			m.l.Error("error starting server", "err", err)
+			errs = append(errs, err)
+		}
+	case <-m.ctx.Done():
+		m.shuttingDown.Store(true)
+		m.l.Info("shutdown signal received, reporting not ready before draining", "delay", m.readinessDelay)
+		time.Sleep(m.readinessDelay)
+		m.l.Info("draining in-flight requests", "timeout", m.shutdownTimeout)
+	}
+
+	m.shuttingDown.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	if err := m.srv.Shutdown(shutdownCtx); err != nil {
+		m.l.Error("error draining http server", "err", err)
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		h := m.hooks[i]
+		if err := h.fn(shutdownCtx); err != nil {
+			m.l.Error("error shutting down", "component", h.name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+
+		m.l.Info("stopped", "component", h.name)
+	}
+
+	m.l.Info("shutdown complete")
+	return errors.Join(errs...)
+}
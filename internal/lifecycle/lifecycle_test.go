@@ -0,0 +1,98 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRunDrainsAndStopsInOrder tests an in-flight request completes after shutdown starts,
+// and hooks run in reverse registration order.
+func TestRunDrainsAndStopsInOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	started := make(chan struct{})
+	srv := &http.Server{
+		ReadHeaderTimeout: time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), 5*time.Second, 0)
+
+	var stopped []string
+	m.OnShutdown("database", func(context.Context) error { stopped = append(stopped, "database"); return nil })
+	m.OnShutdown("worker", func(context.Context) error { stopped = append(stopped, "worker"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(ctx, srv, ln) }()
+
+	respCode := make(chan int, 1)
+	go func() {
+		resp, reqErr := http.Get("http://" + ln.Addr().String()) //nolint:noctx // test request
+		if reqErr != nil {
+			respCode <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		respCode <- resp.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	if code := <-respCode; code != http.StatusNoContent {
+		t.Errorf("in-flight request returned %d; expected %d", code, http.StatusNoContent)
+	}
+
+	if err = <-runErr; err != nil {
+		t.Errorf("Run() returned error %v", err)
+	}
+
+	if !m.ShuttingDown() {
+		t.Error("ShuttingDown() returned false after shutdown")
+	}
+
+	if expected := []string{"worker", "database"}; !reflect.DeepEqual(stopped, expected) {
+		t.Errorf("hooks ran in order %v; expected %v", stopped, expected)
+	}
+}
+
+// TestRunJoinsHookErrors tests a failing hook doesn't stop the remaining ones and its error is returned.
+func TestRunJoinsHookErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, 0)
+
+	errBroken := errors.New("broken")
+	databaseClosed := false
+	m.OnShutdown("database", func(context.Context) error { databaseClosed = true; return nil })
+	m.OnShutdown("worker", func(context.Context) error { return errBroken })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err = m.Run(ctx, &http.Server{ReadHeaderTimeout: time.Second}, ln); !errors.Is(err, errBroken) {
+		t.Errorf("Run() returned %v; expected %v", err, errBroken)
+	}
+
+	if !databaseClosed {
+		t.Error("database hook didn't run after the worker hook failed")
+	}
+}
@@ -0,0 +1,198 @@
+// Package metrics exposes prometheus metrics for slot occupancy, park/unpark outcomes, fees,
+// http request latency and the database connection pool.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gopark"
+
+// Park and unpark outcomes.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFull     = "full"
+	OutcomeConflict = "conflict"
+	OutcomeError    = "error"
+)
+
+// occupancyTimeout bounds the occupancy query run on every scrape.
+const occupancyTimeout = 5 * time.Second
+
+// OccupancySource provides slot counts per parking lot, implemented by domain.ParkingLotRepository.
+type OccupancySource interface {
+	GetOccupancy(ctx context.Context) ([]domain.LotOccupancy, common.AppError)
+}
+
+// Metrics holds the application's collectors on its own registry. A nil *Metrics records nothing,
+// so handlers can be used without instrumentation, eg: in tests.
+type Metrics struct {
+	registry     *prometheus.Registry
+	parks        *prometheus.CounterVec
+	unparks      *prometheus.CounterVec
+	fees         *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+}
+
+// New registers go runtime, process, occupancy and request collectors on a new registry.
+// Slot gauges are read from occupancy on every scrape, so they never drift from storage.
+func New(occupancy OccupancySource, l *slog.Logger) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		parks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "park_requests_total",
+			Help:      "Park attempts by outcome (success, full, conflict, error).",
+		}, []string{"outcome"}),
+		unparks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "unpark_requests_total",
+			Help:      "Unpark attempts by outcome (success, conflict, error).",
+		}, []string{"outcome"}),
+		fees: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fees_collected_total",
+			Help:      "Fees charged on unpark, by currency.",
+		}, []string{"currency"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newOccupancyCollector(occupancy, l),
+		m.parks,
+		m.unparks,
+		m.fees,
+		m.httpDuration,
+	)
+
+	return m
+}
+
+// RegisterDBStats exposes sql.DB.Stats() of the connection pool, eg: open, in use and idle connections, wait counts.
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Handler serves the registry in the prometheus text format. A failing collector is reported in the logs
+// and its metrics are left out, the rest are still served.
+func (m *Metrics) Handler(l *slog.Logger) http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(l.Handler(), slog.LevelError),
+		ErrorHandling: promhttp.ContinueOnError,
+		Registry:      m.registry,
+	})
+}
+
+// Instrument records the latency of every request served by h under the path of its mux pattern,
+// eg: "/parking-lots/{id}/park" for "POST /parking-lots/{id}/park", keeping label cardinality bounded.
+func (m *Metrics) Instrument(pattern string, h http.Handler) http.Handler {
+	if m == nil {
+		return h
+	}
+
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+
+	return promhttp.InstrumentHandlerDuration(m.httpDuration.MustCurryWith(prometheus.Labels{"route": route}), h)
+}
+
+// ObservePark counts a park attempt by the outcome of its error, nil being a success.
+func (m *Metrics) ObservePark(appErr common.AppError) {
+	if m == nil {
+		return
+	}
+
+	m.parks.WithLabelValues(outcome(appErr)).Inc()
+}
+
+// ObserveUnpark counts an unpark attempt by the outcome of its error, adding the charged fee on success.
+func (m *Metrics) ObserveUnpark(v *domain.Vehicle, appErr common.AppError) {
+	if m == nil {
+		return
+	}
+
+	m.unparks.WithLabelValues(outcome(appErr)).Inc()
+	if appErr == nil && v != nil {
+		m.fees.WithLabelValues(v.Currency).Add(float64(v.Fee))
+	}
+}
+
+// outcome classifies a repository error: a full lot, any other 409 Conflict, or an error.
+func outcome(appErr common.AppError) string {
+	switch {
+	case appErr == nil:
+		return OutcomeSuccess
+	case appErr.Code() == http.StatusConflict && appErr.Error() == common.ErrParkingLotFull:
+		return OutcomeFull
+	case appErr.Code() == http.StatusConflict:
+		return OutcomeConflict
+	default:
+		return OutcomeError
+	}
+}
+
+// occupancyCollector reports per-lot slot gauges, queried from storage at scrape time.
+type occupancyCollector struct {
+	source      OccupancySource
+	l           *slog.Logger
+	occupied    *prometheus.Desc
+	available   *prometheus.Desc
+	maintenance *prometheus.Desc
+}
+
+func newOccupancyCollector(source OccupancySource, l *slog.Logger) *occupancyCollector {
+	labels := []string{"parking_lot_id", "parking_lot"}
+
+	return &occupancyCollector{
+		source:      source,
+		l:           l,
+		occupied:    prometheus.NewDesc(namespace+"_slots_occupied", "Slots with a parked vehicle.", labels, nil),
+		available:   prometheus.NewDesc(namespace+"_slots_available", "Free slots not under maintenance.", labels, nil),
+		maintenance: prometheus.NewDesc(namespace+"_slots_maintenance", "Slots under maintenance.", labels, nil),
+	}
+}
+
+func (c *occupancyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.occupied
+	ch <- c.available
+	ch <- c.maintenance
+}
+
+func (c *occupancyCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), occupancyTimeout)
+	defer cancel()
+
+	occupancy, appErr := c.source.GetOccupancy(ctx)
+	if appErr != nil {
+		c.l.Error("unable to collect occupancy metrics", "err", appErr)
+		ch <- prometheus.NewInvalidMetric(c.occupied, appErr)
+		return
+	}
+
+	for _, o := range occupancy {
+		id := o.ParkingLotID.String()
+		ch <- prometheus.MustNewConstMetric(c.occupied, prometheus.GaugeValue, float64(o.Occupied), id, o.Name)
+		ch <- prometheus.MustNewConstMetric(c.available, prometheus.GaugeValue, float64(o.Available), id, o.Name)
+		ch <- prometheus.MustNewConstMetric(c.maintenance, prometheus.GaugeValue, float64(o.Maintenance), id, o.Name)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/domain"
+)
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		appErr   common.AppError
+		expected string
+	}{
+		{nil, OutcomeSuccess},
+		{common.NewConflictError(common.ErrParkingLotFull), OutcomeFull},
+		{common.NewConflictError("vehicle with this registration number is already parked"), OutcomeConflict},
+		{common.NewNotFoundError(common.ErrUnexpectedDatabase), OutcomeError},
+		{common.NewInternalServerError(common.ErrUnexpectedDatabase, nil), OutcomeError},
+	}
+
+	for _, tt := range tests {
+		if actual := outcome(tt.appErr); actual != tt.expected {
+			t.Errorf("outcome(%v) = %s; expected %s", tt.appErr, actual, tt.expected)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := domain.NewMemoryStore()
+	lots := domain.NewParkingLotRepoMemory(store, logger)
+	vehicles := domain.NewVehicleRepoMemory(store, logger)
+
+	lot, appErr := lots.CreateParkingLot(context.Background(), &domain.ParkingLot{Name: "Parking Lot 1", DesiredSlots: 2})
+	if appErr != nil {
+		t.Fatalf("CreateParkingLot returned error %v", appErr)
+	}
+
+	m := New(lots, logger)
+
+	_, appErr = vehicles.ParkVehicle(context.Background(), lot.ID, "ABC-1", domain.ParkOptions{VehicleType: domain.VehicleTypeCar})
+	m.ObservePark(appErr)
+	m.ObservePark(common.NewConflictError(common.ErrParkingLotFull))
+	m.ObserveUnpark(&domain.Vehicle{Fee: 30, Currency: "USD"}, nil)
+	m.ObserveUnpark(&domain.Vehicle{Fee: 20, Currency: "USD"}, nil)
+
+	route := m.Instrument("POST /parking-lots/{id}/park", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	route.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/parking-lots/1/park", nil))
+
+	rec := httptest.NewRecorder()
+	m.Handler(logger).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`gopark_slots_occupied{parking_lot="Parking Lot 1",parking_lot_id="` + lot.ID.String() + `"} 1`,
+		`gopark_slots_available{parking_lot="Parking Lot 1",parking_lot_id="` + lot.ID.String() + `"} 1`,
+		`gopark_slots_maintenance{parking_lot="Parking Lot 1",parking_lot_id="` + lot.ID.String() + `"} 0`,
+		`gopark_park_requests_total{outcome="success"} 1`,
+		`gopark_park_requests_total{outcome="full"} 1`,
+		`gopark_unpark_requests_total{outcome="success"} 2`,
+		`gopark_fees_collected_total{currency="USD"} 50`,
+		`gopark_http_request_duration_seconds_count{code="409",method="post",route="/parking-lots/{id}/park"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics output is missing %s", line)
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	m.ObservePark(nil)
+	m.ObserveUnpark(nil, common.NewConflictError("vehicle not found or already unparked"))
+
+	h := http.NotFoundHandler()
+	if m.Instrument("GET /", h) == nil {
+		t.Error("Instrument on nil metrics returned nil; expected the handler unchanged")
+	}
+}
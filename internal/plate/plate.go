@@ -0,0 +1,139 @@
+// Package plate canonicalizes and validates vehicle registration numbers.
+//
+// Plates are compared by their canonical form: NFKC normalized, upper case, letters and digits only, with Cyrillic and Greek
+// letters that look like Latin ones mapped to them, eg: "abc-123", "ABC 123", "ＡＢＣ１２３" and "АВС123" (Cyrillic) are all ABC123.
+package plate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the longest canonical plate accepted, longer than any country issues.
+const MaxLength = 15
+
+// ErrEmpty is returned for plates without a single letter or digit.
+var ErrEmpty = errors.New("registration number can't be empty")
+
+// confusables maps Cyrillic and Greek capitals to the Latin letter they can't be told apart from on a plate.
+var confusables = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X',
+	'І': 'I', 'Ј': 'J', 'Ѕ': 'S',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T',
+	'Υ': 'Y', 'Χ': 'X',
+}
+
+// Plate is a registration number as entered and its canonical form, the one every lookup uses.
+type Plate struct {
+	Raw       string
+	Canonical string
+	Country   string // the country whose pattern matched, empty without patterns
+}
+
+// Canonicalize returns the canonical form of a plate. Whitespace, separators and punctuation are dropped,
+// letters and digits are kept, so the result may still contain letters outside A-Z, see Rules.Parse.
+func Canonicalize(raw string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(raw) {
+		r = unicode.ToUpper(r)
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Rules validates canonical plates against per-country patterns, eg: {"GB": "[A-Z]{2}[0-9]{2}[A-Z]{3}"}.
+// Patterns match the whole canonical plate. Without patterns every plate of A-Z and 0-9 up to MaxLength is valid.
+type Rules struct {
+	patterns  map[string]*regexp.Regexp
+	countries []string // sorted, the order patterns are tried in
+}
+
+// NewRules compiles the patterns by country code, country codes are upper cased.
+func NewRules(patterns map[string]string) (*Rules, error) {
+	rules := &Rules{patterns: make(map[string]*regexp.Regexp, len(patterns))}
+
+	var errs []error
+	for country, pattern := range patterns {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			errs = append(errs, errors.New("plate pattern without a country"))
+			continue
+		}
+
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plate pattern of %s: %w", country, err))
+			continue
+		}
+
+		rules.patterns[country] = re
+		rules.countries = append(rules.countries, country)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(rules.countries)
+	return rules, nil
+}
+
+// Countries lists the countries with a pattern, sorted.
+func (r *Rules) Countries() []string {
+	return slices.Clone(r.countries)
+}
+
+// Parse canonicalizes a plate and validates it against the pattern of country, or of any country when country is empty.
+func (r *Rules) Parse(raw, country string) (Plate, error) {
+	p := Plate{Raw: strings.TrimSpace(raw), Canonical: Canonicalize(raw)}
+
+	switch {
+	case p.Canonical == "":
+		return Plate{}, ErrEmpty
+	case strings.IndexFunc(p.Canonical, func(c rune) bool { return (c < 'A' || c > 'Z') && (c < '0' || c > '9') }) >= 0:
+		return Plate{}, fmt.Errorf("registration number %q may only contain latin letters and digits", raw)
+	case len(p.Canonical) > MaxLength: // the canonical plate is ASCII here, bytes are letters and digits
+		return Plate{}, fmt.Errorf("registration number %q is longer than %d letters and digits", raw, MaxLength)
+	}
+
+	if country != "" {
+		country = strings.ToUpper(country)
+		re, ok := r.patterns[country]
+		if !ok {
+			return Plate{}, fmt.Errorf("plate country must be one of %v, got %q", r.countries, country)
+		}
+
+		if !re.MatchString(p.Canonical) {
+			return Plate{}, fmt.Errorf("registration number %q isn't a valid %s plate", raw, country)
+		}
+
+		p.Country = country
+		return p, nil
+	}
+
+	if len(r.countries) == 0 {
+		return p, nil
+	}
+
+	for _, c := range r.countries {
+		if r.patterns[c].MatchString(p.Canonical) {
+			p.Country = c
+			return p, nil
+		}
+	}
+
+	return Plate{}, fmt.Errorf("registration number %q doesn't match the plates of %v", raw, r.countries)
+}
@@ -0,0 +1,90 @@
+package plate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{"ABC123", "ABC123"},
+		{"abc-123", "ABC123"},
+		{" ABC 123 ", "ABC123"},
+		{"ab.c_12/3", "ABC123"},
+		{"ＡＢＣ－１２３", "ABC123"}, // fullwidth
+		{"АВС-123", "ABC123"}, // Cyrillic A, Ve and Es
+		{"ΑΒΕ 123", "ABE123"}, // Greek Alpha, Beta and Epsilon
+		{"abc\t123\n", "ABC123"},
+		{"--", ""},
+	}
+
+	for _, tt := range tests {
+		if actual := Canonicalize(tt.raw); actual != tt.expected {
+			t.Errorf("Canonicalize(%q) = %q; expected %q", tt.raw, actual, tt.expected)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	rules, err := NewRules(map[string]string{"gb": "[A-Z]{2}[0-9]{2}[A-Z]{3}", "NL": "[A-Z0-9]{6}"})
+	if err != nil {
+		t.Fatalf("NewRules returned error %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		country  string
+		expected Plate
+		err      string
+	}{
+		{"country pattern", "ab12 cde", "GB", Plate{Raw: "ab12 cde", Canonical: "AB12CDE", Country: "GB"}, ""},
+		{"country code case", "AB12CDE", "gb", Plate{Raw: "AB12CDE", Canonical: "AB12CDE", Country: "GB"}, ""},
+		{"any country", "12-AB-34", "", Plate{Raw: "12-AB-34", Canonical: "12AB34", Country: "NL"}, ""},
+		{"wrong country", "12-AB-34", "GB", Plate{}, "isn't a valid GB plate"},
+		{"unknown country", "AB12CDE", "FR", Plate{}, "plate country must be one of [GB NL]"},
+		{"no country matches", "A1", "", Plate{}, "doesn't match"},
+		{"empty", " - ", "", Plate{}, ErrEmpty.Error()},
+		{"too long", strings.Repeat("A", MaxLength+1), "", Plate{}, "longer than"},
+		{"non latin letters", "ЖЖ12CDE", "", Plate{}, "latin letters and digits"},
+		{"non latin letters under the length limit", "ЖЖЖЖЖЖЖЖ", "", Plate{}, "latin letters and digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := rules.Parse(tt.raw, tt.country)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Parse(%q, %q) returned error %v; expected it to mention %q", tt.raw, tt.country, err, tt.err)
+				}
+
+				return
+			}
+
+			if err != nil || p != tt.expected {
+				t.Errorf("Parse(%q, %q) = %+v, %v; expected %+v", tt.raw, tt.country, p, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseWithoutPatterns(t *testing.T) {
+	rules, err := NewRules(nil)
+	if err != nil {
+		t.Fatalf("NewRules returned error %v", err)
+	}
+
+	if p, err := rules.Parse("abc-123", ""); err != nil || p.Canonical != "ABC123" || p.Country != "" {
+		t.Errorf("Parse returned %+v, %v; expected ABC123 without a country", p, err)
+	}
+}
+
+func TestNewRulesInvalid(t *testing.T) {
+	_, err := NewRules(map[string]string{"GB": "[A-Z", "": "[0-9]+"})
+	if err == nil || !strings.Contains(err.Error(), "plate pattern of GB") || !strings.Contains(err.Error(), "without a country") {
+		t.Errorf("NewRules returned error %v; expected both problems reported", err)
+	}
+}
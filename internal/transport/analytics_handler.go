@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+type AnalyticsHandler struct {
+	Repo   domain.AnalyticsRepository
+	Logger *slog.Logger
+}
+
+// GetOccupancyAnalytics handles HTTP requests for the occupancy timeline and utilization of a parking lot over a period.
+func (h *AnalyticsHandler) GetOccupancyAnalytics(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	q, err := parseAnalyticsQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	analytics, appErr := h.Repo.GetOccupancyAnalytics(r.Context(), plUUID, q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		analytics.InTimeZone()
+	}
+
+	writeResponse(w, http.StatusOK, analytics)
+}
+
+// parseAnalyticsQuery reads the from and to query parameters, dates or RFC 3339 times like ranged reports.
+func parseAnalyticsQuery(r *http.Request) (domain.AnalyticsQuery, error) {
+	params := r.URL.Query()
+	var q domain.AnalyticsQuery
+
+	var err error
+	if q.From, q.FromDate, err = parseReportTime(params.Get("from")); err != nil {
+		return q, errors.New("invalid from, expected YYYY-MM-DD or RFC 3339")
+	}
+
+	if q.To, q.ToDate, err = parseReportTime(params.Get("to")); err != nil {
+		return q, errors.New("invalid to, expected YYYY-MM-DD or RFC 3339")
+	}
+
+	return q, nil
+}
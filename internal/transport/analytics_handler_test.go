@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestOccupancyAnalytics tests analytics query validation and the occupancy of a vehicle parked now.
+func TestOccupancyAnalytics(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	today := time.Now().UTC()
+	period := "?from=" + today.Format("2006-01-02") + "&to=" + today.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var analytics domain.OccupancyAnalytics
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/analytics"+period, nil), &analytics)
+	if len(analytics.Hourly) != 24 || len(analytics.HourOfDay) != 24 || len(analytics.Slots) != 2 || analytics.Capacity != 2 ||
+		analytics.VehiclesParked != 1 || analytics.Slots[0].Turnover != 1 {
+		t.Errorf("GetOccupancyAnalytics returned %+v; expected 24 hours with ABC-123 parked in slot 1", analytics)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"missing period", lotPath + "/analytics", http.StatusBadRequest},
+		{"invalid to", lotPath + "/analytics?from=2024-03-13&to=tomorrow", http.StatusBadRequest},
+		{"to before from", lotPath + "/analytics?from=2024-03-14&to=2024-03-13", http.StatusBadRequest},
+		{"period too long", lotPath + "/analytics?from=2024-01-01&to=2024-03-01", http.StatusBadRequest},
+		{"invalid parking lot ID", "/parking-lots/invalid/analytics?from=2024-03-13&to=2024-03-14", http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/analytics?from=2024-03-13&to=2024-03-14", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodGet, tt.path, nil); rec.Code != tt.expected {
+				t.Errorf("GET %s returned %d; expected %d", tt.path, rec.Code, tt.expected)
+			}
+		})
+	}
+}
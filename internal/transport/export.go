@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/export"
+)
+
+// exportFormat negotiates the format of a report or sessions response from the format query parameter or the Accept header,
+// writing a 400 Bad Request response for an unknown format.
+func exportFormat(w http.ResponseWriter, r *http.Request) (export.Format, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	format, err := export.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return "", false
+	}
+
+	return format, true
+}
+
+// exportStatusTrailer is the trailer of every download, "complete" once all of its rows are written.
+const exportStatusTrailer = "X-Export-Status"
+
+// startExport writes the headers of a download of filename in format, and returns the writer of its rows in a sheet named sheet.
+func startExport(w http.ResponseWriter, format export.Format, filename, sheet string) export.Writer {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+"."+string(format)+`"`)
+	w.Header().Set("Trailer", exportStatusTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	return export.NewWriter(format, w, sheet)
+}
+
+// finishExport closes a started download unless writing its rows failed with err, and sets its status trailer.
+// The 200 status is already sent on failure, so the response is aborted with http.ErrAbortHandler: the client gets
+// a broken download without the trailer rather than a truncated file that looks complete.
+func finishExport(w http.ResponseWriter, l *slog.Logger, ew export.Writer, filename string, err error) {
+	if err == nil {
+		err = ew.Close()
+	}
+
+	if err != nil {
+		l.Error("unable to write export", "filename", filename, "err", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Set(exportStatusTrailer, "complete")
+}
+
+// writeExport writes a download of rows, the first one being the column names.
+func writeExport(w http.ResponseWriter, l *slog.Logger, format export.Format, filename, sheet string, rows [][]any) {
+	ew := startExport(w, format, filename, sheet)
+
+	var err error
+	for _, row := range rows {
+		if err = ew.WriteRow(row...); err != nil {
+			break
+		}
+	}
+
+	finishExport(w, l, ew, filename, err)
+}
+
+// dailyReportRows returns the rows of a daily report export, the totals then the permit and transient usage.
+func dailyReportRows(report *domain.DailyReport) [][]any {
+	return [][]any{
+		{"Stays", "Vehicles Parked", "Parking Hours", "Fee Collected"},
+		{"total", report.TotalVehiclesParked, report.TotalParkingHours, report.TotalFeeCollected},
+		{"permit", report.Permit.VehiclesParked, report.Permit.ParkingHours, report.Permit.FeeCollected},
+		{"transient", report.Transient.VehiclesParked, report.Transient.ParkingHours, report.Transient.FeeCollected},
+	}
+}
+
+var reportColumns = []any{
+	"Row", "Parking Lot ID", "Parking Lot", "Start", "End", "Vehicles Parked", "Parking Hours", "Fee Collected", "Average Stay Seconds", "Peak Occupancy",
+}
+
+// rangeReportRows returns the rows of a ranged report export, a bucket row for each bucket then a total row for the period.
+func rangeReportRows(report *domain.RangeReport) [][]any {
+	rows := [][]any{reportColumns}
+	for _, b := range report.Buckets {
+		rows = append(rows, reportRow("bucket", report.ParkingLotID.String(), "", b))
+	}
+
+	return append(rows, reportRow("total", report.ParkingLotID.String(), "", report.Total))
+}
+
+// portfolioReportRows returns the rows of a portfolio report export, the combined buckets and total, then a lot row with the
+// total of each lot.
+func portfolioReportRows(report *domain.PortfolioReport) [][]any {
+	rows := [][]any{reportColumns}
+	for _, b := range report.Buckets {
+		rows = append(rows, reportRow("bucket", "", "", b))
+	}
+
+	rows = append(rows, reportRow("total", "", "", report.Total))
+	for _, lot := range report.Lots {
+		rows = append(rows, reportRow("lot", lot.ParkingLotID.String(), lot.Name, lot.Total))
+	}
+
+	return rows
+}
+
+func reportRow(kind, lotID, lotName string, b domain.ReportBucket) []any {
+	return []any{kind, lotID, lotName, b.Start, b.End, b.VehiclesParked, b.ParkingHours, b.FeeCollected, b.AverageStaySeconds, b.PeakOccupancy}
+}
+
+var sessionColumns = []any{
+	"Session ID", "Parking Lot ID", "Time Zone", "Registration Number", "Raw Registration Number", "Vehicle Type", "Slot ID", "Slot Number",
+	"Slot Label", "Parked At", "Unparked At", "Duration Seconds", "Fee", "Currency", "Permit ID",
+}
+
+func sessionRow(s *domain.Session) []any {
+	permitID := ""
+	if s.PermitID != nil {
+		permitID = s.PermitID.String()
+	}
+
+	return []any{
+		s.ID.String(), s.ParkingLotID.String(), s.TimeZone, s.RegistrationNumber, s.RawRegistrationNumber, string(s.VehicleType), s.SlotID.String(),
+		s.SlotNumber, s.SlotLabel, s.ParkedAt, s.UnparkedAt, s.DurationSeconds, s.Fee, s.Currency, permitID,
+	}
+}
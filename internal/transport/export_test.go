@@ -0,0 +1,145 @@
+package transport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/export"
+	"github.com/google/uuid"
+)
+
+// TestExport tests reports and sessions negotiate CSV and XLSX downloads, and sessions exports stream past the page limit.
+func TestExport(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-124"})
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+
+	today := time.Now().UTC()
+	date := today.Format("2006-01-02")
+	period := "from=" + date + "&to=" + today.AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		filename    string
+		rows        int
+		firstColumn string
+	}{
+		{"daily report", lotPath + "/reports/" + date + "?format=csv", "", "report-" + date + ".csv", 4, "Stays"},
+		{"ranged report", lotPath + "/reports?" + period, "text/csv", "report.csv", 3, "Row"},
+		{"portfolio report", "/reports?" + period, "application/json;q=0.5, text/csv", "portfolio-report.csv", 4, "Row"},
+		{"lot sessions past the limit", lotPath + "/sessions?limit=1", "text/csv", "sessions.csv", 3, "Session ID"},
+		{"vehicle sessions", "/vehicles/abc-124/sessions?format=csv", "", "sessions.csv", 2, "Session ID"},
+		{"unknown vehicle sessions", "/vehicles/XYZ-1/sessions?format=csv", "", "sessions.csv", 1, "Session ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doExportRequest(router, tt.target, tt.accept)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != export.FormatCSV.ContentType() ||
+				rec.Header().Get("Content-Disposition") != `attachment; filename="`+tt.filename+`"` {
+				t.Fatalf("GET %s returned %d with headers %v; expected a CSV download of %s", tt.target, rec.Code, rec.Header(), tt.filename)
+			}
+
+			if status := rec.Result().Trailer.Get("X-Export-Status"); status != "complete" {
+				t.Errorf("GET %s returned export status %q; expected complete", tt.target, status)
+			}
+
+			rows, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil || len(rows) != tt.rows || rows[0][0] != tt.firstColumn {
+				t.Errorf("GET %s returned rows %v, %v; expected %d rows starting with %s", tt.target, rows, err, tt.rows, tt.firstColumn)
+			}
+		})
+	}
+
+	rec := doExportRequest(router, lotPath+"/sessions", export.MediaTypeXLSX)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != export.MediaTypeXLSX {
+		t.Fatalf("GET %s/sessions returned %d with headers %v; expected an XLSX download", lotPath, rec.Code, rec.Header())
+	}
+
+	if _, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len())); err != nil {
+		t.Errorf("GET %s/sessions returned an invalid spreadsheet: %v", lotPath, err)
+	}
+
+	errorTests := []struct {
+		name     string
+		target   string
+		expected int
+	}{
+		{"unknown format", lotPath + "/reports/" + date + "?format=pdf", http.StatusBadRequest},
+		{"unknown parking lot report", "/parking-lots/" + uuid.NewString() + "/reports/" + date + "?format=csv", http.StatusNotFound},
+		{"unknown parking lot sessions", "/parking-lots/" + uuid.NewString() + "/sessions?format=xlsx", http.StatusNotFound},
+		{"invalid sessions order", lotPath + "/sessions?format=csv&order=up", http.StatusBadRequest},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doExportRequest(router, tt.target, "")
+			if rec.Code != tt.expected || rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("GET %s returned %d with %s; expected a JSON error %d", tt.target, rec.Code, rec.Header().Get("Content-Type"), tt.expected)
+			}
+		})
+	}
+}
+
+// failingSessionRepo streams a session then fails, like a database connection lost during an export.
+type failingSessionRepo struct {
+	domain.SessionRepository
+}
+
+func (failingSessionRepo) StreamLotSessions(_ context.Context, _ uuid.UUID, _ domain.SessionQuery, fn func(*domain.Session) error) common.AppError {
+	if err := fn(&domain.Session{ID: uuid.New()}); err != nil {
+		return common.NewInternalServerError("unable to stream sessions", err)
+	}
+
+	return common.NewInternalServerError(common.ErrUnexpectedDatabase, errors.New("connection reset"))
+}
+
+// TestExportFailsPartway tests a sessions export failing after its first row breaks the download instead of completing it.
+func TestExportFailsPartway(t *testing.T) {
+	handler := SessionHandler{Repo: failingSessionRepo{}, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	router := http.NewServeMux()
+	router.HandleFunc("GET /parking-lots/{id}/sessions", handler.ListLotSessions)
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	// the connection is aborted, before the headers if they were still buffered, otherwise during the body.
+	res, err := http.Get(srv.URL + "/parking-lots/" + uuid.NewString() + "/sessions?format=csv")
+	if err == nil {
+		defer res.Body.Close()
+		if _, err = io.ReadAll(res.Body); err == nil {
+			t.Errorf("GET sessions returned %d with export status %q; expected a broken download", res.StatusCode,
+				res.Trailer.Get("X-Export-Status"))
+		}
+	}
+}
+
+// doExportRequest serves a GET request with an Accept header.
+func doExportRequest(h http.Handler, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
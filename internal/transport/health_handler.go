@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds every readiness check, a hanging dependency must not hang the probe.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck checks a single dependency, returning details for the response and an error if it's unusable.
+type HealthCheck func(ctx context.Context) (details any, err error)
+
+// CheckResult is the readiness outcome of a single dependency.
+type CheckResult struct {
+	Status  string `json:"status"`
+	Details any    `json:"details,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReadinessResponse reports overall readiness with a breakdown per dependency.
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthHandler serves liveness and readiness. Starting reports whether startup, eg: applying migrations,
+// is still running while the server already listens.
+type HealthHandler struct {
+	Checks       map[string]HealthCheck
+	Starting     func() bool
+	ShuttingDown func() bool
+	Logger       *slog.Logger
+}
+
+// Liveness handles GET /healthz, the process is alive as long as it can answer.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, _ *http.Request) {
+	writeResponse(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// Readiness handles GET /readyz, runs every dependency check concurrently and returns 503 Service Unavailable
+// if any of them fails, migrations are pending or the app is shutting down, so load balancers stop routing traffic to it.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	resp := ReadinessResponse{Status: "ready", Checks: make(map[string]CheckResult, len(h.Checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+
+			details, err := check(ctx)
+			result := CheckResult{Status: "up", Details: details}
+			if err != nil {
+				h.Logger.Warn("readiness check failed", "check", name, "err", err)
+				result.Status, result.Error = "down", err.Error()
+			}
+
+			mu.Lock()
+			resp.Checks[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, result := range resp.Checks {
+		if result.Status != "up" {
+			resp.Status, code = "not ready", http.StatusServiceUnavailable
+		}
+	}
+
+	if h.Starting != nil && h.Starting() {
+		resp.Status, code = "migrations pending", http.StatusServiceUnavailable
+	}
+
+	if h.ShuttingDown != nil && h.ShuttingDown() {
+		resp.Status, code = "shutting down", http.StatusServiceUnavailable
+	}
+
+	writeResponse(w, code, resp)
+}
+
+// StartupGate answers 503 Service Unavailable to every request but the health checks while the app is starting,
+// so nothing reaches a schema that migrations haven't brought up to date yet.
+func (h *HealthHandler) StartupGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Starting != nil && h.Starting() && r.URL.Path != "/healthz" && r.URL.Path != "/readyz" {
+			w.Header().Set("Retry-After", "1")
+			writeResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "migrations pending, try again shortly"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
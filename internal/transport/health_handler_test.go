@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/lifecycle"
+)
+
+// TestReadiness tests readiness is 503 when a dependency check fails, migrations are pending or the app is shutting down,
+// with a result for every dependency.
+func TestReadiness(t *testing.T) {
+	up := func(context.Context) (any, error) { return map[string]int{"current": 4}, nil }
+	down := func(context.Context) (any, error) { return nil, errors.New("connection refused") }
+
+	tests := []struct {
+		name           string
+		checks         map[string]HealthCheck
+		starting       bool
+		shuttingDown   bool
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"no dependencies", map[string]HealthCheck{}, false, false, http.StatusOK, "ready"},
+		{"all up", map[string]HealthCheck{"database": up, "migrations": up}, false, false, http.StatusOK, "ready"},
+		{"dependency down", map[string]HealthCheck{"database": down, "migrations": up}, false, false, http.StatusServiceUnavailable, "not ready"},
+		{"migrations pending", map[string]HealthCheck{"database": up}, true, false, http.StatusServiceUnavailable, "migrations pending"},
+		{"shutting down", map[string]HealthCheck{"database": up}, false, true, http.StatusServiceUnavailable, "shutting down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HealthHandler{
+				Checks:       tt.checks,
+				Starting:     func() bool { return tt.starting },
+				ShuttingDown: func() bool { return tt.shuttingDown },
+				Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			router := http.NewServeMux()
+			router.HandleFunc("GET /readyz", h.Readiness)
+
+			rec := doRequest(t, router, http.MethodGet, "/readyz", nil)
+			if rec.Code != tt.expectedCode {
+				t.Errorf("Readiness returned %d; expected %d", rec.Code, tt.expectedCode)
+			}
+
+			var resp ReadinessResponse
+			decodeResponse(t, rec, &resp)
+			if resp.Status != tt.expectedStatus || len(resp.Checks) != len(tt.checks) {
+				t.Errorf("Readiness returned %+v; expected status %q with %d checks", resp, tt.expectedStatus, len(tt.checks))
+			}
+
+			if result, ok := resp.Checks["database"]; ok && tt.name == "dependency down" &&
+				(result.Status != "down" || result.Error != "connection refused") {
+				t.Errorf("Readiness returned database check %+v; expected down with its error", result)
+			}
+		})
+	}
+}
+
+// TestLiveness tests liveness always answers 200.
+func TestLiveness(t *testing.T) {
+	h := HealthHandler{ShuttingDown: func() bool { return true }}
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /healthz", h.Liveness)
+
+	if rec := doRequest(t, router, http.MethodGet, "/healthz", nil); rec.Code != http.StatusOK {
+		t.Errorf("Liveness returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+}
+
+// TestReadinessBeforeMigrations tests a server started before its migrations finish answers /readyz with 503
+// and refuses other routes, while /healthz answers 200, then serves everything once startup is marked done.
+func TestReadinessBeforeMigrations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app := lifecycle.NewManager(logger, time.Second, 0)
+	h := HealthHandler{Checks: map[string]HealthCheck{}, Starting: app.Starting, ShuttingDown: app.ShuttingDown, Logger: logger}
+
+	router := newTestRouter()
+	router.HandleFunc("GET /healthz", h.Liveness)
+	router.HandleFunc("GET /readyz", h.Readiness)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.Start(ctx, &http.Server{Handler: h.StartupGate(router), ReadHeaderTimeout: time.Second}, ln)
+	defer func() {
+		cancel()
+		if err = app.Wait(); err != nil {
+			t.Errorf("Wait() returned error %v", err)
+		}
+	}()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+
+		resp, reqErr := http.Get("http://" + ln.Addr().String() + path) //nolint:noctx // test request
+		if reqErr != nil {
+			t.Fatalf("GET %s: %v", path, reqErr)
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Status
+	}
+
+	if code, status := get("/readyz"); code != http.StatusServiceUnavailable || status != "migrations pending" {
+		t.Errorf("/readyz before migrations returned %d %q; expected %d %q", code, status, http.StatusServiceUnavailable, "migrations pending")
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz before migrations returned %d; expected %d", code, http.StatusOK)
+	}
+
+	if code, _ := get("/reports?from=2024-01-01&to=2024-01-02"); code != http.StatusServiceUnavailable {
+		t.Errorf("/reports before migrations returned %d; expected %d", code, http.StatusServiceUnavailable)
+	}
+
+	app.MarkStarted()
+
+	if code, status := get("/readyz"); code != http.StatusOK || status != "ready" {
+		t.Errorf("/readyz after migrations returned %d %q; expected %d %q", code, status, http.StatusOK, "ready")
+	}
+
+	if code, _ := get("/reports?from=2024-01-01&to=2024-01-02"); code != http.StatusOK {
+		t.Errorf("/reports after migrations returned %d; expected %d", code, http.StatusOK)
+	}
+}
+
+// TestReadinessDuringShutdownDelay tests /readyz answers 503 while a shutting down server waits out its readiness delay,
+// and /healthz still answers 200.
+func TestReadinessDuringShutdownDelay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app := lifecycle.NewManager(logger, time.Second, 500*time.Millisecond)
+	app.MarkStarted()
+	h := HealthHandler{Checks: map[string]HealthCheck{}, Starting: app.Starting, ShuttingDown: app.ShuttingDown, Logger: logger}
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /healthz", h.Liveness)
+	router.HandleFunc("GET /readyz", h.Readiness)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.Start(ctx, &http.Server{Handler: router, ReadHeaderTimeout: time.Second}, ln)
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- app.Wait() }()
+	cancel()
+
+	for deadline := time.Now().Add(time.Second); !app.ShuttingDown(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("ShuttingDown() still false after the shutdown signal")
+		}
+	}
+
+	get := func(path string) int {
+		t.Helper()
+
+		resp, reqErr := http.Get("http://" + ln.Addr().String() + path) //nolint:noctx // test request
+		if reqErr != nil {
+			t.Fatalf("GET %s during the readiness delay: %v", path, reqErr)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz during the readiness delay returned %d; expected %d", code, http.StatusServiceUnavailable)
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz during the readiness delay returned %d; expected %d", code, http.StatusOK)
+	}
+
+	if err = <-waitErr; err != nil {
+		t.Errorf("Wait() returned error %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package transport
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/ashtishad/gopark/internal/plate"
 )
 
 // writeResponse helper function for writing JSON responses to the http.ResponseWriter
@@ -16,3 +18,25 @@ func writeResponse(w http.ResponseWriter, code int, data any) {
 		http.Error(w, "binding error message failed", http.StatusInternalServerError)
 	}
 }
+
+// parsePlates validates registration numbers against the plate rules of country, any country when empty,
+// writing a 400 Bad Request response for the first invalid one. Returns the plates as entered, trimmed.
+func parsePlates(w http.ResponseWriter, rules *plate.Rules, country string, raws ...string) ([]string, bool) {
+	plates := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		p, err := rules.Parse(raw, country)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return nil, false
+		}
+
+		plates = append(plates, p.Raw)
+	}
+
+	return plates, true
+}
+
+// wantsLocalTime reports whether the localTime query parameter asks for timestamps in the parking lot's time zone instead of UTC.
+func wantsLocalTime(r *http.Request) bool {
+	return r.URL.Query().Get("localTime") == "true"
+}
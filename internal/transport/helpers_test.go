@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/plate"
+)
+
+// newTestRouter wires handlers to in-memory repositories with the same routes as main.go, accepting plates of any country.
+func newTestRouter() *http.ServeMux {
+	return newTestRouterWithPlates(nil)
+}
+
+// newTestRouterWithPlates is newTestRouter validating registration numbers against the plate patterns by country.
+func newTestRouterWithPlates(patterns map[string]string) *http.ServeMux {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := domain.NewMemoryStore()
+
+	plates, err := plate.NewRules(patterns)
+	if err != nil {
+		panic(err)
+	}
+
+	parkingLotHandler := ParkingLotHandler{Repo: domain.NewParkingLotRepoMemory(store, logger), Logger: logger}
+	vehicleHandler := VehicleHandler{Repo: domain.NewVehicleRepoMemory(store, logger), Plates: plates, Logger: logger}
+	reservationHandler := ReservationHandler{Repo: domain.NewReservationRepoMemory(store, logger), Plates: plates, Logger: logger}
+	permitHandler := PermitHandler{Repo: domain.NewPermitRepoMemory(store, logger), Plates: plates, Logger: logger}
+	sessionHandler := SessionHandler{Repo: domain.NewSessionRepoMemory(store, logger), Logger: logger}
+	analyticsHandler := AnalyticsHandler{Repo: domain.NewAnalyticsRepoMemory(store, logger), Logger: logger}
+
+	router := http.NewServeMux()
+	router.HandleFunc("POST /parking-lots", parkingLotHandler.CreateParkingLot)
+	router.HandleFunc("GET /parking-lots/{id}/status", parkingLotHandler.GetParkingLotStatus)
+	router.HandleFunc("GET /parking-lots/{id}/reports/{date}", parkingLotHandler.GetDailyReport)
+	router.HandleFunc("GET /parking-lots/{id}/reports", parkingLotHandler.GetRangeReport)
+	router.HandleFunc("GET /reports", parkingLotHandler.GetPortfolioReport)
+	router.HandleFunc("GET /parking-lots/{id}/analytics", analyticsHandler.GetOccupancyAnalytics)
+	router.HandleFunc("PUT /parking-lots/{id}/slots/{slotId}/maintenance", parkingLotHandler.SetSlotMaintenance)
+	router.HandleFunc("GET /parking-lots/{id}/maintenance", parkingLotHandler.ListMaintenanceWindows)
+	router.HandleFunc("GET /parking-lots/{id}/pricing", parkingLotHandler.GetPricingPolicy)
+	router.HandleFunc("PUT /parking-lots/{id}/pricing", parkingLotHandler.SetPricingPolicy)
+	router.HandleFunc("GET /parking-lots/{id}/allocation", parkingLotHandler.GetAllocationStrategy)
+	router.HandleFunc("PUT /parking-lots/{id}/allocation", parkingLotHandler.SetAllocationStrategy)
+	router.HandleFunc("POST /parking-lots/{id}/park", vehicleHandler.Park)
+	router.HandleFunc("POST /parking-lots/{id}/unpark", vehicleHandler.Unpark)
+	router.HandleFunc("GET /parking-lots/{id}/vehicles/{registrationNumber}/quote", vehicleHandler.QuoteFee)
+	router.HandleFunc("POST /parking-lots/{id}/reservations", reservationHandler.CreateReservation)
+	router.HandleFunc("GET /parking-lots/{id}/reservations/{reservationId}", reservationHandler.GetReservation)
+	router.HandleFunc("DELETE /parking-lots/{id}/reservations/{reservationId}", reservationHandler.CancelReservation)
+	router.HandleFunc("POST /parking-lots/{id}/permits", permitHandler.CreatePermit)
+	router.HandleFunc("GET /parking-lots/{id}/permits", permitHandler.ListPermits)
+	router.HandleFunc("DELETE /parking-lots/{id}/permits/{permitId}", permitHandler.RevokePermit)
+	router.HandleFunc("GET /parking-lots/{id}/sessions", sessionHandler.ListLotSessions)
+	router.HandleFunc("GET /vehicles/{registrationNumber}/sessions", sessionHandler.ListVehicleSessions)
+	router.HandleFunc("GET /vehicles/{registrationNumber}/current", vehicleHandler.GetCurrentLocation)
+
+	return router
+}
+
+// doRequest serves a request with a JSON body, a string body is sent as is to test malformed payloads.
+func doRequest(t *testing.T, h http.Handler, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload []byte
+	switch b := body.(type) {
+	case nil:
+	case string:
+		payload = []byte(b)
+	default:
+		var err error
+		if payload, err = json.Marshal(b); err != nil {
+			t.Fatalf("marshaling request body: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewReader(payload)))
+
+	return rec
+}
+
+// decodeResponse decodes a JSON response body into v.
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decoding response body %q: %v", rec.Body.String(), err)
+	}
+}
+
+// createTestLot creates a parking lot through the API and returns it.
+func createTestLot(t *testing.T, h http.Handler, name string, slots int) domain.ParkingLot {
+	t.Helper()
+
+	rec := doRequest(t, h, http.MethodPost, "/parking-lots", map[string]any{"name": name, "desiredSlots": slots})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating parking lot returned %d; expected %d", rec.Code, http.StatusCreated)
+	}
+
+	var lot domain.ParkingLot
+	decodeResponse(t, rec, &lot)
+
+	return lot
+}
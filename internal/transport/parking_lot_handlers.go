@@ -2,16 +2,20 @@ package transport
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/ashtishad/gopark/internal/common"
 	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/export"
 	"github.com/google/uuid"
 )
 
 type ParkingLotHandler struct {
-	Repo   *domain.ParkingLotRepoDB
+	Repo   domain.ParkingLotRepository
 	Logger *slog.Logger
 }
 
@@ -50,6 +54,10 @@ func (h *ParkingLotHandler) GetParkingLotStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	if wantsLocalTime(r) {
+		status.InTimeZone()
+	}
+
 	writeResponse(w, http.StatusOK, status)
 }
 
@@ -67,11 +75,307 @@ func (h *ParkingLotHandler) GetDailyReport(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
 	report, appErr := h.Repo.GetDailyReport(r.Context(), plUUID, reportDate)
 	if appErr != nil {
 		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
 		return
 	}
 
+	if format != export.FormatJSON {
+		writeExport(w, h.Logger, format, "report-"+reportDate.Format("2006-01-02"), "Daily Report", dailyReportRows(report))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, report)
+}
+
+// GetRangeReport handles HTTP requests for the usage of a parking lot over a period, bucketed by hour, day, week or month.
+func (h *ParkingLotHandler) GetRangeReport(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	q, err := parseReportQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	report, appErr := h.Repo.GetRangeReport(r.Context(), plUUID, q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		report.InTimeZone()
+	}
+
+	if format != export.FormatJSON {
+		writeExport(w, h.Logger, format, "report", "Report", rangeReportRows(report))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, report)
+}
+
+// GetPortfolioReport handles HTTP requests for the usage of every parking lot combined over a period,
+// bucketed in the optional timeZone query parameter, UTC by default.
+func (h *ParkingLotHandler) GetPortfolioReport(w http.ResponseWriter, r *http.Request) {
+	q, err := parseReportQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var appErr common.AppError
+	if q.Location, appErr = domain.LoadTimeZone(r.URL.Query().Get("timeZone")); appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	report, appErr := h.Repo.GetPortfolioReport(r.Context(), q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		report.InTimeZone()
+	}
+
+	if format != export.FormatJSON {
+		writeExport(w, h.Logger, format, "portfolio-report", "Portfolio Report", portfolioReportRows(report))
+		return
+	}
+
 	writeResponse(w, http.StatusOK, report)
 }
+
+// parseReportQuery reads the from and to (YYYY-MM-DD or RFC 3339) and the optional granularity query parameters.
+// Dates are midnight in the report's time zone.
+func parseReportQuery(r *http.Request) (domain.ReportQuery, error) {
+	params := r.URL.Query()
+	q := domain.ReportQuery{Granularity: domain.ReportGranularity(params.Get("granularity"))}
+
+	var err error
+	if q.From, q.FromDate, err = parseReportTime(params.Get("from")); err != nil {
+		return q, errors.New("invalid from, expected YYYY-MM-DD or RFC 3339")
+	}
+
+	if q.To, q.ToDate, err = parseReportTime(params.Get("to")); err != nil {
+		return q, errors.New("invalid to, expected YYYY-MM-DD or RFC 3339")
+	}
+
+	return q, nil
+}
+
+// parseReportTime parses a date, reporting it's one, or an RFC 3339 time. Empty values are left zero.
+func parseReportTime(v string) (time.Time, bool, error) {
+	if v == "" {
+		return time.Time{}, false, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+// SlotMaintenanceRequest represents the request body for moving a slot into or out of maintenance.
+type SlotMaintenanceRequest struct {
+	InMaintenance bool   `json:"inMaintenance"`
+	Force         bool   `json:"force"`
+	PerformedBy   string `json:"performedBy"`
+	Reason        string `json:"reason"`
+}
+
+// SetSlotMaintenance handles HTTP requests to put a slot under maintenance or bring it back into service.
+func (h *ParkingLotHandler) SetSlotMaintenance(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	slotUUID, err := uuid.Parse(r.PathValue("slotId"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid slot ID format"})
+		return
+	}
+
+	var reqBody SlotMaintenanceRequest
+	if err = json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	if reqBody.PerformedBy == "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "performedBy can't be empty"})
+		return
+	}
+
+	slot, appErr := h.Repo.SetSlotMaintenance(r.Context(), plUUID, slotUUID, domain.MaintenanceChange{
+		InMaintenance: reqBody.InMaintenance,
+		Force:         reqBody.Force,
+		PerformedBy:   reqBody.PerformedBy,
+		Reason:        reqBody.Reason,
+	})
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, slot)
+}
+
+// ListMaintenanceWindows handles HTTP requests for the maintenance history of a parking lot, a page of windows at a time
+// by the optional limit and cursor query parameters.
+func (h *ParkingLotHandler) ListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	q := domain.MaintenanceQuery{Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+	}
+
+	page, appErr := h.Repo.ListMaintenanceWindows(r.Context(), plUUID, q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		page.InTimeZone()
+	}
+
+	writeResponse(w, http.StatusOK, page)
+}
+
+// GetPricingPolicy handles HTTP requests for the pricing policy currently in effect for a parking lot.
+func (h *ParkingLotHandler) GetPricingPolicy(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	policy, appErr := h.Repo.GetPricingPolicy(r.Context(), plUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, policy)
+}
+
+// SetPricingPolicy handles HTTP requests to replace the pricing policy of a parking lot.
+func (h *ParkingLotHandler) SetPricingPolicy(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	var policy domain.PricingPolicy
+	if err = json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	updated, appErr := h.Repo.SetPricingPolicy(r.Context(), plUUID, &policy)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, updated)
+}
+
+// AllocationStrategyRequest represents the request body for changing the slot allocation strategy of a parking lot.
+type AllocationStrategyRequest struct {
+	Strategy string `json:"strategy"`
+}
+
+// AllocationStrategyResponse is the slot allocation strategy of a parking lot.
+type AllocationStrategyResponse struct {
+	ParkingLotID uuid.UUID                 `json:"parkingLotId"`
+	Strategy     domain.AllocationStrategy `json:"strategy"`
+}
+
+// GetAllocationStrategy handles HTTP requests for the slot allocation strategy of a parking lot.
+func (h *ParkingLotHandler) GetAllocationStrategy(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	strategy, appErr := h.Repo.GetAllocationStrategy(r.Context(), plUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, AllocationStrategyResponse{ParkingLotID: plUUID, Strategy: strategy})
+}
+
+// SetAllocationStrategy handles HTTP requests to change the slot allocation strategy of a parking lot.
+func (h *ParkingLotHandler) SetAllocationStrategy(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	var reqBody AllocationStrategyRequest
+	if err = json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	if reqBody.Strategy == "" {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "strategy can't be empty"})
+		return
+	}
+
+	strategy, err := domain.ParseAllocationStrategy(reqBody.Strategy)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.Repo.SetAllocationStrategy(r.Context(), plUUID, strategy); appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	h.Logger.Info("allocation strategy changed", "parking_lot_id", plUUID, "strategy", strategy)
+	writeResponse(w, http.StatusOK, AllocationStrategyResponse{ParkingLotID: plUUID, Strategy: strategy})
+}
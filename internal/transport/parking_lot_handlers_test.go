@@ -0,0 +1,329 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestCreateParkingLot tests parking lot creation, payload validation and duplicate names.
+func TestCreateParkingLot(t *testing.T) {
+	router := newTestRouter()
+
+	lot := createTestLot(t, router, "Parking Lot 1", 3)
+	if len(lot.Slots) != 3 || lot.Slots[0].SlotNumber != 1 || lot.Slots[2].SlotNumber != 3 {
+		t.Errorf("CreateParkingLot returned slots %+v; expected slots numbered 1..3", lot.Slots)
+	}
+
+	tests := []struct {
+		name     string
+		body     any
+		expected int
+	}{
+		{"invalid payload", "{", http.StatusBadRequest},
+		{"missing name", map[string]any{"desiredSlots": 2}, http.StatusBadRequest},
+		{"duplicate name", map[string]any{"name": "Parking Lot 1", "desiredSlots": 2}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/parking-lots", tt.body)
+			if rec.Code != tt.expected {
+				t.Errorf("CreateParkingLot returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGetParkingLotStatus tests status lookups by parking lot ID.
+func TestGetParkingLotStatus(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	doRequest(t, router, http.MethodPost, "/parking-lots/"+lot.ID.String()+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	rec := doRequest(t, router, http.MethodGet, "/parking-lots/"+lot.ID.String()+"/status", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetParkingLotStatus returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var status domain.ParkingLotStatus
+	decodeResponse(t, rec, &status)
+	if status.Name != lot.Name || len(status.Slots) != 2 {
+		t.Errorf("GetParkingLotStatus returned %+v; expected 2 slots of %s", status, lot.Name)
+	}
+
+	if status.Slots[0].RegistrationNum == nil || *status.Slots[0].RegistrationNum != "ABC123" {
+		t.Errorf("GetParkingLotStatus returned slot %+v; expected ABC123 parked in slot 1", status.Slots[0])
+	}
+
+	if rec = doRequest(t, router, http.MethodGet, "/parking-lots/invalid/status", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("GetParkingLotStatus with invalid ID returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec = doRequest(t, router, http.MethodGet, "/parking-lots/"+uuid.NewString()+"/status", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GetParkingLotStatus with unknown ID returned %d; expected %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// TestGetDailyReport tests report date validation and totals of today's parking.
+func TestGetDailyReport(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-124"})
+
+	rec := doRequest(t, router, http.MethodGet, lotPath+"/reports/"+time.Now().UTC().Format("2006-01-02"), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetDailyReport returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var report domain.DailyReport
+	decodeResponse(t, rec, &report)
+
+	expected := domain.DailyReport{
+		TotalVehiclesParked: 2,
+		TotalParkingHours:   1,
+		TotalFeeCollected:   10,
+		Transient:           domain.UsageReport{VehiclesParked: 2, ParkingHours: 1, FeeCollected: 10},
+	}
+	if report != expected {
+		t.Errorf("GetDailyReport returned %+v; expected %+v", report, expected)
+	}
+
+	if rec = doRequest(t, router, http.MethodGet, lotPath+"/reports/22-11-2023", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("GetDailyReport with invalid date returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// TestGetRangeReport tests query validation of ranged and portfolio reports, and a daily series of today's parking.
+func TestGetRangeReport(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	today := time.Now().UTC()
+	period := "?from=" + today.AddDate(0, 0, -1).Format("2006-01-02") + "&to=" + today.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var report domain.RangeReport
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/reports"+period, nil), &report)
+	if len(report.Buckets) != 2 || report.Granularity != domain.ReportGranularityDay || report.Total.VehiclesParked != 1 ||
+		report.Buckets[1].PeakOccupancy != 1 {
+		t.Errorf("GetRangeReport returned %+v; expected 2 daily buckets with ABC-123 parked today", report)
+	}
+
+	var portfolio domain.PortfolioReport
+	decodeResponse(t, doRequest(t, router, http.MethodGet, "/reports"+period+"&granularity=week", nil), &portfolio)
+	if len(portfolio.Lots) != 1 || portfolio.Lots[0].Total.VehiclesParked != 1 || portfolio.Total.VehiclesParked != 1 {
+		t.Errorf("GetPortfolioReport returned %+v; expected ABC-123 in %s", portfolio, lot.Name)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"missing period", lotPath + "/reports", http.StatusBadRequest},
+		{"invalid from", lotPath + "/reports?from=13-03-2024&to=2024-03-14", http.StatusBadRequest},
+		{"to before from", lotPath + "/reports?from=2024-03-14&to=2024-03-13", http.StatusBadRequest},
+		{"unknown granularity", lotPath + "/reports?from=2024-03-13&to=2024-03-14&granularity=year", http.StatusBadRequest},
+		{"too many buckets", lotPath + "/reports?from=2020-01-01&to=2024-01-01&granularity=hour", http.StatusBadRequest},
+		{"invalid parking lot ID", "/parking-lots/invalid/reports?from=2024-03-13&to=2024-03-14", http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/reports?from=2024-03-13&to=2024-03-14", http.StatusNotFound},
+		{"portfolio missing period", "/reports?from=2024-03-13", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodGet, tt.path, nil); rec.Code != tt.expected {
+				t.Errorf("GET %s returned %d; expected %d", tt.path, rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestLocalTime tests parking lots keep their time zone and localTime renders timestamps in it.
+func TestLocalTime(t *testing.T) {
+	router := newTestRouter()
+
+	rec := doRequest(t, router, http.MethodPost, "/parking-lots", map[string]any{"name": "Parking Lot 1", "desiredSlots": 1, "timeZone": "Asia/Dhaka"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateParkingLot returned %d; expected %d", rec.Code, http.StatusCreated)
+	}
+
+	var lot domain.ParkingLot
+	decodeResponse(t, rec, &lot)
+	lotPath := "/parking-lots/" + lot.ID.String()
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	var status domain.ParkingLotStatus
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/status?localTime=true", nil), &status)
+	if _, offset := status.Slots[0].ParkedAt.Zone(); status.TimeZone != "Asia/Dhaka" || offset != 6*60*60 {
+		t.Errorf("GetParkingLotStatus returned %+v parked at %s; expected Asia/Dhaka times", status, status.Slots[0].ParkedAt)
+	}
+
+	var sessions domain.SessionPage
+	decodeResponse(t, doRequest(t, router, http.MethodGet, "/vehicles/ABC-123/sessions", nil), &sessions)
+	if _, offset := sessions.Sessions[0].ParkedAt.Zone(); offset != 0 {
+		t.Errorf("ListVehicleSessions returned park time %s; expected UTC without localTime", sessions.Sessions[0].ParkedAt)
+	}
+
+	var portfolio domain.PortfolioReport
+	decodeResponse(t, doRequest(t, router, http.MethodGet, "/reports?from=2024-03-12&to=2024-03-13&timeZone=Asia/Dhaka&localTime=true", nil), &portfolio)
+	if portfolio.TimeZone != "Asia/Dhaka" || portfolio.From.Format(time.RFC3339) != "2024-03-12T00:00:00+06:00" {
+		t.Errorf("GetPortfolioReport returned %+v; expected a report from midnight in Asia/Dhaka", portfolio)
+	}
+
+	for _, path := range []string{"/reports?from=2024-03-12&to=2024-03-13&timeZone=Local", "/reports?from=2024-03-12&to=2024-03-13&timeZone=Mars/Olympus"} {
+		if rec = doRequest(t, router, http.MethodGet, path, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s returned %d; expected %d", path, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestSetSlotMaintenance tests occupied slots only enter maintenance when forced, and maintenance slots aren't allocated.
+func TestSetSlotMaintenance(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+	slotPath := lotPath + "/slots/" + lot.Slots[0].ID.String() + "/maintenance"
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	tests := []struct {
+		name     string
+		body     any
+		expected int
+	}{
+		{"missing performedBy", map[string]any{"inMaintenance": true}, http.StatusBadRequest},
+		{"occupied without force", map[string]any{"inMaintenance": true, "performedBy": "ops"}, http.StatusConflict},
+		{"occupied with force", map[string]any{"inMaintenance": true, "force": true, "performedBy": "ops"}, http.StatusOK},
+		{"already under maintenance", map[string]any{"inMaintenance": true, "performedBy": "ops"}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPut, slotPath, tt.body)
+			if rec.Code != tt.expected {
+				t.Errorf("SetSlotMaintenance returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+
+	// slot 1 is freed but still under maintenance, the next vehicle must get slot 2.
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-124"}), &vehicle)
+	if vehicle.SlotID != lot.Slots[1].ID {
+		t.Errorf("Park chose slot %s; expected slot 2 %s", vehicle.SlotID, lot.Slots[1].ID)
+	}
+
+	if rec := doRequest(t, router, http.MethodPut, lotPath+"/slots/"+uuid.NewString()+"/maintenance",
+		map[string]any{"inMaintenance": true, "performedBy": "ops"}); rec.Code != http.StatusNotFound {
+		t.Errorf("SetSlotMaintenance with unknown slot returned %d; expected %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// TestListMaintenanceWindows tests the maintenance history records forced windows, and rejects invalid pages.
+func TestListMaintenanceWindows(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 1)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+	doRequest(t, router, http.MethodPut, lotPath+"/slots/"+lot.Slots[0].ID.String()+"/maintenance",
+		map[string]any{"inMaintenance": true, "force": true, "performedBy": "ops", "reason": "broken barrier"})
+
+	rec := doRequest(t, router, http.MethodGet, lotPath+"/maintenance?limit=10", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListMaintenanceWindows returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var page domain.MaintenancePage
+	decodeResponse(t, rec, &page)
+	if len(page.Windows) != 1 || !page.Windows[0].Forced || page.Windows[0].Reason != "broken barrier" {
+		t.Errorf("ListMaintenanceWindows returned %+v; expected the forced window", page)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"invalid parking lot ID", "/parking-lots/invalid/maintenance", http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/maintenance", http.StatusNotFound},
+		{"invalid limit", lotPath + "/maintenance?limit=0", http.StatusBadRequest},
+		{"limit too large", lotPath + "/maintenance?limit=201", http.StatusBadRequest},
+		{"invalid cursor", lotPath + "/maintenance?cursor=nope", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodGet, tt.path, nil); rec.Code != tt.expected {
+				t.Errorf("ListMaintenanceWindows returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAllocationStrategy tests changing the allocation strategy of a parking lot and that parks report it.
+func TestAllocationStrategy(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 3)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	if lot.AllocationStrategy != domain.AllocationNearest {
+		t.Errorf("CreateParkingLot returned strategy %q; expected %q", lot.AllocationStrategy, domain.AllocationNearest)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     any
+		expected int
+	}{
+		{"invalid payload", lotPath, "{", http.StatusBadRequest},
+		{"missing strategy", lotPath, map[string]string{}, http.StatusBadRequest},
+		{"unknown strategy", lotPath, map[string]string{"strategy": "cheapest"}, http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString(), map[string]string{"strategy": "random"}, http.StatusNotFound},
+		{"round robin", lotPath, map[string]string{"strategy": "Round-Robin"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPut, tt.path+"/allocation", tt.body)
+			if rec.Code != tt.expected {
+				t.Errorf("SetAllocationStrategy returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+
+	var strategy AllocationStrategyResponse
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/allocation", nil), &strategy)
+	if strategy.Strategy != domain.AllocationRoundRobin {
+		t.Errorf("GetAllocationStrategy returned %q; expected %q", strategy.Strategy, domain.AllocationRoundRobin)
+	}
+
+	// round robin moves on to slot 2 even though slot 1 is free again.
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-124"}), &vehicle)
+	if vehicle.SlotID != lot.Slots[1].ID || vehicle.AllocationStrategy != domain.AllocationRoundRobin {
+		t.Errorf("Park returned %+v; expected slot 2 %s chosen by %s", vehicle, lot.Slots[1].ID, domain.AllocationRoundRobin)
+	}
+
+	rec := doRequest(t, router, http.MethodPost, "/parking-lots", map[string]any{"name": "Parking Lot 2", "desiredSlots": 1, "allocationStrategy": "cheapest"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("CreateParkingLot with unknown strategy returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+}
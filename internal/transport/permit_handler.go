@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+// CreatePermitRequest represents the request body for issuing a permit, times are RFC 3339.
+// validFrom defaults to now and validUntil to a month later, a permit without slotId floats across the lot's free slots.
+// Every plate must be a valid plate of country, of any country when empty.
+type CreatePermitRequest struct {
+	Holder     string     `json:"holder"`
+	Plates     []string   `json:"plates"`
+	Country    string     `json:"country"`
+	SlotID     *uuid.UUID `json:"slotId"`
+	ValidFrom  time.Time  `json:"validFrom"`
+	ValidUntil time.Time  `json:"validUntil"`
+}
+
+type PermitHandler struct {
+	Repo   domain.PermitRepository
+	Plates *plate.Rules
+	Logger *slog.Logger
+}
+
+// CreatePermit handles HTTP requests to issue a permit for a parking lot.
+func (h *PermitHandler) CreatePermit(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	var reqBody CreatePermitRequest
+	if err = json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	plates, ok := parsePlates(w, h.Plates, reqBody.Country, reqBody.Plates...)
+	if !ok {
+		return
+	}
+
+	permit, appErr := h.Repo.CreatePermit(r.Context(), plUUID, domain.PermitRequest{
+		Holder:     reqBody.Holder,
+		Plates:     plates,
+		SlotID:     reqBody.SlotID,
+		ValidFrom:  reqBody.ValidFrom,
+		ValidUntil: reqBody.ValidUntil,
+	})
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, permit)
+}
+
+// ListPermits handles HTTP requests for the permits of a parking lot.
+func (h *PermitHandler) ListPermits(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	permits, appErr := h.Repo.ListPermits(r.Context(), plUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, permits)
+}
+
+// RevokePermit handles HTTP requests to revoke a permit.
+func (h *PermitHandler) RevokePermit(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	permitUUID, err := uuid.Parse(r.PathValue("permitId"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid permit ID format"})
+		return
+	}
+
+	permit, appErr := h.Repo.RevokePermit(r.Context(), plUUID, permitUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, permit)
+}
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestPermits tests permit payload validation, listing, revocation, and that permit holders park in their slot for free.
+func TestPermits(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	valid := map[string]any{"holder": "Acme", "plates": []string{"ABC-123"}, "slotId": lot.Slots[1].ID}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     any
+		expected int
+	}{
+		{"invalid payload", lotPath, "{", http.StatusBadRequest},
+		{"invalid parking lot ID", "/parking-lots/invalid", valid, http.StatusBadRequest},
+		{"invalid slot ID", lotPath, map[string]any{"holder": "Acme", "plates": []string{"ABC-123"}, "slotId": "invalid"},
+			http.StatusBadRequest},
+		{"missing plates", lotPath, map[string]any{"holder": "Acme"}, http.StatusBadRequest},
+		{"unknown slot", lotPath, map[string]any{"holder": "Acme", "plates": []string{"ABC-123"}, "slotId": uuid.NewString()},
+			http.StatusNotFound},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString(), valid, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, tt.path+"/permits", tt.body)
+			if rec.Code != tt.expected {
+				t.Errorf("CreatePermit returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+
+	rec := doRequest(t, router, http.MethodPost, lotPath+"/permits", valid)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreatePermit returned %d; expected %d", rec.Code, http.StatusCreated)
+	}
+
+	var permit domain.Permit
+	decodeResponse(t, rec, &permit)
+	if permit.SlotLabel != "2" || !permit.ValidUntil.After(permit.ValidFrom) {
+		t.Errorf("CreatePermit returned %+v; expected a permit of slot 2", permit)
+	}
+
+	if rec = doRequest(t, router, http.MethodPost, lotPath+"/permits", valid); rec.Code != http.StatusConflict {
+		t.Errorf("CreatePermit overlapping a permit returned %d; expected %d", rec.Code, http.StatusConflict)
+	}
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"}), &vehicle)
+	if vehicle.SlotID != lot.Slots[1].ID || vehicle.PermitID == nil || *vehicle.PermitID != permit.ID {
+		t.Errorf("Park returned %+v; expected the permit slot %s", vehicle, lot.Slots[1].ID)
+	}
+
+	decodeResponse(t, doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"}), &vehicle)
+	if vehicle.Fee != 0 || vehicle.PermitID == nil {
+		t.Errorf("Unpark returned %+v; expected a free permit stay", vehicle)
+	}
+
+	permitPath := lotPath + "/permits/" + permit.ID.String()
+	if rec = doRequest(t, router, http.MethodDelete, permitPath, nil); rec.Code != http.StatusOK {
+		t.Errorf("RevokePermit returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	if rec = doRequest(t, router, http.MethodDelete, permitPath, nil); rec.Code != http.StatusConflict {
+		t.Errorf("RevokePermit twice returned %d; expected %d", rec.Code, http.StatusConflict)
+	}
+
+	if rec = doRequest(t, router, http.MethodDelete, lotPath+"/permits/invalid", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("RevokePermit with invalid ID returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+
+	var permits []domain.Permit
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/permits", nil), &permits)
+	if len(permits) != 1 || permits[0].ID != permit.ID || permits[0].RevokedAt == nil {
+		t.Errorf("ListPermits returned %+v; expected the revoked permit %s", permits, permit.ID)
+	}
+}
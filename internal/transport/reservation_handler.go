@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/plate"
+	"github.com/google/uuid"
+)
+
+// CreateReservationRequest represents the request body for reserving a slot, times are RFC 3339.
+// An empty vehicle type reserves for a car, holdMinutes defaults to 15. The preferred level and zone are optional,
+// the plate's country too.
+type CreateReservationRequest struct {
+	RegistrationNumber string    `json:"registrationNumber"`
+	Country            string    `json:"country"`
+	VehicleType        string    `json:"vehicleType"`
+	PreferredLevel     string    `json:"preferredLevel"`
+	PreferredZone      string    `json:"preferredZone"`
+	StartsAt           time.Time `json:"startsAt"`
+	EndsAt             time.Time `json:"endsAt"`
+	HoldMinutes        int       `json:"holdMinutes"`
+}
+
+type ReservationHandler struct {
+	Repo   domain.ReservationRepository
+	Plates *plate.Rules
+	Logger *slog.Logger
+}
+
+// CreateReservation handles HTTP requests to reserve a slot of a parking lot for a time window.
+func (h *ReservationHandler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	var reqBody CreateReservationRequest
+	if err = json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+
+	plates, ok := parsePlates(w, h.Plates, reqBody.Country, reqBody.RegistrationNumber)
+	if !ok {
+		return
+	}
+
+	vehicleType, err := domain.ParseVehicleType(reqBody.VehicleType)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reservation, appErr := h.Repo.CreateReservation(r.Context(), plUUID, domain.ReservationRequest{
+		RegistrationNumber: plates[0],
+		StartsAt:           reqBody.StartsAt,
+		EndsAt:             reqBody.EndsAt,
+		Hold:               time.Duration(reqBody.HoldMinutes) * time.Minute,
+		Options: domain.ParkOptions{
+			VehicleType:    vehicleType,
+			PreferredLevel: reqBody.PreferredLevel,
+			PreferredZone:  reqBody.PreferredZone,
+		},
+	})
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, reservation)
+}
+
+// GetReservation handles HTTP requests for a reservation of a parking lot.
+func (h *ReservationHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	plUUID, resUUID, ok := reservationPath(w, r)
+	if !ok {
+		return
+	}
+
+	reservation, appErr := h.Repo.GetReservation(r.Context(), plUUID, resUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, reservation)
+}
+
+// CancelReservation handles HTTP requests to cancel an active reservation.
+func (h *ReservationHandler) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	plUUID, resUUID, ok := reservationPath(w, r)
+	if !ok {
+		return
+	}
+
+	reservation, appErr := h.Repo.CancelReservation(r.Context(), plUUID, resUUID)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, reservation)
+}
+
+// reservationPath parses the parking lot and reservation IDs of the path, writing a 400 Bad Request response if either is invalid.
+func reservationPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	resUUID, err := uuid.Parse(r.PathValue("reservationId"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid reservation ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return plUUID, resUUID, true
+}
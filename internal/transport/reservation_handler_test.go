@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestReservations tests reservation payload validation, lookups, and that parking fulfils the reservation.
+func TestReservations(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	startsAt := time.Now().Add(10 * time.Minute).UTC()
+	valid := map[string]any{"registrationNumber": "ABC-123", "startsAt": startsAt, "endsAt": startsAt.Add(time.Hour)}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     any
+		expected int
+	}{
+		{"invalid payload", lotPath, "{", http.StatusBadRequest},
+		{"invalid parking lot ID", "/parking-lots/invalid", valid, http.StatusBadRequest},
+		{"unknown vehicle type", lotPath, map[string]any{"registrationNumber": "ABC-123", "vehicleType": "bus",
+			"startsAt": startsAt, "endsAt": startsAt.Add(time.Hour)}, http.StatusBadRequest},
+		{"ends before it starts", lotPath, map[string]any{"registrationNumber": "ABC-123",
+			"startsAt": startsAt, "endsAt": startsAt.Add(-time.Hour)}, http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString(), valid, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, tt.path+"/reservations", tt.body)
+			if rec.Code != tt.expected {
+				t.Errorf("CreateReservation returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+
+	rec := doRequest(t, router, http.MethodPost, lotPath+"/reservations", valid)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateReservation returned %d; expected %d", rec.Code, http.StatusCreated)
+	}
+
+	var reservation domain.Reservation
+	decodeResponse(t, rec, &reservation)
+	if reservation.SlotID != lot.Slots[0].ID || reservation.Status != domain.ReservationActive {
+		t.Errorf("CreateReservation returned %+v; expected an active reservation of slot 1", reservation)
+	}
+
+	resPath := lotPath + "/reservations/" + reservation.ID.String()
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"}), &vehicle)
+	if vehicle.SlotID != reservation.SlotID || vehicle.ReservationID == nil || *vehicle.ReservationID != reservation.ID {
+		t.Errorf("Park returned %+v; expected the reserved slot %s", vehicle, reservation.SlotID)
+	}
+
+	decodeResponse(t, doRequest(t, router, http.MethodGet, resPath, nil), &reservation)
+	if reservation.Status != domain.ReservationFulfilled {
+		t.Errorf("GetReservation returned status %s; expected %s", reservation.Status, domain.ReservationFulfilled)
+	}
+
+	if rec = doRequest(t, router, http.MethodDelete, resPath, nil); rec.Code != http.StatusConflict {
+		t.Errorf("CancelReservation of a fulfilled reservation returned %d; expected %d", rec.Code, http.StatusConflict)
+	}
+
+	if rec = doRequest(t, router, http.MethodGet, lotPath+"/reservations/invalid", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("GetReservation with invalid ID returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,163 @@
+package transport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/export"
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	Repo   domain.SessionRepository
+	Logger *slog.Logger
+}
+
+// ListVehicleSessions handles HTTP requests for the parking history of a vehicle across every parking lot.
+// CSV and XLSX exports stream every session after the cursor instead of a page.
+func (h *SessionHandler) ListVehicleSessions(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSessionQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	if format != export.FormatJSON {
+		h.exportSessions(w, r, format, func(fn func(*domain.Session) error) common.AppError {
+			return h.Repo.StreamVehicleSessions(r.Context(), r.PathValue("registrationNumber"), q, fn)
+		})
+		return
+	}
+
+	page, appErr := h.Repo.ListVehicleSessions(r.Context(), r.PathValue("registrationNumber"), q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		page.InTimeZone()
+	}
+
+	writeResponse(w, http.StatusOK, page)
+}
+
+// ListLotSessions handles HTTP requests for the parking history of a parking lot.
+// CSV and XLSX exports stream every session after the cursor instead of a page.
+func (h *SessionHandler) ListLotSessions(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	q, err := parseSessionQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	if format != export.FormatJSON {
+		h.exportSessions(w, r, format, func(fn func(*domain.Session) error) common.AppError {
+			return h.Repo.StreamLotSessions(r.Context(), plUUID, q, fn)
+		})
+		return
+	}
+
+	page, appErr := h.Repo.ListLotSessions(r.Context(), plUUID, q)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	if wantsLocalTime(r) {
+		page.InTimeZone()
+	}
+
+	writeResponse(w, http.StatusOK, page)
+}
+
+// exportSessions writes the sessions of stream as a download, a row at a time as they're read. Errors before the first
+// session are sent as JSON, later ones abort the started download, see finishExport.
+func (h *SessionHandler) exportSessions(w http.ResponseWriter, r *http.Request, format export.Format,
+	stream func(fn func(*domain.Session) error) common.AppError) {
+	localTime := wantsLocalTime(r)
+
+	var ew export.Writer
+	start := func() error {
+		ew = startExport(w, format, "sessions", "Sessions")
+		return ew.WriteRow(sessionColumns...)
+	}
+
+	appErr := stream(func(s *domain.Session) error {
+		if ew == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if localTime {
+			s.InTimeZone()
+		}
+
+		return ew.WriteRow(sessionRow(s)...)
+	})
+
+	var err error
+	switch {
+	case appErr != nil && ew == nil:
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	case appErr != nil:
+		err = appErr
+	case ew == nil:
+		err = start()
+	}
+
+	finishExport(w, h.Logger, ew, "sessions", err)
+}
+
+// parseSessionQuery reads the optional from, to (RFC 3339), cursor, limit and order query parameters.
+func parseSessionQuery(r *http.Request) (domain.SessionQuery, error) {
+	params := r.URL.Query()
+	q := domain.SessionQuery{
+		Cursor: params.Get("cursor"),
+		Order:  domain.SessionOrder(params.Get("order")),
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return q, errors.New("invalid from, expected RFC 3339")
+		}
+	}
+
+	if to := params.Get("to"); to != "" {
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return q, errors.New("invalid to, expected RFC 3339")
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 {
+			return q, errors.New("invalid limit")
+		}
+	}
+
+	return q, nil
+}
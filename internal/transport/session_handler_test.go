@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestSessions tests session query validation and paging through a lot's and a vehicle's history.
+func TestSessions(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 2)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	for _, regNum := range []string{"ABC-123", "ABC-124"} {
+		doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": regNum})
+	}
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+
+	tests := []struct {
+		name     string
+		target   string
+		expected int
+	}{
+		{"invalid parking lot ID", "/parking-lots/invalid/sessions", http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/sessions", http.StatusNotFound},
+		{"invalid from", lotPath + "/sessions?from=yesterday", http.StatusBadRequest},
+		{"invalid to", "/vehicles/ABC-123/sessions?to=2024-13-01", http.StatusBadRequest},
+		{"invalid limit", lotPath + "/sessions?limit=0", http.StatusBadRequest},
+		{"limit over the maximum", lotPath + "/sessions?limit=1000", http.StatusBadRequest},
+		{"invalid order", lotPath + "/sessions?order=up", http.StatusBadRequest},
+		{"invalid cursor", lotPath + "/sessions?cursor=abc", http.StatusBadRequest},
+		{"unknown vehicle", "/vehicles/XYZ-1/sessions", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodGet, tt.target, nil); rec.Code != tt.expected {
+				t.Errorf("GET %s returned %d; expected %d", tt.target, rec.Code, tt.expected)
+			}
+		})
+	}
+
+	var page domain.SessionPage
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/sessions?limit=1&order=asc", nil), &page)
+	if len(page.Sessions) != 1 || page.NextCursor == "" {
+		t.Fatalf("ListLotSessions returned %+v; expected 1 session and a next cursor", page)
+	}
+
+	var next domain.SessionPage
+	decodeResponse(t, doRequest(t, router, http.MethodGet, lotPath+"/sessions?limit=1&order=asc&cursor="+url.QueryEscape(page.NextCursor), nil), &next)
+	if len(next.Sessions) != 1 || next.Sessions[0].RegistrationNumber != "ABC124" || next.NextCursor != "" {
+		t.Errorf("ListLotSessions returned %+v; expected the last session of ABC124", next)
+	}
+
+	var history domain.SessionPage
+	decodeResponse(t, doRequest(t, router, http.MethodGet, "/vehicles/ABC-123/sessions", nil), &history)
+	if len(history.Sessions) != 1 || history.Sessions[0].Fee == nil || history.Sessions[0].SlotNumber != 1 {
+		t.Errorf("ListVehicleSessions returned %+v; expected the unparked session in slot 1 with its fee", history)
+	}
+}
@@ -4,24 +4,35 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/ashtishad/gopark/internal/metrics"
+	"github.com/ashtishad/gopark/internal/plate"
 	"github.com/google/uuid"
 )
 
-// ParkVehicleRequest represents the information needed to park a vehicle in the HTTP request body
+// ParkVehicleRequest represents the information needed to park a vehicle in the HTTP request body,
+// an empty vehicle type parks a car. The preferred level and zone are optional, the plate's country too.
 type ParkVehicleRequest struct {
 	RegistrationNumber string `json:"registrationNumber"`
+	Country            string `json:"country"`
+	VehicleType        string `json:"vehicleType"`
+	PreferredLevel     string `json:"preferredLevel"`
+	PreferredZone      string `json:"preferredZone"`
 }
 
-// UnparkVehicleRequest represents the request for unparking
+// UnparkVehicleRequest represents the request for unparking, the plate's country is optional.
 type UnparkVehicleRequest struct {
 	RegistrationNumber string `json:"registrationNumber"`
+	Country            string `json:"country"`
 }
 
 type VehicleHandler struct {
-	Repo   *domain.VehicleRepositoryDB
-	Logger *slog.Logger
+	Repo    domain.VehicleRepository
+	Plates  *plate.Rules
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
 }
 
 // Park handles HTTP requests to park a vehicle
@@ -32,8 +43,14 @@ func (h *VehicleHandler) Park(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if reqBody.RegistrationNumber == "" {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "registration number can't be empty"})
+	plates, ok := parsePlates(w, h.Plates, reqBody.Country, reqBody.RegistrationNumber)
+	if !ok {
+		return
+	}
+
+	vehicleType, err := domain.ParseVehicleType(reqBody.VehicleType)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
@@ -43,7 +60,14 @@ func (h *VehicleHandler) Park(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parkedVehicle, appErr := h.Repo.ParkVehicle(r.Context(), parkingLotID, reqBody.RegistrationNumber)
+	opts := domain.ParkOptions{
+		VehicleType:    vehicleType,
+		PreferredLevel: reqBody.PreferredLevel,
+		PreferredZone:  reqBody.PreferredZone,
+	}
+
+	parkedVehicle, appErr := h.Repo.ParkVehicle(r.Context(), parkingLotID, plates[0], opts)
+	h.Metrics.ObservePark(appErr)
 	if appErr != nil {
 		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
 		return
@@ -60,12 +84,19 @@ func (h *VehicleHandler) Unpark(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if reqBody.RegistrationNumber == "" {
-		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "registration number can't be empty"})
+	plates, ok := parsePlates(w, h.Plates, reqBody.Country, reqBody.RegistrationNumber)
+	if !ok {
+		return
+	}
+
+	parkingLotID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
 		return
 	}
 
-	unparkedVehicle, appErr := h.Repo.UnparkVehicle(r.Context(), reqBody.RegistrationNumber)
+	unparkedVehicle, appErr := h.Repo.UnparkVehicle(r.Context(), parkingLotID, plates[0])
+	h.Metrics.ObserveUnpark(unparkedVehicle, appErr)
 	if appErr != nil {
 		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
 		return
@@ -73,3 +104,40 @@ func (h *VehicleHandler) Unpark(w http.ResponseWriter, r *http.Request) {
 
 	writeResponse(w, http.StatusOK, unparkedVehicle)
 }
+
+// GetCurrentLocation handles HTTP requests for where a vehicle is parked right now, with a running fee estimate.
+func (h *VehicleHandler) GetCurrentLocation(w http.ResponseWriter, r *http.Request) {
+	parked, appErr := h.Repo.FindParkedVehicle(r.Context(), r.PathValue("registrationNumber"))
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, parked)
+}
+
+// QuoteFee handles HTTP requests for the itemized fee of a parked vehicle at the optional `at` query parameter (RFC 3339),
+// now by default, without unparking it.
+func (h *VehicleHandler) QuoteFee(w http.ResponseWriter, r *http.Request) {
+	plUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid parking lot ID format"})
+		return
+	}
+
+	var at time.Time
+	if param := r.URL.Query().Get("at"); param != "" {
+		if at, err = time.Parse(time.RFC3339, param); err != nil {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid at, expected RFC 3339"})
+			return
+		}
+	}
+
+	quote, appErr := h.Repo.QuoteFee(r.Context(), plUUID, r.PathValue("registrationNumber"), at)
+	if appErr != nil {
+		writeResponse(w, appErr.Code(), map[string]string{"error": appErr.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, quote)
+}
@@ -0,0 +1,215 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/gopark/internal/domain"
+	"github.com/google/uuid"
+)
+
+// TestPark tests parking allocates the nearest slot and rejects invalid requests, double parking and full lots.
+func TestPark(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 1)
+	parkPath := "/parking-lots/" + lot.ID.String() + "/park"
+
+	rec := doRequest(t, router, http.MethodPost, parkPath, map[string]string{"registrationNumber": "ABC-123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Park returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, rec, &vehicle)
+	if vehicle.SlotID != lot.Slots[0].ID || vehicle.RegistrationNumber != "ABC123" || vehicle.RawRegistrationNumber != "ABC-123" {
+		t.Errorf("Park returned %+v; expected ABC123 entered as ABC-123 in slot %s", vehicle, lot.Slots[0].ID)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     any
+		expected int
+	}{
+		{"invalid payload", parkPath, "{", http.StatusBadRequest},
+		{"empty registration number", parkPath, map[string]string{"registrationNumber": ""}, http.StatusBadRequest},
+		{"unknown vehicle type", parkPath, map[string]string{"registrationNumber": "ABC-124", "vehicleType": "bus"}, http.StatusBadRequest},
+		{"invalid parking lot ID", "/parking-lots/invalid/park", map[string]string{"registrationNumber": "ABC-124"}, http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/park", map[string]string{"registrationNumber": "ABC-124"}, http.StatusNotFound},
+		{"already parked", parkPath, map[string]string{"registrationNumber": "ABC-123"}, http.StatusConflict},
+		{"already parked written differently", parkPath, map[string]string{"registrationNumber": "abc 123"}, http.StatusConflict},
+		{"invalid plate characters", parkPath, map[string]string{"registrationNumber": "ABC-12Ж"}, http.StatusBadRequest},
+		{"parking lot full", parkPath, map[string]string{"registrationNumber": "ABC-124"}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodPost, tt.path, tt.body); rec.Code != tt.expected {
+				t.Errorf("Park returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParkPlatePatterns tests registration numbers are validated against the configured plate patterns of their country.
+func TestParkPlatePatterns(t *testing.T) {
+	router := newTestRouterWithPlates(map[string]string{"GB": "[A-Z]{2}[0-9]{2}[A-Z]{3}", "NL": "[A-Z0-9]{6}"})
+	lot := createTestLot(t, router, "Parking Lot 1", 3)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	tests := []struct {
+		name     string
+		path     string
+		body     map[string]any
+		expected int
+	}{
+		{"plate of the country", "/park", map[string]any{"registrationNumber": "ab12 cde", "country": "GB"}, http.StatusOK},
+		{"plate of any country", "/park", map[string]any{"registrationNumber": "12-AB-34"}, http.StatusOK},
+		{"plate of another country", "/park", map[string]any{"registrationNumber": "12-AB-35", "country": "GB"}, http.StatusBadRequest},
+		{"unknown country", "/park", map[string]any{"registrationNumber": "AB12CDF", "country": "FR"}, http.StatusBadRequest},
+		{"plate of no country", "/park", map[string]any{"registrationNumber": "A1"}, http.StatusBadRequest},
+		{"reservation plate of no country", "/reservations", map[string]any{"registrationNumber": "A1"}, http.StatusBadRequest},
+		{"permit plate of no country", "/permits", map[string]any{"holder": "Acme", "plates": []string{"AB12CDG", "A1"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodPost, lotPath+tt.path, tt.body); rec.Code != tt.expected {
+				t.Errorf("POST %s returned %d; expected %d", tt.path, rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestUnpark tests unparking charges a fee and frees the slot, unknown vehicles and vehicles parked in other lots are rejected.
+func TestUnpark(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 1)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	rec := doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unpark returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var vehicle domain.Vehicle
+	decodeResponse(t, rec, &vehicle)
+	if vehicle.UnparkedAt == nil || vehicle.Fee != 10 || vehicle.Currency != "USD" {
+		t.Errorf("Unpark returned %+v; expected a fee of 10 USD for the first started hour", vehicle)
+	}
+
+	if rec = doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"}); rec.Code != http.StatusConflict {
+		t.Errorf("Unpark of an unparked vehicle returned %d; expected %d", rec.Code, http.StatusConflict)
+	}
+
+	if rec = doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": ""}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Unpark with empty registration number returned %d; expected %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// malformed plates are rejected like on park, not looked up.
+	for _, regNum := range []string{"ÄBC-123", "ABC-123-DEF-456-GHIJ"} {
+		if rec = doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": regNum}); rec.Code != http.StatusBadRequest {
+			t.Errorf("Unpark of malformed plate %q returned %d; expected %d", regNum, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	if rec = doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-124"}); rec.Code != http.StatusOK {
+		t.Errorf("Park after unpark returned %d; expected the freed slot to be available", rec.Code)
+	}
+
+	other := createTestLot(t, router, "Parking Lot 2", 1)
+	otherPath := "/parking-lots/" + other.ID.String()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"parked in another parking lot", otherPath + "/unpark", http.StatusConflict},
+		{"invalid parking lot ID", "/parking-lots/invalid/unpark", http.StatusBadRequest},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/unpark", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodPost, tt.path, map[string]string{"registrationNumber": "ABC-124"}); rec.Code != tt.expected {
+				t.Errorf("Unpark returned %d; expected %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGetCurrentLocation tests finding a parked vehicle, and 404 once it's unparked.
+func TestGetCurrentLocation(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 1)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+
+	rec := doRequest(t, router, http.MethodGet, "/vehicles/ABC-123/current", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetCurrentLocation returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var parked domain.ParkedVehicle
+	decodeResponse(t, rec, &parked)
+	if parked.ParkingLotID != lot.ID || parked.ParkingLotName != lot.Name || parked.SlotNumber != 1 || parked.EstimatedFee != 10 ||
+		parked.Currency != "USD" {
+		t.Errorf("GetCurrentLocation returned %+v; expected slot 1 of %s with an estimated fee of 10 USD", parked, lot.Name)
+	}
+
+	doRequest(t, router, http.MethodPost, lotPath+"/unpark", map[string]string{"registrationNumber": "ABC-123"})
+
+	if rec = doRequest(t, router, http.MethodGet, "/vehicles/ABC-123/current", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GetCurrentLocation of an unparked vehicle returned %d; expected %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// TestQuoteFee tests quote time validation, and that a quote leaves the vehicle parked.
+func TestQuoteFee(t *testing.T) {
+	router := newTestRouter()
+	lot := createTestLot(t, router, "Parking Lot 1", 1)
+	lotPath := "/parking-lots/" + lot.ID.String()
+
+	doRequest(t, router, http.MethodPost, lotPath+"/park", map[string]string{"registrationNumber": "ABC-123"})
+	at := time.Now().UTC().Add(150 * time.Minute).Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		target   string
+		expected int
+	}{
+		{"invalid parking lot ID", "/parking-lots/invalid/vehicles/ABC-123/quote", http.StatusBadRequest},
+		{"invalid at", lotPath + "/vehicles/ABC-123/quote?at=tomorrow", http.StatusBadRequest},
+		{"at before parking", lotPath + "/vehicles/ABC-123/quote?at=2020-01-01T00:00:00Z", http.StatusBadRequest},
+		{"vehicle not parked", lotPath + "/vehicles/XYZ-1/quote", http.StatusNotFound},
+		{"unknown parking lot", "/parking-lots/" + uuid.NewString() + "/vehicles/ABC-123/quote", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := doRequest(t, router, http.MethodGet, tt.target, nil); rec.Code != tt.expected {
+				t.Errorf("GET %s returned %d; expected %d", tt.target, rec.Code, tt.expected)
+			}
+		})
+	}
+
+	rec := doRequest(t, router, http.MethodGet, lotPath+"/vehicles/ABC-123/quote?at="+at, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("QuoteFee returned %d; expected %d", rec.Code, http.StatusOK)
+	}
+
+	var quote domain.FeeQuote
+	decodeResponse(t, rec, &quote)
+	if quote.Total != 30 || len(quote.LineItems) != 1 || quote.LineItems[0].Hours != 3 {
+		t.Errorf("QuoteFee returned %+v; expected 30 for 3 started hours on a single line", quote)
+	}
+
+	if rec = doRequest(t, router, http.MethodGet, "/vehicles/ABC-123/current", nil); rec.Code != http.StatusOK {
+		t.Errorf("GetCurrentLocation after a quote returned %d; expected the vehicle to stay parked", rec.Code)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -8,85 +10,228 @@ import (
 	"net/http"
 	"os"
 	"time"
+	_ "time/tzdata" // parking lot time zones load without tzdata in the alpine image
 
 	"github.com/ashtishad/gopark/internal/common"
+	"github.com/ashtishad/gopark/internal/config"
 	"github.com/ashtishad/gopark/internal/domain"
 	"github.com/ashtishad/gopark/internal/infra/postgres"
+	"github.com/ashtishad/gopark/internal/lifecycle"
+	"github.com/ashtishad/gopark/internal/metrics"
+	"github.com/ashtishad/gopark/internal/plate"
 	"github.com/ashtishad/gopark/internal/transport"
 )
 
 func main() {
-	// 1. Initialize structured logger
-	handlerOpts := common.GetSlogConf()
+	// 1. Load configuration from defaults, an optional YAML file, env variables and flags.
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+
+	// 2. Initialize structured logger
+	logLevel, _ := cfg.Log.SlogLevel() // validated by config.Load
+	handlerOpts := common.GetSlogConf(logLevel)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, handlerOpts))
 	slog.SetDefault(logger)
 
-	// 2. Check environment variables, if not exists sets default.
-	sanityCheck(logger)
+	logger.Info("configuration loaded", "config", cfg)
 
-	// 3. Get postgres database client
-	dbClient := postgres.GetDBClient(logger)
+	// 3. Lifecycle manager, on SIGINT/SIGTERM fails readiness for the readiness delay, drains in-flight requests
+	// then stops components in reverse registration order.
+	app := lifecycle.NewManager(logger, cfg.Server.ShutdownTimeout, cfg.Server.ShutdownReadinessDelay)
 
-	defer dbClient.Close()
+	// 4. Wire up repositories, kept in memory when STORAGE_BACKEND=memory, otherwise backed by postgres.
+	var parkingLotRepo domain.ParkingLotRepository
+	var vehicleRepo domain.VehicleRepository
+	var reservationRepo domain.ReservationRepository
+	var permitRepo domain.PermitRepository
+	var sessionRepo domain.SessionRepository
+	var analyticsRepo domain.AnalyticsRepository
+	var dbClient *sql.DB
+	var migrator *postgres.Migrator
+	healthHandler := transport.HealthHandler{
+		Checks:       map[string]transport.HealthCheck{},
+		Starting:     app.Starting,
+		ShuttingDown: app.ShuttingDown,
+		Logger:       logger,
+	}
 
-	// 4. Wire up dependencies
-	parkingLotRepo := domain.NewParkingLotRepoDB(dbClient, logger)
-	parkingLotHandler := transport.ParkingLotHandler{Repo: parkingLotRepo, Logger: logger}
+	if cfg.StorageBackend == config.StorageBackendMemory {
+		// There is no schema to migrate in memory, fail rather than start the server a migration job expects to exit.
+		if len(args) > 0 && args[0] == "migrate" {
+			logger.Error("migrate command requires STORAGE_BACKEND=postgres", "storageBackend", cfg.StorageBackend)
+			os.Exit(1)
+		}
+
+		store := domain.NewMemoryStore()
+		parkingLotRepo = domain.NewParkingLotRepoMemory(store, logger)
+		vehicleRepo = domain.NewVehicleRepoMemory(store, logger).WithPlatePolicy(domain.PlatePolicy(cfg.Parking.PlatePolicy))
+		reservationRepo = domain.NewReservationRepoMemory(store, logger)
+		permitRepo = domain.NewPermitRepoMemory(store, logger)
+		sessionRepo = domain.NewSessionRepoMemory(store, logger)
+		analyticsRepo = domain.NewAnalyticsRepoMemory(store, logger)
+		logger.Warn("using in-memory storage, all data is lost on shutdown")
+	} else {
+		dbClient = postgres.GetDBClient(logger, cfg.DB)
+
+		// Run the `migrate up|down|status` command and exit, otherwise pending migrations are applied once the server listens.
+		var mErr error
+		migrator, mErr = postgres.NewMigrator(dbClient, logger)
+		if mErr != nil {
+			logger.Error("error loading migrations", "err", mErr)
+			dbClient.Close()
+			os.Exit(1)
+		}
+
+		if len(args) > 0 && args[0] == "migrate" {
+			if mErr = runMigrateCommand(context.Background(), migrator, args[1:]); mErr != nil {
+				logger.Error("migrate command failed", "err", mErr)
+				dbClient.Close()
+				os.Exit(1)
+			}
+
+			dbClient.Close()
+			return
+		}
+
+		healthHandler.Checks["database"] = postgres.PingCheck(dbClient)
+		healthHandler.Checks["migrations"] = postgres.MigrationCheck(migrator)
+		healthHandler.Checks["pool"] = postgres.PoolCheck(dbClient)
+
+		// the pool is closed only after in-flight park/unpark transactions have drained.
+		app.OnShutdown("database", func(context.Context) error { return dbClient.Close() })
+
+		parkingLotRepo = domain.NewParkingLotRepoDB(dbClient, logger)
+		vehicleRepo = domain.NewVehicleRepoDB(dbClient, logger).WithPlatePolicy(domain.PlatePolicy(cfg.Parking.PlatePolicy))
+		reservationRepo = domain.NewReservationRepoDB(dbClient, logger)
+		permitRepo = domain.NewPermitRepoDB(dbClient, logger)
+		sessionRepo = domain.NewSessionRepoDB(dbClient, logger)
+		analyticsRepo = domain.NewAnalyticsRepoDB(dbClient, logger)
+	}
+
+	// 5. Metrics, slot gauges are read from the repository on every scrape.
+	appMetrics := metrics.New(parkingLotRepo, logger)
+	if dbClient != nil {
+		appMetrics.RegisterDBStats(dbClient)
+	}
 
-	vehicleRepo := domain.NewVehicleRepoDB(dbClient, logger)
-	vehicleHandler := transport.VehicleHandler{Repo: vehicleRepo, Logger: logger}
+	// 6. Wire up handlers, registration numbers are validated against the configured plate patterns.
+	plateRules, _ := plate.NewRules(cfg.Parking.PlatePatterns) // validated by config.Load
+	parkingLotHandler := transport.ParkingLotHandler{Repo: parkingLotRepo, Logger: logger}
+	vehicleHandler := transport.VehicleHandler{Repo: vehicleRepo, Plates: plateRules, Logger: logger, Metrics: appMetrics}
+	reservationHandler := transport.ReservationHandler{Repo: reservationRepo, Plates: plateRules, Logger: logger}
+	permitHandler := transport.PermitHandler{Repo: permitRepo, Plates: plateRules, Logger: logger}
+	sessionHandler := transport.SessionHandler{Repo: sessionRepo, Logger: logger}
+	analyticsHandler := transport.AnalyticsHandler{Repo: analyticsRepo, Logger: logger}
 
-	// 5. Structured Server Configuration
+	// 7. Structured Server Configuration
 	srv := &http.Server{
-		Addr:              net.JoinHostPort(os.Getenv("API_HOST"), os.Getenv("API_PORT")),
+		Addr:              net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
 		Handler:           nil,
-		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       15 * time.Second,
-		ReadHeaderTimeout: 2 * time.Second,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
 	}
 
-	// 6. Route Registration (using a router or a simple mux)
+	// 8. Route Registration (using a router or a simple mux), every route but /metrics records its latency.
 	router := http.NewServeMux()
-	router.HandleFunc("POST /parking-lots", parkingLotHandler.CreateParkingLot)
-	router.HandleFunc("GET /parking-lots/{id}/status", parkingLotHandler.GetParkingLotStatus)
-	router.HandleFunc("GET /parking-lots/{id}/reports/{date}", parkingLotHandler.GetDailyReport)
-	router.HandleFunc("POST /parking-lots/{id}/park", vehicleHandler.Park)
-	router.HandleFunc("POST /parking-lots/{id}/unpark", vehicleHandler.Unpark)
-	srv.Handler = router
-
-	// 7. Start the Server
-	logger.Info("Server starting...", slog.String("address", srv.Addr))
-	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		router.Handle(pattern, appMetrics.Instrument(pattern, h))
+	}
+
+	router.Handle("GET /metrics", appMetrics.Handler(logger))
+	handle("GET /healthz", healthHandler.Liveness)
+	handle("GET /readyz", healthHandler.Readiness)
+	handle("POST /parking-lots", parkingLotHandler.CreateParkingLot)
+	handle("GET /parking-lots/{id}/status", parkingLotHandler.GetParkingLotStatus)
+	handle("GET /parking-lots/{id}/reports/{date}", parkingLotHandler.GetDailyReport)
+	handle("GET /parking-lots/{id}/reports", parkingLotHandler.GetRangeReport)
+	handle("GET /reports", parkingLotHandler.GetPortfolioReport)
+	handle("GET /parking-lots/{id}/analytics", analyticsHandler.GetOccupancyAnalytics)
+	handle("PUT /parking-lots/{id}/slots/{slotId}/maintenance", parkingLotHandler.SetSlotMaintenance)
+	handle("GET /parking-lots/{id}/maintenance", parkingLotHandler.ListMaintenanceWindows)
+	handle("GET /parking-lots/{id}/pricing", parkingLotHandler.GetPricingPolicy)
+	handle("PUT /parking-lots/{id}/pricing", parkingLotHandler.SetPricingPolicy)
+	handle("GET /parking-lots/{id}/allocation", parkingLotHandler.GetAllocationStrategy)
+	handle("PUT /parking-lots/{id}/allocation", parkingLotHandler.SetAllocationStrategy)
+	handle("POST /parking-lots/{id}/park", vehicleHandler.Park)
+	handle("POST /parking-lots/{id}/unpark", vehicleHandler.Unpark)
+	handle("GET /parking-lots/{id}/vehicles/{registrationNumber}/quote", vehicleHandler.QuoteFee)
+	handle("POST /parking-lots/{id}/reservations", reservationHandler.CreateReservation)
+	handle("GET /parking-lots/{id}/reservations/{reservationId}", reservationHandler.GetReservation)
+	handle("DELETE /parking-lots/{id}/reservations/{reservationId}", reservationHandler.CancelReservation)
+	handle("POST /parking-lots/{id}/permits", permitHandler.CreatePermit)
+	handle("GET /parking-lots/{id}/permits", permitHandler.ListPermits)
+	handle("DELETE /parking-lots/{id}/permits/{permitId}", permitHandler.RevokePermit)
+	handle("GET /parking-lots/{id}/sessions", sessionHandler.ListLotSessions)
+	handle("GET /vehicles/{registrationNumber}/sessions", sessionHandler.ListVehicleSessions)
+	handle("GET /vehicles/{registrationNumber}/current", vehicleHandler.GetCurrentLocation)
+	srv.Handler = healthHandler.StartupGate(router)
+
+	// 9. Start the Server, /healthz and /readyz answer while migrations run, readiness stays 503 and every other route
+	// is refused until they're applied.
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
 		logger.Error("error starting server", "err", err)
-		return
+		os.Exit(1)
+	}
+
+	app.Start(context.Background(), srv, ln)
+
+	if migrator != nil {
+		if _, err = migrator.Up(context.Background()); err != nil {
+			logger.Error("error applying migrations", "err", err)
+			dbClient.Close()
+			os.Exit(1)
+		}
+	}
+
+	app.MarkStarted()
+
+	// 10. Block until shutdown completes.
+	if err = app.Wait(); err != nil {
+		logger.Error("error shutting down", "err", err)
+		os.Exit(1)
 	}
 }
 
-// sanityCheck checks essential env variables required ot run the app, sets defaults if not exists
-func sanityCheck(l *slog.Logger) {
-	defaultEnvVars := map[string]string{
-		"API_HOST":  "127.0.0.1",
-		"API_PORT":  "8080",
-		"DB_USER":   "postgres",
-		"DB_PASSWD": "postgres",
-		"DB_HOST":   "127.0.0.1",
-		"DB_PORT":   "5432",
-		"DB_NAME":   "gopark",
+// runMigrateCommand runs `gopark migrate up|down|status`: apply pending migrations, roll back the latest one,
+// or print every migration with the time it was applied.
+func runMigrateCommand(ctx context.Context, m *postgres.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: gopark migrate up|down|status")
 	}
 
-	for key, defaultValue := range defaultEnvVars {
-		if os.Getenv(key) == "" {
-			if err := os.Setenv(key, defaultValue); err != nil {
-				l.Error(fmt.Sprintf(
-					"failed to set environment variable %s to default value %s. Exiting application.",
-					key,
-					defaultValue,
-				))
-				os.Exit(1)
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
 			}
 
-			l.Warn(fmt.Sprintf("environment variable %s not defined. Setting to default: %s", key, defaultValue))
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, appliedAt)
 		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, usage: gopark migrate up|down|status", args[0])
 	}
+
+	return nil
 }